@@ -1,250 +1,596 @@
 package Onyx1ALU
 
-import "math"
+import (
+	"math"
+	"math/bits"
+)
 
 const (
-	ALU_FLAGS_ERROR        = 0x0000_0000_0000_0001
-	ALU_FLAGS_ZERO         = 0x0000_0000_0000_0002
-	ALU_FLAGS_NEGATIVE     = 0x0000_0000_0000_0004
-	ALU_FLAGS_CARRY        = 0x0000_0000_0000_0008
-	ALU_FLAGS_DIVIDEBYZERO = 0x0000_0000_0000_0010
-	ALU_FLAGS_INVALIDOP    = 0x0000_0000_0000_0020
+	ALU_FLAGS_ERROR           = 0x0000_0000_0000_0001
+	ALU_FLAGS_ZERO            = 0x0000_0000_0000_0002
+	ALU_FLAGS_NEGATIVE        = 0x0000_0000_0000_0004
+	ALU_FLAGS_CARRY           = 0x0000_0000_0000_0008
+	ALU_FLAGS_DIVIDEBYZERO    = 0x0000_0000_0000_0010
+	ALU_FLAGS_INVALIDOP       = 0x0000_0000_0000_0020
+	ALU_FLAGS_OVERFLOW        = 0x0000_0000_0000_0040
+	ALU_FLAGS_UNORDERED       = 0x0000_0000_0000_0080
+	ALU_FLAGS_FPINVALID       = 0x0000_0000_0000_0100
+	ALU_FLAGS_FPOVERFLOW      = 0x0000_0000_0000_0200
+	ALU_FLAGS_FPUNDERFLOW     = 0x0000_0000_0000_0400
+	ALU_FLAGS_FPINEXACT       = 0x0000_0000_0000_0800
+	ALU_FLAGS_DECIMALOVERFLOW = 0x0000_0000_0000_1000
+	ALU_FLAGS_INVALIDDIGIT    = 0x0000_0000_0000_2000
+	ALU_FLAGS_SATURATED       = 0x0000_0000_0000_4000
 )
 
 const (
-	ALU_OP_ADDINT64  = 0x0000_0000_0000_0001
-	ALU_OP_SUBINT64  = 0x0000_0000_0000_0002
-	ALU_OP_MULTINT64 = 0x0000_0000_0000_0003
-	ALU_OP_DIVINT64  = 0x0000_0000_0000_0004
-	ALU_OP_ANDINT64  = 0x0000_0000_0000_0005
-	ALU_OP_NOTINT64  = 0x0000_0000_0000_0006
-	ALU_OP_ORINT64   = 0x0000_0000_0000_0007
-	ALU_OP_XORINT64  = 0x0000_0000_0000_0008
-	ALU_OP_SHLINT64  = 0x0000_0000_0000_0009
-	ALU_OP_SHRINT64  = 0x0000_0000_0000_000A
-	ALU_OP_FADD64    = 0x0000_0000_0000_000B
-	ALU_OP_FSUB64    = 0x0000_0000_0000_000C
-	ALU_OP_FMULT64   = 0x0000_0000_0000_000D
-	ALU_OP_FDIV64    = 0x0000_0000_0000_000E
-	ALU_OP_FSIN64    = 0x0000_0000_0000_000F
-	ALU_OP_FCOS64    = 0x0000_0000_0000_0010
-	ALU_OP_FTAN64    = 0x0000_0000_0000_0011
-	ALU_OP_FLN64     = 0x0000_0000_0000_0012
-	ALU_OP_FEXP64    = 0x0000_0000_0000_0013
-	ALU_OP_FSQRT64   = 0x0000_0000_0000_0014
+	ALU_OP_ADDINT64    = 0x0000_0000_0000_0001
+	ALU_OP_SUBINT64    = 0x0000_0000_0000_0002
+	ALU_OP_MULTINT64   = 0x0000_0000_0000_0003
+	ALU_OP_DIVINT64    = 0x0000_0000_0000_0004
+	ALU_OP_ANDINT64    = 0x0000_0000_0000_0005
+	ALU_OP_NOTINT64    = 0x0000_0000_0000_0006
+	ALU_OP_ORINT64     = 0x0000_0000_0000_0007
+	ALU_OP_XORINT64    = 0x0000_0000_0000_0008
+	ALU_OP_SHLINT64    = 0x0000_0000_0000_0009
+	ALU_OP_SHRINT64    = 0x0000_0000_0000_000A
+	ALU_OP_FADD64      = 0x0000_0000_0000_000B
+	ALU_OP_FSUB64      = 0x0000_0000_0000_000C
+	ALU_OP_FMULT64     = 0x0000_0000_0000_000D
+	ALU_OP_FDIV64      = 0x0000_0000_0000_000E
+	ALU_OP_FSIN64      = 0x0000_0000_0000_000F
+	ALU_OP_FCOS64      = 0x0000_0000_0000_0010
+	ALU_OP_FTAN64      = 0x0000_0000_0000_0011
+	ALU_OP_FLN64       = 0x0000_0000_0000_0012
+	ALU_OP_FEXP64      = 0x0000_0000_0000_0013
+	ALU_OP_FSQRT64     = 0x0000_0000_0000_0014
+	ALU_OP_ADCINT64    = 0x0000_0000_0000_0015
+	ALU_OP_SBCINT64    = 0x0000_0000_0000_0016
+	ALU_OP_UADDINT64   = 0x0000_0000_0000_0017
+	ALU_OP_USUBINT64   = 0x0000_0000_0000_0018
+	ALU_OP_UMULINT64   = 0x0000_0000_0000_0019
+	ALU_OP_UDIVINT64   = 0x0000_0000_0000_001A
+	ALU_OP_SHRLINT64   = 0x0000_0000_0000_001B
+	ALU_OP_ROLINT64    = 0x0000_0000_0000_001C
+	ALU_OP_RORINT64    = 0x0000_0000_0000_001D
+	ALU_OP_RCLINT64    = 0x0000_0000_0000_001E
+	ALU_OP_RCRINT64    = 0x0000_0000_0000_001F
+	ALU_OP_POPCNT64    = 0x0000_0000_0000_0020
+	ALU_OP_CLZINT64    = 0x0000_0000_0000_0021
+	ALU_OP_CTZINT64    = 0x0000_0000_0000_0022
+	ALU_OP_BTINT64     = 0x0000_0000_0000_0023
+	ALU_OP_BTSINT64    = 0x0000_0000_0000_0024
+	ALU_OP_BTRINT64    = 0x0000_0000_0000_0025
+	ALU_OP_BTCINT64    = 0x0000_0000_0000_0026
+	ALU_OP_BFEXTINT64  = 0x0000_0000_0000_0027
+	ALU_OP_BFINSINT64  = 0x0000_0000_0000_0028
+	ALU_OP_CMPINT64    = 0x0000_0000_0000_0029
+	ALU_OP_TESTINT64   = 0x0000_0000_0000_002A
+	ALU_OP_FCMP64      = 0x0000_0000_0000_002B
+	ALU_OP_FADD32      = 0x0000_0000_0000_002C
+	ALU_OP_FSUB32      = 0x0000_0000_0000_002D
+	ALU_OP_FMULT32     = 0x0000_0000_0000_002E
+	ALU_OP_FDIV32      = 0x0000_0000_0000_002F
+	ALU_OP_FSQRT32     = 0x0000_0000_0000_0030
+	ALU_OP_FCMP32      = 0x0000_0000_0000_0031
+	ALU_OP_CVTI64F64   = 0x0000_0000_0000_0032
+	ALU_OP_CVTF64I64   = 0x0000_0000_0000_0033
+	ALU_OP_CVTI32F32   = 0x0000_0000_0000_0034
+	ALU_OP_CVTF32I32   = 0x0000_0000_0000_0035
+	ALU_OP_CVTF64F32   = 0x0000_0000_0000_0036
+	ALU_OP_CVTF32F64   = 0x0000_0000_0000_0037
+	ALU_OP_FTRUNC64    = 0x0000_0000_0000_0038
+	ALU_OP_FROUND64    = 0x0000_0000_0000_0039
+	ALU_OP_FFLOOR64    = 0x0000_0000_0000_003A
+	ALU_OP_FCEIL64     = 0x0000_0000_0000_003B
+	ALU_OP_FMA64       = 0x0000_0000_0000_003C
+	ALU_OP_FMIN64      = 0x0000_0000_0000_003D
+	ALU_OP_FMAX64      = 0x0000_0000_0000_003E
+	ALU_OP_FABS64      = 0x0000_0000_0000_003F
+	ALU_OP_FNEG64      = 0x0000_0000_0000_0040
+	ALU_OP_FCOPYSIGN64 = 0x0000_0000_0000_0041
+	ALU_OP_FREM64      = 0x0000_0000_0000_0042
+	ALU_OP_FATAN264    = 0x0000_0000_0000_0043
+	ALU_OP_FPOW64      = 0x0000_0000_0000_0044
+	ALU_OP_FLOG1064    = 0x0000_0000_0000_0045
+	ALU_OP_FLOG264     = 0x0000_0000_0000_0046
+	ALU_OP_DADD        = 0x0000_0000_0000_0047
+	ALU_OP_DSUB        = 0x0000_0000_0000_0048
+	ALU_OP_DMUL        = 0x0000_0000_0000_0049
+	ALU_OP_DDIV        = 0x0000_0000_0000_004A
+	ALU_OP_DCMP        = 0x0000_0000_0000_004B
+	ALU_OP_DPACK       = 0x0000_0000_0000_004C
+	ALU_OP_DUNPACK     = 0x0000_0000_0000_004D
+	ALU_OP_DEDIT       = 0x0000_0000_0000_004E
+	ALU_OP_VADD        = 0x0000_0000_0000_004F
+	ALU_OP_VSUB        = 0x0000_0000_0000_0050
+	ALU_OP_VADDS       = 0x0000_0000_0000_0051
+	ALU_OP_VADDUS      = 0x0000_0000_0000_0052
+	ALU_OP_VSUBS       = 0x0000_0000_0000_0053
+	ALU_OP_VSUBUS      = 0x0000_0000_0000_0054
+	ALU_OP_VMULLO      = 0x0000_0000_0000_0055
+	ALU_OP_VMULHI      = 0x0000_0000_0000_0056
+	ALU_OP_VMULHIU     = 0x0000_0000_0000_0057
+	ALU_OP_VMIN        = 0x0000_0000_0000_0058
+	ALU_OP_VMAX        = 0x0000_0000_0000_0059
+	ALU_OP_VMINU       = 0x0000_0000_0000_005A
+	ALU_OP_VMAXU       = 0x0000_0000_0000_005B
+	ALU_OP_VCMPEQ      = 0x0000_0000_0000_005C
+	ALU_OP_VCMPGT      = 0x0000_0000_0000_005D
+	ALU_OP_VCMPGTU     = 0x0000_0000_0000_005E
+	ALU_OP_VSHUF       = 0x0000_0000_0000_005F
+	ALU_OP_CRC32       = 0x0000_0000_0000_0060
+	ALU_OP_CRC32C      = 0x0000_0000_0000_0061
+	ALU_OP_CRC16CCITT  = 0x0000_0000_0000_0062
+	ALU_OP_CLMUL64     = 0x0000_0000_0000_0063
+	ALU_OP_AESENC      = 0x0000_0000_0000_0064
+	ALU_OP_AESENCLAST  = 0x0000_0000_0000_0065
+	ALU_OP_AESDEC      = 0x0000_0000_0000_0066
+	ALU_OP_AESDECLAST  = 0x0000_0000_0000_0067
+	ALU_OP_AESIMC      = 0x0000_0000_0000_0068
+	ALU_OP_BIGADD      = 0x0000_0000_0000_0069
+	ALU_OP_BIGSUB      = 0x0000_0000_0000_006A
+	ALU_OP_BIGMUL      = 0x0000_0000_0000_006B
+	ALU_OP_BIGDIVMOD   = 0x0000_0000_0000_006C
+	ALU_OP_BIGSHL      = 0x0000_0000_0000_006D
+	ALU_OP_BIGSHR      = 0x0000_0000_0000_006E
+	ALU_OP_BIGSHRL     = 0x0000_0000_0000_006F
+	ALU_OP_BIGCMP      = 0x0000_0000_0000_0070
+	ALU_OP_FADD80      = 0x0000_0000_0000_0071
+	ALU_OP_FSUB80      = 0x0000_0000_0000_0072
+	ALU_OP_FMULT80     = 0x0000_0000_0000_0073
+	ALU_OP_FDIV80      = 0x0000_0000_0000_0074
+	ALU_OP_FSQRT80     = 0x0000_0000_0000_0075
+	ALU_OP_FCMP80      = 0x0000_0000_0000_0076
+	ALU_OP_QMUL        = 0x0000_0000_0000_0077
+	ALU_OP_QDIV        = 0x0000_0000_0000_0078
+	ALU_OP_QRECIP      = 0x0000_0000_0000_0079
+	ALU_OP_QSQRT       = 0x0000_0000_0000_007A
 )
 
+const (
+	ALU_WIDTH_8  = 8
+	ALU_WIDTH_16 = 16
+	ALU_WIDTH_32 = 32
+	ALU_WIDTH_64 = 64
+)
+
+// widthMask returns the mask of the bits that are significant at the given operand width
+func widthMask(width int) uint64 {
+	if width == ALU_WIDTH_64 {
+		return 0xFFFF_FFFF_FFFF_FFFF
+	}
+	return (uint64(1) << uint(width)) - 1
+}
+
+// truncateToWidth drops everything above the operand width and sign extends the rest back to an int64
+func truncateToWidth(v int64, width int) int64 {
+	shift := uint(64 - width)
+	return (v << shift) >> shift
+}
+
+func isValidWidth(width int) bool {
+	switch width {
+	case ALU_WIDTH_8, ALU_WIDTH_16, ALU_WIDTH_32, ALU_WIDTH_64:
+		return true
+	}
+	return false
+}
+
+// resultFlags computes ZERO and NEGATIVE from a result that has already been truncated to the operand width
+func resultFlags(outA int64) (flags uint64) {
+	if outA < 0 {
+		flags |= ALU_FLAGS_NEGATIVE
+	}
+	if outA == 0 {
+		flags |= ALU_FLAGS_ZERO
+	}
+	return flags
+}
+
+// signedProductFits reports whether parmA*parmB is representable at the operand width
+func signedProductFits(parmA int64, parmB int64, width int) bool {
+	if width < ALU_WIDTH_64 {
+		// Operands of 32 bits or less can't overflow the int64 product
+		p := parmA * parmB
+		return truncateToWidth(p, width) == p
+	}
+	hi, lo := bits.Mul64(uint64(parmA), uint64(parmB))
+	// Correct the unsigned high word into the signed one
+	if parmA < 0 {
+		hi -= uint64(parmB)
+	}
+	if parmB < 0 {
+		hi -= uint64(parmA)
+	}
+	return int64(hi) == int64(lo)>>63
+}
+
 func ALUInt64(op int, parmA int64, parmB int64) (outA int64, outB int64, flags uint64) {
-	switch op {
-	case ALU_OP_ADDINT64:
-		outA = parmA + parmB
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
-		if outA == 0 {
-			flags |= ALU_FLAGS_ZERO
-		}
-		return outA, parmB, flags
-	case ALU_OP_SUBINT64:
-		outA = parmA - parmB
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
-		if outA == 0 {
-			flags |= ALU_FLAGS_ZERO
-		}
-		return outA, outB, flags
-	case ALU_OP_MULTINT64:
-		outA = parmA * parmB
-		if outA == 0 {
-			flags |= ALU_FLAGS_ZERO
-		}
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
-		return outA, outB, flags
-	case ALU_OP_DIVINT64:
-		if parmB == 0 {
-			flags |= ALU_FLAGS_ERROR
-			flags |= ALU_FLAGS_DIVIDEBYZERO
-			return 0, 0, flags
-		}
-		outA = parmA / parmB
-		outB = parmA % parmB
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
-		if outA == 0 {
-			flags |= ALU_FLAGS_ZERO
-		}
-		return outA, outB, flags
-	case ALU_OP_ANDINT64:
-		outA := parmA & parmB
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
-		if outA == 0 {
-			flags |= ALU_FLAGS_ZERO
-		}
-		return outA, outB, flags
-	case ALU_OP_NOTINT64:
-		outA = ^parmA
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
-		if outA == 0 {
-			flags |= ALU_FLAGS_ZERO
-		}
-		return outA, outB, flags
-	case ALU_OP_ORINT64:
-		outA = parmA | parmB
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
-		if outA == 0 {
-			flags |= ALU_FLAGS_ZERO
-		}
-		return outA, outB, flags
-	case ALU_OP_XORINT64:
-		outA = parmA ^ parmB
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
-		if outA == 0 {
-			flags |= ALU_FLAGS_ZERO
-		}
-		return outA, outB, flags
-	case ALU_OP_SHLINT64:
-		if parmB >= 64 {
-			flags |= ALU_FLAGS_ERROR
-			flags |= ALU_FLAGS_INVALIDOP
-			return 0, 0, flags
-		}
-		outA = parmA << parmB
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
-		if outA == 0 {
-			flags |= ALU_FLAGS_ZERO
-		}
-		return outA, outB, flags
-	case ALU_OP_SHRINT64:
-		if parmB >= 64 {
-			flags |= ALU_FLAGS_ERROR
-			flags |= ALU_FLAGS_INVALIDOP
-			return 0, 0, flags
-		}
-		outA := parmA >> parmB
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
-		if outA == 0 {
-			flags |= ALU_FLAGS_ZERO
-		}
-		return outA, outB, flags
+	return ALUIntWidthWithFlags(op, ALU_WIDTH_64, parmA, parmB, 0)
+}
+
+// ALUInt64WithFlags is ALUInt64 for ops that consume incoming flags, such as the
+// carry/borrow into ADC and SBC. flagsIn is normally the flags word from the previous op.
+func ALUInt64WithFlags(op int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	return ALUIntWidthWithFlags(op, ALU_WIDTH_64, parmA, parmB, flagsIn)
+}
+
+func ALUInt32(op int, parmA int32, parmB int32) (outA int32, outB int32, flags uint64) {
+	a, b, flags := ALUIntWidth(op, ALU_WIDTH_32, int64(parmA), int64(parmB))
+	return int32(a), int32(b), flags
+}
+
+func ALUInt16(op int, parmA int16, parmB int16) (outA int16, outB int16, flags uint64) {
+	a, b, flags := ALUIntWidth(op, ALU_WIDTH_16, int64(parmA), int64(parmB))
+	return int16(a), int16(b), flags
+}
+
+func ALUInt8(op int, parmA int8, parmB int8) (outA int8, outB int8, flags uint64) {
+	a, b, flags := ALUIntWidth(op, ALU_WIDTH_8, int64(parmA), int64(parmB))
+	return int8(a), int8(b), flags
+}
+
+// ALUIntWidth performs an integer operation at an 8, 16, 32 or 64-bit operand width.
+// Operands are truncated to the width first, results come back sign extended to an int64,
+// and the flags describe the truncated result rather than the full int64 one.
+func ALUIntWidth(op int, width int, parmA int64, parmB int64) (outA int64, outB int64, flags uint64) {
+	return ALUIntWidthWithFlags(op, width, parmA, parmB, 0)
+}
+
+// ALUIntWidthWithFlags is ALUIntWidth taking an incoming flags word
+func ALUIntWidthWithFlags(op int, width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	if !isValidWidth(width) {
+		flags |= ALU_FLAGS_INVALIDOP
+		flags |= ALU_FLAGS_ERROR
+		return 0, 0, flags
+	}
+	info, ok := aluOps[op]
+	if !ok || info.intOp == nil {
+		flags |= ALU_FLAGS_INVALIDOP
+		flags |= ALU_FLAGS_ERROR
+		return 0, 0, flags
+	}
+	return info.intOp(width, truncateToWidth(parmA, width), truncateToWidth(parmB, width), flagsIn)
+}
+
+// shiftedOperands returns unsigned views of the operands shifted so the top bit of the width
+// lands in bit 63, which lets the 64-bit carry/borrow logic work unchanged for the narrow widths
+func shiftedOperands(width int, parmA int64, parmB int64) (ua uint64, ub uint64, shift uint) {
+	shift = uint(64 - width)
+	return uint64(parmA) << shift, uint64(parmB) << shift, shift
+}
+
+// withOp binds the op code into one of the family helpers shared by several ops
+func withOp(op int, f func(op int, width int, parmA int64, parmB int64, flagsIn uint64) (int64, int64, uint64)) intOpFunc {
+	return func(width int, parmA int64, parmB int64, flagsIn uint64) (int64, int64, uint64) {
+		return f(op, width, parmA, parmB, flagsIn)
+	}
+}
+
+func intAdd(width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	return addWithCarry(width, parmA, parmB, false)
+}
+
+func intAdc(width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	return addWithCarry(width, parmA, parmB, flagsIn&ALU_FLAGS_CARRY != 0)
+}
+
+func addWithCarry(width int, parmA int64, parmB int64, carryIn bool) (outA int64, outB int64, flags uint64) {
+	ua, ub, shift := shiftedOperands(width, parmA, parmB)
+	sum, carry := bits.Add64(ua, ub, 0)
+	if carryIn {
+		var carry2 uint64
+		sum, carry2 = bits.Add64(sum, uint64(1)<<shift, 0)
+		carry |= carry2
+	}
+	outA = int64(sum) >> shift
+	flags |= resultFlags(outA)
+	if carry != 0 {
+		flags |= ALU_FLAGS_CARRY
+	}
+	// Signed overflow when both operands have the same sign and the result does not
+	if ((ua^sum)&(ub^sum))>>63 != 0 {
+		flags |= ALU_FLAGS_OVERFLOW
 	}
-	flags |= ALU_FLAGS_INVALIDOP
-	flags |= ALU_FLAGS_ERROR
-	return 0, 0, flags
+	return outA, outB, flags
+}
+
+func intSub(width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	return subWithBorrow(width, parmA, parmB, false)
+}
+
+func intSbc(width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	return subWithBorrow(width, parmA, parmB, flagsIn&ALU_FLAGS_CARRY != 0)
+}
+
+// subWithBorrow uses CARRY for the borrow, both coming in for SBC and going out
+func subWithBorrow(width int, parmA int64, parmB int64, borrowIn bool) (outA int64, outB int64, flags uint64) {
+	ua, ub, shift := shiftedOperands(width, parmA, parmB)
+	diff, borrow := bits.Sub64(ua, ub, 0)
+	if borrowIn {
+		var borrow2 uint64
+		diff, borrow2 = bits.Sub64(diff, uint64(1)<<shift, 0)
+		borrow |= borrow2
+	}
+	outA = int64(diff) >> shift
+	flags |= resultFlags(outA)
+	if borrow != 0 {
+		flags |= ALU_FLAGS_CARRY
+	}
+	// Signed overflow when the operands differ in sign and the result takes the sign of parmB
+	if ((ua^ub)&(ua^diff))>>63 != 0 {
+		flags |= ALU_FLAGS_OVERFLOW
+	}
+	return outA, outB, flags
+}
+
+func intMul(width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	outA = truncateToWidth(parmA*parmB, width)
+	flags |= resultFlags(outA)
+	// CARRY and OVERFLOW both mean the signed product did not fit in the operand width
+	if !signedProductFits(parmA, parmB, width) {
+		flags |= ALU_FLAGS_CARRY
+		flags |= ALU_FLAGS_OVERFLOW
+	}
+	return outA, outB, flags
+}
+
+func intDiv(width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	if parmB == 0 {
+		flags |= ALU_FLAGS_ERROR
+		flags |= ALU_FLAGS_DIVIDEBYZERO
+		return 0, 0, flags
+	}
+	outA = truncateToWidth(parmA/parmB, width)
+	outB = truncateToWidth(parmA%parmB, width)
+	flags |= resultFlags(outA)
+	// The most negative value divided by -1 is the only quotient that can't be represented
+	if parmB == -1 && parmA == truncateToWidth(int64(uint64(1)<<uint(width-1)), width) {
+		flags |= ALU_FLAGS_OVERFLOW
+	}
+	return outA, outB, flags
+}
+
+func intAnd(width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	outA = parmA & parmB
+	flags |= resultFlags(outA)
+	return outA, outB, flags
+}
+
+func intNot(width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	outA = ^parmA
+	flags |= resultFlags(outA)
+	return outA, outB, flags
+}
+
+func intOr(width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	outA = parmA | parmB
+	flags |= resultFlags(outA)
+	return outA, outB, flags
+}
+
+func intXor(width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	outA = parmA ^ parmB
+	flags |= resultFlags(outA)
+	return outA, outB, flags
+}
+
+func intShl(width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	if parmB < 0 || parmB >= int64(width) {
+		flags |= ALU_FLAGS_ERROR
+		flags |= ALU_FLAGS_INVALIDOP
+		return 0, 0, flags
+	}
+	outA = truncateToWidth(parmA<<parmB, width)
+	flags |= resultFlags(outA)
+	// CARRY is the last bit shifted out, OVERFLOW means the shift changed the signed value
+	if parmB > 0 && (parmA>>(int64(width)-parmB))&1 != 0 {
+		flags |= ALU_FLAGS_CARRY
+	}
+	if outA>>parmB != parmA {
+		flags |= ALU_FLAGS_OVERFLOW
+	}
+	return outA, outB, flags
+}
+
+func intShr(width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	if parmB < 0 || parmB >= int64(width) {
+		flags |= ALU_FLAGS_ERROR
+		flags |= ALU_FLAGS_INVALIDOP
+		return 0, 0, flags
+	}
+	outA = parmA >> parmB
+	flags |= resultFlags(outA)
+	if parmB > 0 && (parmA>>(parmB-1))&1 != 0 {
+		flags |= ALU_FLAGS_CARRY
+	}
+	return outA, outB, flags
+}
+
+// intUadd zero extends its result, which can't be negative. An unsigned wrap sets both
+// CARRY and OVERFLOW, as it does for the other unsigned ops.
+func intUadd(width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	ua, ub, shift := shiftedOperands(width, parmA, parmB)
+	sum, carry := bits.Add64(ua, ub, 0)
+	outA = int64(sum >> shift)
+	if outA == 0 {
+		flags |= ALU_FLAGS_ZERO
+	}
+	if carry != 0 {
+		flags |= ALU_FLAGS_CARRY
+		flags |= ALU_FLAGS_OVERFLOW
+	}
+	return outA, outB, flags
+}
+
+func intUsub(width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	ua, ub, shift := shiftedOperands(width, parmA, parmB)
+	diff, borrow := bits.Sub64(ua, ub, 0)
+	outA = int64(diff >> shift)
+	if outA == 0 {
+		flags |= ALU_FLAGS_ZERO
+	}
+	if borrow != 0 {
+		flags |= ALU_FLAGS_CARRY
+		flags |= ALU_FLAGS_OVERFLOW
+	}
+	return outA, outB, flags
+}
+
+// intUmul returns the full double-width product as high half in outA, low half in outB
+func intUmul(width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	ua, ub, shift := shiftedOperands(width, parmA, parmB)
+	hi, lo := bits.Mul64(ua>>shift, ub>>shift)
+	if width < ALU_WIDTH_64 {
+		hi = lo >> uint(width)
+		lo &= widthMask(width)
+	}
+	outA = int64(hi)
+	outB = int64(lo)
+	if hi == 0 && lo == 0 {
+		flags |= ALU_FLAGS_ZERO
+	}
+	if hi != 0 {
+		flags |= ALU_FLAGS_CARRY
+		flags |= ALU_FLAGS_OVERFLOW
+	}
+	return outA, outB, flags
+}
+
+func intUdiv(width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	if parmB == 0 {
+		flags |= ALU_FLAGS_ERROR
+		flags |= ALU_FLAGS_DIVIDEBYZERO
+		return 0, 0, flags
+	}
+	ua, ub, shift := shiftedOperands(width, parmA, parmB)
+	outA = int64((ua >> shift) / (ub >> shift))
+	outB = int64((ua >> shift) % (ub >> shift))
+	if outA == 0 {
+		flags |= ALU_FLAGS_ZERO
+	}
+	return outA, outB, flags
+}
+
+func intShrl(width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	if parmB < 0 || parmB >= int64(width) {
+		flags |= ALU_FLAGS_ERROR
+		flags |= ALU_FLAGS_INVALIDOP
+		return 0, 0, flags
+	}
+	ua := uint64(parmA) & widthMask(width)
+	outA = truncateToWidth(int64(ua>>parmB), width)
+	flags |= resultFlags(outA)
+	if parmB > 0 && (ua>>(parmB-1))&1 != 0 {
+		flags |= ALU_FLAGS_CARRY
+	}
+	return outA, outB, flags
+}
+
+// intCmp only sets flags, the operands come back unchanged
+func intCmp(width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	_, _, flags = subWithBorrow(width, parmA, parmB, false)
+	return parmA, parmB, flags
+}
+
+func intTest(width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	flags |= resultFlags(parmA & parmB)
+	return parmA, parmB, flags
 }
 
 func ALUFloat64(op int, parmA float64, parmB float64) (outA float64, outB float64, flags uint64) {
-	switch op {
-	case ALU_OP_FADD64:
-		outA = parmA + parmB
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
-		if outA == 0 {
-			flags |= ALU_FLAGS_ZERO
-		}
-		return outA, outB, flags
-	case ALU_OP_FSUB64:
-		outA = parmA - parmB
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
-		if outA == 0 {
-			flags |= ALU_FLAGS_ZERO
-		}
-		return outA, outB, flags
-	case ALU_OP_FMULT64:
-		outA = parmA * parmB
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
-		if outA == 0 {
-			flags |= ALU_FLAGS_ZERO
-		}
-		return outA, outB, flags
-	case ALU_OP_FDIV64:
-		if parmB == 0 {
-			flags |= ALU_FLAGS_ERROR
-			flags |= ALU_FLAGS_DIVIDEBYZERO
-			return 0, 0, flags
-		}
-		outA = parmA / parmB
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
-		if outA == 0 {
-			flags |= ALU_FLAGS_ZERO
-		}
-		return outA, outB, flags
-	case ALU_OP_FSIN64:
-		outA = math.Sin(parmA)
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
-		return outA, outB, flags
-	case ALU_OP_FCOS64:
-		outA = math.Cos(parmA)
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
-		return outA, outB, flags
-	case ALU_OP_FTAN64:
-		outA = math.Tan(parmA)
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
+	return ALUFloat64WithControl(op, parmA, parmB, ALU_FPCW_ROUND_NEAREST)
+}
+
+// ALUFloat64WithControl is ALUFloat64 under a float control word selecting the rounding
+// mode. Results and exceptions follow IEEE-754: x/0 is a signed infinity with
+// DIVIDEBYZERO, and invalid operations give NaN with FPINVALID.
+func ALUFloat64WithControl(op int, parmA float64, parmB float64, control uint64) (outA float64, outB float64, flags uint64) {
+	info, ok := aluOps[op]
+	if !ok || info.float64Op == nil {
+		flags |= ALU_FLAGS_INVALIDOP
+		flags |= ALU_FLAGS_ERROR
+		return 0, 0, flags
+	}
+	return info.float64Op(parmA, parmB, control)
+}
+
+// floatArith is the implementation of the correctly rounded ops
+func floatArith(op int) float64OpFunc {
+	return func(parmA float64, parmB float64, control uint64) (outA float64, outB float64, flags uint64) {
+		outA, flags = roundedArith64(op, parmA, parmB, control)
+		flags |= floatResultFlags(outA)
 		return outA, outB, flags
-	case ALU_OP_FEXP64:
-		outA := math.Exp(parmA)
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
+	}
+}
+
+// floatUnary and floatBinary wrap the math package functions used for the transcendental ops
+func floatUnary(f func(float64) float64) float64OpFunc {
+	return func(parmA float64, parmB float64, control uint64) (outA float64, outB float64, flags uint64) {
+		outA = f(parmA)
+		flags |= transcendentalFlags(outA, parmA) | floatResultFlags(outA)
 		return outA, outB, flags
-	case ALU_OP_FLN64:
-		outA := math.Log2(parmA)
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
+	}
+}
+
+func floatBinary(f func(float64, float64) float64) float64OpFunc {
+	return func(parmA float64, parmB float64, control uint64) (outA float64, outB float64, flags uint64) {
+		outA = f(parmA, parmB)
+		flags |= transcendentalFlags(outA, parmA, parmB) | floatResultFlags(outA)
 		return outA, outB, flags
-	case ALU_OP_FSQRT64:
-		outA = math.Sqrt(parmA)
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
-		if outA < 0 {
-			flags |= ALU_FLAGS_NEGATIVE
-		}
+	}
+}
+
+// floatRem is the IEEE remainder, which is always exact and can only be invalid
+func floatRem(parmA float64, parmB float64, control uint64) (outA float64, outB float64, flags uint64) {
+	outA = math.Remainder(parmA, parmB)
+	if math.IsNaN(outA) && !math.IsNaN(parmA) && !math.IsNaN(parmB) {
+		flags |= ALU_FLAGS_FPINVALID
+	}
+	flags |= floatResultFlags(outA)
+	return outA, outB, flags
+}
+
+func floatMinMax(op int) float64OpFunc {
+	return func(parmA float64, parmB float64, control uint64) (outA float64, outB float64, flags uint64) {
+		outA = minMaxNum(op, parmA, parmB)
+		flags |= floatResultFlags(outA)
 		return outA, outB, flags
 	}
-	return 0, 0, ALU_FLAGS_INVALIDOP
+}
+
+func floatAbs(parmA float64, parmB float64, control uint64) (outA float64, outB float64, flags uint64) {
+	outA = math.Abs(parmA)
+	flags |= floatResultFlags(outA)
+	return outA, outB, flags
+}
+
+func floatNeg(parmA float64, parmB float64, control uint64) (outA float64, outB float64, flags uint64) {
+	outA = math.Float64frombits(math.Float64bits(parmA) ^ (1 << 63))
+	flags |= floatResultFlags(outA)
+	return outA, outB, flags
+}
+
+func floatCopysign(parmA float64, parmB float64, control uint64) (outA float64, outB float64, flags uint64) {
+	outA = math.Copysign(parmA, parmB)
+	flags |= floatResultFlags(outA)
+	return outA, outB, flags
+}
+
+// floatCmp sets NEGATIVE and CARRY for less-than like CMPINT64, so both the signed and
+// unsigned conditions work. A NaN operand makes the compare UNORDERED.
+func floatCmp(parmA float64, parmB float64, control uint64) (outA float64, outB float64, flags uint64) {
+	if math.IsNaN(parmA) || math.IsNaN(parmB) {
+		flags |= ALU_FLAGS_UNORDERED
+		return parmA, parmB, flags
+	}
+	if parmA == parmB {
+		flags |= ALU_FLAGS_ZERO
+	}
+	if parmA < parmB {
+		flags |= ALU_FLAGS_NEGATIVE
+		flags |= ALU_FLAGS_CARRY
+	}
+	return parmA, parmB, flags
 }
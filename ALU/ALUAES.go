@@ -0,0 +1,115 @@
+package Onyx1ALU
+
+import "encoding/binary"
+
+// The AES ops follow the x86 AES-NI instructions. The 128-bit state and round key are
+// each passed as two 64-bit halves, lo holding bytes 0-7 and hi bytes 8-15, least
+// significant byte first. AESENC and AESDEC do one full round, the LAST variants skip
+// (Inv)MixColumns for the final round. AESDEC is the equivalent inverse cipher round, so
+// its round keys must be passed through AESIMC first; AESIMC ignores the state.
+
+var aesSbox, aesInvSbox [256]byte
+
+func init() {
+	// Walk the multiplicative group of GF(2^8) with generator 3, p and q being inverses
+	p, q := byte(1), byte(1)
+	for {
+		p = p ^ (p << 1) ^ ((p >> 7) * 0x1B)
+		q ^= q << 1
+		q ^= q << 2
+		q ^= q << 4
+		if q&0x80 != 0 {
+			q ^= 0x09
+		}
+		s := q ^ rotl8(q, 1) ^ rotl8(q, 2) ^ rotl8(q, 3) ^ rotl8(q, 4) ^ 0x63
+		aesSbox[p] = s
+		aesInvSbox[s] = p
+		if p == 1 {
+			break
+		}
+	}
+	aesSbox[0] = 0x63
+	aesInvSbox[0x63] = 0
+}
+
+func rotl8(v byte, n uint) byte {
+	return v<<n | v>>(8-n)
+}
+
+// gfMul multiplies in GF(2^8) modulo the AES polynomial
+func gfMul(a byte, b byte) (r byte) {
+	for b != 0 {
+		if b&1 != 0 {
+			r ^= a
+		}
+		a = a<<1 ^ (a>>7)*0x1B
+		b >>= 1
+	}
+	return r
+}
+
+// ALUAESRound performs one AES round on the state with the given round key
+func ALUAESRound(op int, stateHi uint64, stateLo uint64, keyHi uint64, keyLo uint64) (outHi uint64, outLo uint64, flags uint64) {
+	info, ok := aluOps[op]
+	if !ok || info.aesOp == nil {
+		return 0, 0, ALU_FLAGS_ERROR | ALU_FLAGS_INVALIDOP
+	}
+	var state, key [16]byte
+	binary.LittleEndian.PutUint64(state[0:], stateLo)
+	binary.LittleEndian.PutUint64(state[8:], stateHi)
+	binary.LittleEndian.PutUint64(key[0:], keyLo)
+	binary.LittleEndian.PutUint64(key[8:], keyHi)
+	state = info.aesOp(state, key)
+	outLo = binary.LittleEndian.Uint64(state[0:])
+	outHi = binary.LittleEndian.Uint64(state[8:])
+	if outHi == 0 && outLo == 0 {
+		flags |= ALU_FLAGS_ZERO
+	}
+	return outHi, outLo, flags
+}
+
+// aesRound builds the round ops. The state is column major, byte i being row i%4 of column i/4.
+func aesRound(inverse bool, mix bool) aesOpFunc {
+	return func(state [16]byte, key [16]byte) (out [16]byte) {
+		sbox := &aesSbox
+		if inverse {
+			sbox = &aesInvSbox
+		}
+		for c := 0; c < 4; c++ {
+			for r := 0; r < 4; r++ {
+				// ShiftRows moves row r left by r columns, InvShiftRows right
+				from := (c + r) % 4
+				if inverse {
+					from = (c - r + 4) % 4
+				}
+				out[r+4*c] = sbox[state[r+4*from]]
+			}
+		}
+		if mix {
+			out = mixColumns(out, inverse)
+		}
+		for i := range out {
+			out[i] ^= key[i]
+		}
+		return out
+	}
+}
+
+func mixColumns(state [16]byte, inverse bool) (out [16]byte) {
+	m := [4]byte{2, 3, 1, 1}
+	if inverse {
+		m = [4]byte{14, 11, 13, 9}
+	}
+	for c := 0; c < 4; c++ {
+		col := state[4*c : 4*c+4]
+		for r := 0; r < 4; r++ {
+			out[r+4*c] = gfMul(col[0], m[(4-r)%4]) ^ gfMul(col[1], m[(5-r)%4]) ^
+				gfMul(col[2], m[(6-r)%4]) ^ gfMul(col[3], m[(7-r)%4])
+		}
+	}
+	return out
+}
+
+func aesInvMixKey(state [16]byte, key [16]byte) [16]byte {
+	return mixColumns(key, true)
+}
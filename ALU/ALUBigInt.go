@@ -0,0 +1,216 @@
+package Onyx1ALU
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+// ALUBigInt works on integers of any size held as vectors of 64-bit limbs, least
+// significant limb first. The operands are two's complement values as wide as parmA,
+// and parmB, which may not be longer, is sign extended to that width by the signed
+// BIGADD, BIGSUB and BIGCMP and zero extended by the rest. Flags follow
+// ALUInt64: BIGADD, BIGSUB and BIGCMP set CARRY (the borrow for subtraction) and
+// OVERFLOW as ADDINT64 and SUBINT64 do. BIGMUL and BIGDIVMOD are unsigned like UMUL and
+// UDIV: BIGMUL returns the full product, len(parmA)+len(parmB) limbs long, setting CARRY
+// and OVERFLOW when it doesn't fit in len(parmA) limbs, and BIGDIVMOD returns the
+// quotient in outA and the remainder, sized like parmB, in outB. The shifts take their
+// count from parmB[0] and set CARRY to the last bit shifted out. Results are new slices.
+func ALUBigInt(op int, parmA []uint64, parmB []uint64) (outA []uint64, outB []uint64, flags uint64) {
+	info, ok := aluOps[op]
+	if !ok || info.bigIntOp == nil || len(parmA) == 0 || len(parmB) > len(parmA) {
+		return nil, nil, ALU_FLAGS_ERROR | ALU_FLAGS_INVALIDOP
+	}
+	return info.bigIntOp(parmA, parmB)
+}
+
+// limb returns limb i of v, zero past the end
+func limb(v []uint64, i int) uint64 {
+	if i < len(v) {
+		return v[i]
+	}
+	return 0
+}
+
+// signedLimb returns limb i of v, sign extending past the end
+func signedLimb(v []uint64, i int) uint64 {
+	if i < len(v) {
+		return v[i]
+	}
+	if len(v) > 0 && v[len(v)-1]>>63 != 0 {
+		return ^uint64(0)
+	}
+	return 0
+}
+
+func isZeroLimbs(v []uint64) bool {
+	for _, l := range v {
+		if l != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bigResultFlags computes ZERO and NEGATIVE for a two's complement limb vector
+func bigResultFlags(v []uint64) (flags uint64) {
+	if isZeroLimbs(v) {
+		flags |= ALU_FLAGS_ZERO
+	}
+	if len(v) > 0 && v[len(v)-1]>>63 != 0 {
+		flags |= ALU_FLAGS_NEGATIVE
+	}
+	return flags
+}
+
+func bigAdd(parmA []uint64, parmB []uint64) (outA []uint64, outB []uint64, flags uint64) {
+	outA = make([]uint64, len(parmA))
+	var carry uint64
+	for i := range parmA {
+		outA[i], carry = bits.Add64(parmA[i], signedLimb(parmB, i), carry)
+	}
+	flags |= bigResultFlags(outA)
+	if carry != 0 {
+		flags |= ALU_FLAGS_CARRY
+	}
+	top := len(parmA) - 1
+	if ((parmA[top]^outA[top])&(signedLimb(parmB, top)^outA[top]))>>63 != 0 {
+		flags |= ALU_FLAGS_OVERFLOW
+	}
+	return outA, nil, flags
+}
+
+func bigSub(parmA []uint64, parmB []uint64) (outA []uint64, outB []uint64, flags uint64) {
+	outA = make([]uint64, len(parmA))
+	var borrow uint64
+	for i := range parmA {
+		outA[i], borrow = bits.Sub64(parmA[i], signedLimb(parmB, i), borrow)
+	}
+	flags |= bigResultFlags(outA)
+	if borrow != 0 {
+		flags |= ALU_FLAGS_CARRY
+	}
+	top := len(parmA) - 1
+	if ((parmA[top]^signedLimb(parmB, top))&(parmA[top]^outA[top]))>>63 != 0 {
+		flags |= ALU_FLAGS_OVERFLOW
+	}
+	return outA, nil, flags
+}
+
+// bigCmp only sets flags, the operands come back unchanged
+func bigCmp(parmA []uint64, parmB []uint64) (outA []uint64, outB []uint64, flags uint64) {
+	_, _, flags = bigSub(parmA, parmB)
+	return parmA, parmB, flags
+}
+
+// bigMul is schoolbook multiplication, which is fine at the sizes guest code uses
+func bigMul(parmA []uint64, parmB []uint64) (outA []uint64, outB []uint64, flags uint64) {
+	outA = make([]uint64, len(parmA)+len(parmB))
+	for i, b := range parmB {
+		var carry uint64
+		for j, a := range parmA {
+			hi, lo := bits.Mul64(a, b)
+			var c uint64
+			lo, c = bits.Add64(lo, outA[i+j], 0)
+			hi += c
+			lo, c = bits.Add64(lo, carry, 0)
+			hi += c
+			outA[i+j] = lo
+			carry = hi
+		}
+		outA[i+len(parmA)] = carry
+	}
+	if isZeroLimbs(outA) {
+		flags |= ALU_FLAGS_ZERO
+	}
+	if !isZeroLimbs(outA[len(parmA):]) {
+		flags |= ALU_FLAGS_CARRY
+		flags |= ALU_FLAGS_OVERFLOW
+	}
+	return outA, nil, flags
+}
+
+func limbsToBig(v []uint64) *big.Int {
+	b := make([]byte, 8*len(v))
+	for i, l := range v {
+		for j := 0; j < 8; j++ {
+			b[len(b)-1-(8*i+j)] = byte(l >> (8 * j))
+		}
+	}
+	return new(big.Int).SetBytes(b)
+}
+
+// bigToLimbs stores the non-negative v in n limbs, dropping anything above them
+func bigToLimbs(v *big.Int, n int) []uint64 {
+	out := make([]uint64, n)
+	words := new(big.Int).Set(v)
+	mask := new(big.Int).SetUint64(^uint64(0))
+	for i := 0; i < n && words.Sign() != 0; i++ {
+		out[i] = new(big.Int).And(words, mask).Uint64()
+		words.Rsh(words, 64)
+	}
+	return out
+}
+
+func bigDivMod(parmA []uint64, parmB []uint64) (outA []uint64, outB []uint64, flags uint64) {
+	if isZeroLimbs(parmB) {
+		return nil, nil, ALU_FLAGS_ERROR | ALU_FLAGS_DIVIDEBYZERO
+	}
+	q, r := new(big.Int).QuoRem(limbsToBig(parmA), limbsToBig(parmB), new(big.Int))
+	outA = bigToLimbs(q, len(parmA))
+	outB = bigToLimbs(r, len(parmB))
+	if isZeroLimbs(outA) {
+		flags |= ALU_FLAGS_ZERO
+	}
+	return outA, outB, flags
+}
+
+// bigShift handles BIGSHL, BIGSHR and BIGSHRL. BIGSHR is arithmetic like SHRINT64.
+func bigShift(op int) bigIntOpFunc {
+	return func(parmA []uint64, parmB []uint64) (outA []uint64, outB []uint64, flags uint64) {
+		n := limb(parmB, 0)
+		if n >= uint64(64*len(parmA)) {
+			return nil, nil, ALU_FLAGS_ERROR | ALU_FLAGS_INVALIDOP
+		}
+		fill := uint64(0)
+		if op == ALU_OP_BIGSHR && parmA[len(parmA)-1]>>63 != 0 {
+			fill = ^uint64(0)
+		}
+		// ext is parmA extended with zeroes on the right and fill on the left
+		ext := func(i int) uint64 {
+			if i < 0 {
+				return 0
+			}
+			if i >= len(parmA) {
+				return fill
+			}
+			return parmA[i]
+		}
+		limbShift := int(n / 64)
+		bitShift := uint(n % 64)
+		outA = make([]uint64, len(parmA))
+		for i := range outA {
+			if op == ALU_OP_BIGSHL {
+				outA[i] = ext(i-limbShift) << bitShift
+				if bitShift != 0 {
+					outA[i] |= ext(i-limbShift-1) >> (64 - bitShift)
+				}
+			} else {
+				outA[i] = ext(i+limbShift) >> bitShift
+				if bitShift != 0 {
+					outA[i] |= ext(i+limbShift+1) << (64 - bitShift)
+				}
+			}
+		}
+		flags |= bigResultFlags(outA)
+		if n > 0 {
+			last := n - 1
+			if op == ALU_OP_BIGSHL {
+				last = uint64(64*len(parmA)) - n
+			}
+			if (parmA[last/64]>>(last%64))&1 != 0 {
+				flags |= ALU_FLAGS_CARRY
+			}
+		}
+		return outA, nil, flags
+	}
+}
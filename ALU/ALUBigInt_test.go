@@ -0,0 +1,136 @@
+package Onyx1ALU
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// toBig converts limbs through hex so the test doesn't depend on the ALU's own conversion
+func toBig(v []uint64) *big.Int {
+	s := ""
+	for i := len(v) - 1; i >= 0; i-- {
+		s += fmt.Sprintf("%016x", v[i])
+	}
+	b, _ := new(big.Int).SetString(s, 16)
+	return b
+}
+
+func randomLimbs(r *rand.Rand, n int) []uint64 {
+	v := make([]uint64, n)
+	for i := range v {
+		v[i] = r.Uint64()
+	}
+	return v
+}
+
+func TestBigAddSub(t *testing.T) {
+	outA, _, flags := ALUBigInt(ALU_OP_BIGADD, []uint64{^uint64(0), 0}, []uint64{1})
+	if outA[0] != 0 || outA[1] != 1 || flags != 0 {
+		t.Errorf("OP_BIGADD Expected 0 1, got %x %b", outA, flags)
+	}
+	outA, _, flags = ALUBigInt(ALU_OP_BIGADD, []uint64{0, 1 << 63}, []uint64{0, 1 << 63})
+	if flags != ALU_FLAGS_ZERO|ALU_FLAGS_CARRY|ALU_FLAGS_OVERFLOW {
+		t.Errorf("OP_BIGADD Expected ZERO|CARRY|OVERFLOW, got %x %b", outA, flags)
+	}
+	outA, _, flags = ALUBigInt(ALU_OP_BIGSUB, []uint64{0, 0}, []uint64{1})
+	if outA[0] != ^uint64(0) || outA[1] != ^uint64(0) || flags != ALU_FLAGS_NEGATIVE|ALU_FLAGS_CARRY {
+		t.Errorf("OP_BIGSUB Expected -1 with NEGATIVE|CARRY, got %x %b", outA, flags)
+	}
+	a := []uint64{5, 7}
+	outA, outB, flags := ALUBigInt(ALU_OP_BIGCMP, a, []uint64{5, 7})
+	if &outA[0] != &a[0] || outB[1] != 7 || flags != ALU_FLAGS_ZERO {
+		t.Errorf("OP_BIGCMP Expected ZERO and unchanged operands, got %b", flags)
+	}
+	_, _, flags = ALUBigInt(ALU_OP_BIGCMP, []uint64{5}, []uint64{6})
+	if !ALUCondition(flags, ALU_COND_ULT) || !ALUCondition(flags, ALU_COND_LT) {
+		t.Errorf("OP_BIGCMP Expected 5 < 6, got %b", flags)
+	}
+	// A shorter negative parmB is sign extended: 2^64 + -1 is 2^64-1, 5 - -1 is 6
+	outA, _, flags = ALUBigInt(ALU_OP_BIGADD, []uint64{0, 1}, []uint64{^uint64(0)})
+	if outA[0] != ^uint64(0) || outA[1] != 0 || flags&(ALU_FLAGS_NEGATIVE|ALU_FLAGS_OVERFLOW) != 0 {
+		t.Errorf("OP_BIGADD Expected 2^64-1, got %x %b", outA, flags)
+	}
+	outA, _, flags = ALUBigInt(ALU_OP_BIGSUB, []uint64{5, 0}, []uint64{^uint64(0)})
+	if outA[0] != 6 || outA[1] != 0 || flags&ALU_FLAGS_NEGATIVE != 0 {
+		t.Errorf("OP_BIGSUB Expected 6, got %x %b", outA, flags)
+	}
+	_, _, flags = ALUBigInt(ALU_OP_BIGCMP, []uint64{0, ^uint64(0)}, []uint64{^uint64(0)})
+	if !ALUCondition(flags, ALU_COND_LT) || flags&ALU_FLAGS_ZERO != 0 {
+		t.Errorf("OP_BIGCMP Expected -2^64 < -1, got %b", flags)
+	}
+	_, _, flags = ALUBigInt(ALU_OP_BIGADD, []uint64{1}, []uint64{1, 2})
+	if flags&ALU_FLAGS_INVALIDOP == 0 {
+		t.Errorf("OP_BIGADD Expected INVALIDOP when parmB is longer, got %b", flags)
+	}
+}
+
+func TestBigMulDivMod(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		a := randomLimbs(r, 1+r.Intn(32))
+		b := randomLimbs(r, 1+r.Intn(len(a)))
+		outA, _, flags := ALUBigInt(ALU_OP_BIGMUL, a, b)
+		want := new(big.Int).Mul(toBig(a), toBig(b))
+		if len(outA) != len(a)+len(b) || toBig(outA).Cmp(want) != 0 {
+			t.Errorf("OP_BIGMUL Expected %x, got %x", want, toBig(outA))
+		}
+		if (flags&ALU_FLAGS_CARRY != 0) != (want.BitLen() > 64*len(a)) {
+			t.Errorf("OP_BIGMUL Expected CARRY for a product of %d bits, got %b", want.BitLen(), flags)
+		}
+		q, rem, _ := ALUBigInt(ALU_OP_BIGDIVMOD, a, b)
+		wantQ, wantR := new(big.Int).QuoRem(toBig(a), toBig(b), new(big.Int))
+		if toBig(q).Cmp(wantQ) != 0 || toBig(rem).Cmp(wantR) != 0 || len(rem) != len(b) {
+			t.Errorf("OP_BIGDIVMOD Expected %x %x, got %x %x", wantQ, wantR, toBig(q), toBig(rem))
+		}
+	}
+	_, _, flags := ALUBigInt(ALU_OP_BIGDIVMOD, []uint64{1, 2}, []uint64{0})
+	if flags != ALU_FLAGS_ERROR|ALU_FLAGS_DIVIDEBYZERO {
+		t.Errorf("OP_BIGDIVMOD Expected DIVIDEBYZERO, got %b", flags)
+	}
+}
+
+func TestBigShifts(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < 200; i++ {
+		a := randomLimbs(r, 1+r.Intn(8))
+		width := uint(64 * len(a))
+		n := uint(r.Intn(int(width)))
+		mod := new(big.Int).Lsh(big.NewInt(1), width)
+
+		outA, _, _ := ALUBigInt(ALU_OP_BIGSHL, a, []uint64{uint64(n)})
+		want := new(big.Int).Lsh(toBig(a), n)
+		want.Mod(want, mod)
+		if toBig(outA).Cmp(want) != 0 {
+			t.Errorf("OP_BIGSHL Expected %x, got %x", want, toBig(outA))
+		}
+		outA, _, flags := ALUBigInt(ALU_OP_BIGSHRL, a, []uint64{uint64(n)})
+		want = new(big.Int).Rsh(toBig(a), n)
+		if toBig(outA).Cmp(want) != 0 {
+			t.Errorf("OP_BIGSHRL Expected %x, got %x", want, toBig(outA))
+		}
+		if n > 0 && (flags&ALU_FLAGS_CARRY != 0) != (toBig(a).Bit(int(n-1)) != 0) {
+			t.Errorf("OP_BIGSHRL Expected CARRY to be bit %d, got %b", n-1, flags)
+		}
+		// The arithmetic shift of a negative value, as two's complement
+		signed := toBig(a)
+		if a[len(a)-1]>>63 != 0 {
+			signed.Sub(signed, mod)
+		}
+		outA, _, _ = ALUBigInt(ALU_OP_BIGSHR, a, []uint64{uint64(n)})
+		want = new(big.Int).Rsh(signed, n)
+		want.Mod(want, mod)
+		if toBig(outA).Cmp(want) != 0 {
+			t.Errorf("OP_BIGSHR Expected %x, got %x", want, toBig(outA))
+		}
+	}
+	_, _, flags := ALUBigInt(ALU_OP_BIGSHL, []uint64{1}, []uint64{64})
+	if flags&ALU_FLAGS_INVALIDOP == 0 {
+		t.Errorf("OP_BIGSHL Expected INVALIDOP for a 64-bit shift of one limb, got %b", flags)
+	}
+	_, _, flags = ALUBigInt(ALU_OP_BIGSHL, []uint64{0, 1 << 63}, []uint64{1})
+	if flags != ALU_FLAGS_ZERO|ALU_FLAGS_CARRY {
+		t.Errorf("OP_BIGSHL Expected ZERO|CARRY, got %b", flags)
+	}
+}
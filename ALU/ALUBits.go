@@ -0,0 +1,161 @@
+package Onyx1ALU
+
+import "math/bits"
+
+// BitFieldSpec packs a bit-field offset and width into the parmB operand used by
+// ALU_OP_BFEXTINT64 and ALUBitFieldInsert. The offset is in bits 0-7, the width in bits 8-15.
+func BitFieldSpec(offset int, width int) int64 {
+	return int64(offset&0xFF) | int64(width&0xFF)<<8
+}
+
+func decodeBitFieldSpec(spec int64, width int) (offset int, fieldWidth int, ok bool) {
+	offset = int(spec & 0xFF)
+	fieldWidth = int((spec >> 8) & 0xFF)
+	if fieldWidth == 0 || offset+fieldWidth > width {
+		return 0, 0, false
+	}
+	return offset, fieldWidth, true
+}
+
+func invalidBitOp() (outA int64, outB int64, flags uint64) {
+	flags |= ALU_FLAGS_ERROR
+	flags |= ALU_FLAGS_INVALIDOP
+	return 0, 0, flags
+}
+
+// rotateWidth handles ROL/ROR and the through-carry RCL/RCR, which rotate a width+1 bit
+// value made of the operand and the incoming CARRY flag
+func rotateWidth(op int, width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	if parmB < 0 {
+		return invalidBitOp()
+	}
+	mask := widthMask(width)
+	v := uint64(parmA) & mask
+	top := uint(width - 1)
+	switch op {
+	case ALU_OP_ROLINT64, ALU_OP_RORINT64:
+		n := uint(parmB % int64(width))
+		if op == ALU_OP_RORINT64 {
+			n = (uint(width) - n) % uint(width)
+		}
+		if n != 0 {
+			v = ((v << n) | (v >> (uint(width) - n))) & mask
+		}
+		outA = truncateToWidth(int64(v), width)
+		flags |= resultFlags(outA)
+		// CARRY copies the bit that was rotated across the end of the operand
+		if parmB%int64(width) != 0 {
+			if op == ALU_OP_ROLINT64 && v&1 != 0 {
+				flags |= ALU_FLAGS_CARRY
+			}
+			if op == ALU_OP_RORINT64 && (v>>top)&1 != 0 {
+				flags |= ALU_FLAGS_CARRY
+			}
+		}
+		return outA, outB, flags
+	default:
+		carry := uint64(0)
+		if flagsIn&ALU_FLAGS_CARRY != 0 {
+			carry = 1
+		}
+		n := parmB % int64(width+1)
+		for i := int64(0); i < n; i++ {
+			if op == ALU_OP_RCLINT64 {
+				out := (v >> top) & 1
+				v = ((v << 1) | carry) & mask
+				carry = out
+			} else {
+				out := v & 1
+				v = (v >> 1) | (carry << top)
+				carry = out
+			}
+		}
+		outA = truncateToWidth(int64(v), width)
+		flags |= resultFlags(outA)
+		if carry != 0 {
+			flags |= ALU_FLAGS_CARRY
+		}
+		return outA, outB, flags
+	}
+}
+
+// countBitsWidth handles POPCNT/CLZ/CTZ. A zero operand gives a count equal to the
+// width and sets CARRY for CLZ and CTZ.
+func countBitsWidth(op int, width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	v := uint64(parmA) & widthMask(width)
+	switch op {
+	case ALU_OP_POPCNT64:
+		outA = int64(bits.OnesCount64(v))
+	case ALU_OP_CLZINT64:
+		outA = int64(bits.LeadingZeros64(v) - (64 - width))
+	case ALU_OP_CTZINT64:
+		outA = int64(bits.TrailingZeros64(v))
+		if v == 0 {
+			outA = int64(width)
+		}
+	}
+	if op != ALU_OP_POPCNT64 && v == 0 {
+		flags |= ALU_FLAGS_CARRY
+	}
+	if outA == 0 {
+		flags |= ALU_FLAGS_ZERO
+	}
+	return outA, outB, flags
+}
+
+// testBitWidth handles BT/BTS/BTR/BTC. The old value of the bit goes into CARRY, and
+// ZERO is set when that bit was clear.
+func testBitWidth(op int, width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	if parmB < 0 || parmB >= int64(width) {
+		return invalidBitOp()
+	}
+	bit := int64(1) << parmB
+	if parmA&bit != 0 {
+		flags |= ALU_FLAGS_CARRY
+	} else {
+		flags |= ALU_FLAGS_ZERO
+	}
+	outA = parmA
+	switch op {
+	case ALU_OP_BTSINT64:
+		outA = parmA | bit
+	case ALU_OP_BTRINT64:
+		outA = parmA &^ bit
+	case ALU_OP_BTCINT64:
+		outA = parmA ^ bit
+	}
+	outA = truncateToWidth(outA, width)
+	if outA < 0 {
+		flags |= ALU_FLAGS_NEGATIVE
+	}
+	return outA, outB, flags
+}
+
+// extractBitField returns the zero extended field of parmA described by the spec in parmB
+func extractBitField(width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	offset, fieldWidth, ok := decodeBitFieldSpec(parmB, width)
+	if !ok {
+		return invalidBitOp()
+	}
+	outA = int64((uint64(parmA) >> uint(offset)) & widthMask(fieldWidth))
+	if outA == 0 {
+		flags |= ALU_FLAGS_ZERO
+	}
+	return outA, outB, flags
+}
+
+// ALUBitFieldInsert is ALU_OP_BFINSINT64. It needs three operands so it can't go through
+// ALUInt64: the low bits of value replace the field of dest described by spec.
+// ALUOpInfo.Execute also runs it, with dest, value and spec in parmA, parmB and parmC.
+func ALUBitFieldInsert(dest int64, value int64, spec int64) (outA int64, flags uint64) {
+	offset, fieldWidth, ok := decodeBitFieldSpec(spec, ALU_WIDTH_64)
+	if !ok {
+		flags |= ALU_FLAGS_ERROR
+		flags |= ALU_FLAGS_INVALIDOP
+		return 0, flags
+	}
+	fieldMask := widthMask(fieldWidth) << uint(offset)
+	outA = int64((uint64(dest) &^ fieldMask) | ((uint64(value) << uint(offset)) & fieldMask))
+	flags |= resultFlags(outA)
+	return outA, flags
+}
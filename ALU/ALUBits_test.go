@@ -0,0 +1,96 @@
+package Onyx1ALU
+
+import "testing"
+
+func TestALURotate(t *testing.T) {
+	outA, outB, flags := ALUInt64(ALU_OP_ROLINT64, -0x8000_0000_0000_0000, 1)
+	if outA != 1 || flags&ALU_FLAGS_CARRY == 0 {
+		t.Errorf("OP_ROLINT64 Expected 1 CARRY, got %d %d %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUInt64(ALU_OP_RORINT64, 1, 1)
+	if outA != -0x8000_0000_0000_0000 || flags&ALU_FLAGS_CARRY == 0 {
+		t.Errorf("OP_RORINT64 Expected MinInt64 CARRY, got %d %d %b", outA, outB, flags)
+	}
+	i8A, i8B, flags := ALUInt8(ALU_OP_ROLINT64, 0x41, 4)
+	if i8A != 0x14 {
+		t.Errorf("OP_ROLINT8 Expected 0x14, got %x %x %b", i8A, i8B, flags)
+	}
+	outA, outB, flags = ALUInt64WithFlags(ALU_OP_RCLINT64, 0, 1, ALU_FLAGS_CARRY)
+	if outA != 1 || flags&ALU_FLAGS_CARRY != 0 {
+		t.Errorf("OP_RCLINT64 Expected 1, got %d %d %b", outA, outB, flags)
+	}
+	wA, wB, flags := ALUIntWidthWithFlags(ALU_OP_RCRINT64, ALU_WIDTH_8, 1, 1, 0)
+	if wA != 0 || flags&ALU_FLAGS_CARRY == 0 || flags&ALU_FLAGS_ZERO == 0 {
+		t.Errorf("OP_RCRINT8 Expected 0 CARRY ZERO, got %d %d %b", wA, wB, flags)
+	}
+	// Rotating a byte through carry nine times gives back the original value and carry
+	wA, wB, flags = ALUIntWidthWithFlags(ALU_OP_RCLINT64, ALU_WIDTH_8, 0x5A, 9, ALU_FLAGS_CARRY)
+	if wA != 0x5A || flags&ALU_FLAGS_CARRY == 0 {
+		t.Errorf("OP_RCLINT8 Expected 0x5A CARRY, got %x %d %b", wA, wB, flags)
+	}
+}
+
+func TestALUBitCount(t *testing.T) {
+	outA, outB, flags := ALUInt64(ALU_OP_POPCNT64, -1, 0)
+	if outA != 64 {
+		t.Errorf("OP_POPCNT64 Expected 64, got %d %d %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUInt64(ALU_OP_CLZINT64, 1, 0)
+	if outA != 63 {
+		t.Errorf("OP_CLZINT64 Expected 63, got %d %d %b", outA, outB, flags)
+	}
+	wA, wB, flags := ALUIntWidth(ALU_OP_CLZINT64, ALU_WIDTH_16, 0x0100, 0)
+	if wA != 7 {
+		t.Errorf("OP_CLZINT16 Expected 7, got %d %d %b", wA, wB, flags)
+	}
+	wA, wB, flags = ALUIntWidth(ALU_OP_CTZINT64, ALU_WIDTH_32, 0, 0)
+	if wA != 32 || flags&ALU_FLAGS_CARRY == 0 {
+		t.Errorf("OP_CTZINT32 Expected 32 CARRY, got %d %d %b", wA, wB, flags)
+	}
+	outA, outB, flags = ALUInt64(ALU_OP_CTZINT64, 0x50, 0)
+	if outA != 4 {
+		t.Errorf("OP_CTZINT64 Expected 4, got %d %d %b", outA, outB, flags)
+	}
+}
+
+func TestALUBitTest(t *testing.T) {
+	outA, outB, flags := ALUInt64(ALU_OP_BTINT64, 0x10, 4)
+	if outA != 0x10 || flags&ALU_FLAGS_CARRY == 0 || flags&ALU_FLAGS_ZERO != 0 {
+		t.Errorf("OP_BTINT64 Expected CARRY, got %x %d %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUInt64(ALU_OP_BTSINT64, 0, 63)
+	if outA != -0x8000_0000_0000_0000 || flags&ALU_FLAGS_ZERO == 0 {
+		t.Errorf("OP_BTSINT64 Expected MinInt64 ZERO, got %x %d %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUInt64(ALU_OP_BTRINT64, 0xFF, 0)
+	if outA != 0xFE || flags&ALU_FLAGS_CARRY == 0 {
+		t.Errorf("OP_BTRINT64 Expected 0xFE CARRY, got %x %d %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUInt64(ALU_OP_BTCINT64, 0xFF, 8)
+	if outA != 0x1FF {
+		t.Errorf("OP_BTCINT64 Expected 0x1FF, got %x %d %b", outA, outB, flags)
+	}
+	_, _, flags = ALUIntWidth(ALU_OP_BTINT64, ALU_WIDTH_8, 0, 8)
+	if flags&ALU_FLAGS_INVALIDOP == 0 {
+		t.Errorf("OP_BTINT8 Expected INVALIDOP, got %b", flags)
+	}
+}
+
+func TestALUBitField(t *testing.T) {
+	outA, outB, flags := ALUInt64(ALU_OP_BFEXTINT64, 0x0000_0ABC_0000_0000, BitFieldSpec(32, 12))
+	if outA != 0xABC {
+		t.Errorf("OP_BFEXTINT64 Expected 0xABC, got %x %d %b", outA, outB, flags)
+	}
+	_, _, flags = ALUInt64(ALU_OP_BFEXTINT64, 0, BitFieldSpec(60, 8))
+	if flags&ALU_FLAGS_INVALIDOP == 0 {
+		t.Errorf("OP_BFEXTINT64 Expected INVALIDOP, got %b", flags)
+	}
+	outA, flags = ALUBitFieldInsert(-1, 0, BitFieldSpec(8, 8))
+	if outA != -0xFF01 {
+		t.Errorf("OP_BFINSINT64 Expected 0xFFFFFFFFFFFF00FF, got %x %b", outA, flags)
+	}
+	outA, flags = ALUBitFieldInsert(0, 0x1FF, BitFieldSpec(4, 4))
+	if outA != 0xF0 {
+		t.Errorf("OP_BFINSINT64 Expected 0xF0, got %x %b", outA, flags)
+	}
+}
@@ -0,0 +1,79 @@
+package Onyx1ALU
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+)
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ALUChecksum folds the low width bits of data into a running checksum, the data bytes
+// taken least significant first as they sit in memory. The CRC32 ops work like
+// crc32.Update, starting from 0 and including the final inversion, so the output is
+// always the CRC of everything fed so far. CRC16CCITT is the MSB-first 0x1021
+// polynomial with no inversion; start it from 0xFFFF for CRC-16/CCITT-FALSE.
+func ALUChecksum(op int, width int, crc uint64, data uint64) (out uint64, flags uint64) {
+	if !isValidWidth(width) {
+		return 0, ALU_FLAGS_ERROR | ALU_FLAGS_INVALIDOP
+	}
+	info, ok := aluOps[op]
+	if !ok || info.checksumOp == nil {
+		return 0, ALU_FLAGS_ERROR | ALU_FLAGS_INVALIDOP
+	}
+	var p [8]byte
+	binary.LittleEndian.PutUint64(p[:], data)
+	out = info.checksumOp(crc, p[:width/8])
+	if out == 0 {
+		flags |= ALU_FLAGS_ZERO
+	}
+	return out, flags
+}
+
+func checksumCRC32(crc uint64, p []byte) uint64 {
+	return uint64(crc32.Update(uint32(crc), crc32.IEEETable, p))
+}
+
+func checksumCRC32C(crc uint64, p []byte) uint64 {
+	return uint64(crc32.Update(uint32(crc), castagnoliTable, p))
+}
+
+func checksumCRC16CCITT(crc uint64, p []byte) uint64 {
+	c := uint16(crc)
+	for _, b := range p {
+		c ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if c&0x8000 != 0 {
+				c = c<<1 ^ 0x1021
+			} else {
+				c <<= 1
+			}
+		}
+	}
+	return uint64(c)
+}
+
+// intClmul is the carry-less multiply. Like UMUL the double-width product comes back as
+// high half in outA, low half in outB.
+func intClmul(width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64) {
+	mask := widthMask(width)
+	a := uint64(parmA) & mask
+	b := uint64(parmB) & mask
+	var hi, lo uint64
+	for i := uint(0); i < uint(width); i++ {
+		if (b>>i)&1 == 0 {
+			continue
+		}
+		lo ^= a << i
+		if i > 0 {
+			hi ^= a >> (64 - i)
+		}
+	}
+	if width < ALU_WIDTH_64 {
+		hi = lo >> uint(width)
+		lo &= mask
+	}
+	if hi == 0 && lo == 0 {
+		flags |= ALU_FLAGS_ZERO
+	}
+	return int64(hi), int64(lo), flags
+}
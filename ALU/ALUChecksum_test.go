@@ -0,0 +1,135 @@
+package Onyx1ALU
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"testing"
+)
+
+// checkData is "123456789", the standard CRC check input, as a quad and a byte
+const checkDataQuad = 0x3837363534333231
+const checkDataByte = 0x39
+
+func TestCRC32(t *testing.T) {
+	crc, _ := ALUChecksum(ALU_OP_CRC32, ALU_WIDTH_64, 0, checkDataQuad)
+	crc, _ = ALUChecksum(ALU_OP_CRC32, ALU_WIDTH_8, crc, checkDataByte)
+	if crc != 0xCBF43926 {
+		t.Errorf("OP_CRC32 Expected cbf43926, got %x", crc)
+	}
+	crc, _ = ALUChecksum(ALU_OP_CRC32C, ALU_WIDTH_64, 0, checkDataQuad)
+	crc, _ = ALUChecksum(ALU_OP_CRC32C, ALU_WIDTH_8, crc, checkDataByte)
+	if crc != 0xE3069283 {
+		t.Errorf("OP_CRC32C Expected e3069283, got %x", crc)
+	}
+}
+
+func TestCRC16(t *testing.T) {
+	crc, _ := ALUChecksum(ALU_OP_CRC16CCITT, ALU_WIDTH_32, 0xFFFF, 0x34333231)
+	crc, _ = ALUChecksum(ALU_OP_CRC16CCITT, ALU_WIDTH_32, crc, 0x38373635)
+	crc, _ = ALUChecksum(ALU_OP_CRC16CCITT, ALU_WIDTH_8, crc, checkDataByte)
+	if crc != 0x29B1 {
+		t.Errorf("OP_CRC16CCITT Expected 29b1, got %x", crc)
+	}
+	_, flags := ALUChecksum(ALU_OP_CRC16CCITT, 12, 0, 0)
+	if flags&ALU_FLAGS_INVALIDOP == 0 {
+		t.Errorf("OP_CRC16CCITT Expected INVALIDOP for width 12, got %b", flags)
+	}
+}
+
+func TestCLMUL(t *testing.T) {
+	outA, outB, _ := ALUInt64(ALU_OP_CLMUL64, 3, 3)
+	if outA != 0 || outB != 5 {
+		t.Errorf("OP_CLMUL64 Expected 0 5, got %d %d", outA, outB)
+	}
+	outA, outB, _ = ALUInt64(ALU_OP_CLMUL64, -1<<63, 6)
+	if outA != 3 || outB != 0 {
+		t.Errorf("OP_CLMUL64 Expected 3 0, got %d %d", outA, outB)
+	}
+	outA, outB, _ = ALUIntWidth(ALU_OP_CLMUL64, ALU_WIDTH_8, 0x80, 0x81)
+	if outA != 0x40 || outB != 0x80 {
+		t.Errorf("OP_CLMUL8 Expected 40 80, got %x %x", outA, outB)
+	}
+}
+
+// expandKey128 is the AES-128 key schedule, kept here so the test doesn't share code with the ALU
+func expandKey128(key []byte) (roundKeys [11][16]byte) {
+	sub := func(b byte) byte {
+		// The S-box by definition: the GF(2^8) inverse followed by the affine transform
+		var inv byte
+		for x := 1; x < 256 && b != 0; x++ {
+			if gfMul(b, byte(x)) == 1 {
+				inv = byte(x)
+			}
+		}
+		return inv ^ rotl8(inv, 1) ^ rotl8(inv, 2) ^ rotl8(inv, 3) ^ rotl8(inv, 4) ^ 0x63
+	}
+	w := make([]byte, 176)
+	copy(w, key)
+	rcon := byte(1)
+	for i := 16; i < 176; i += 4 {
+		temp := []byte{w[i-4], w[i-3], w[i-2], w[i-1]}
+		if i%16 == 0 {
+			temp = []byte{sub(temp[1]) ^ rcon, sub(temp[2]), sub(temp[3]), sub(temp[0])}
+			rcon = gfMul(rcon, 2)
+		}
+		for j := 0; j < 4; j++ {
+			w[i+j] = w[i-16+j] ^ temp[j]
+		}
+	}
+	for r := range roundKeys {
+		copy(roundKeys[r][:], w[16*r:])
+	}
+	return roundKeys
+}
+
+func halves(b [16]byte) (hi uint64, lo uint64) {
+	return binary.LittleEndian.Uint64(b[8:]), binary.LittleEndian.Uint64(b[0:])
+}
+
+func TestAESRounds(t *testing.T) {
+	key := []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
+	plain := []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}
+	want := make([]byte, 16)
+	block, _ := aes.NewCipher(key)
+	block.Encrypt(want, plain)
+
+	roundKeys := expandKey128(key)
+	var state [16]byte
+	copy(state[:], plain)
+	sHi, sLo := halves(state)
+	kHi, kLo := halves(roundKeys[0])
+	sHi, sLo = sHi^kHi, sLo^kLo
+	for r := 1; r <= 10; r++ {
+		op := ALU_OP_AESENC
+		if r == 10 {
+			op = ALU_OP_AESENCLAST
+		}
+		kHi, kLo = halves(roundKeys[r])
+		sHi, sLo, _ = ALUAESRound(op, sHi, sLo, kHi, kLo)
+	}
+	got := make([]byte, 16)
+	binary.LittleEndian.PutUint64(got[0:], sLo)
+	binary.LittleEndian.PutUint64(got[8:], sHi)
+	if !bytes.Equal(got, want) {
+		t.Errorf("OP_AESENC Expected %x, got %x", want, got)
+	}
+
+	// Decrypt with the equivalent inverse cipher, the middle round keys going through AESIMC
+	kHi, kLo = halves(roundKeys[10])
+	sHi, sLo = sHi^kHi, sLo^kLo
+	for r := 9; r >= 0; r-- {
+		kHi, kLo = halves(roundKeys[r])
+		op := ALU_OP_AESDECLAST
+		if r > 0 {
+			op = ALU_OP_AESDEC
+			kHi, kLo, _ = ALUAESRound(ALU_OP_AESIMC, 0, 0, kHi, kLo)
+		}
+		sHi, sLo, _ = ALUAESRound(op, sHi, sLo, kHi, kLo)
+	}
+	binary.LittleEndian.PutUint64(got[0:], sLo)
+	binary.LittleEndian.PutUint64(got[8:], sHi)
+	if !bytes.Equal(got, plain) {
+		t.Errorf("OP_AESDEC Expected %x, got %x", plain, got)
+	}
+}
@@ -0,0 +1,101 @@
+package Onyx1ALU
+
+// Condition codes tested against the flags word from ALUInt64/ALUFloat64.
+// Signed conditions use NEGATIVE and OVERFLOW, unsigned ones use CARRY as the borrow
+// from a subtract or compare. FCMP64 sets the flags the same way, so the ordered
+// float compares use the signed conditions. Any UNORDERED result fails every
+// ordered condition and satisfies NE.
+const (
+	ALU_COND_AL  = 0
+	ALU_COND_EQ  = 1
+	ALU_COND_NE  = 2
+	ALU_COND_LT  = 3
+	ALU_COND_LE  = 4
+	ALU_COND_GT  = 5
+	ALU_COND_GE  = 6
+	ALU_COND_ULT = 7
+	ALU_COND_ULE = 8
+	ALU_COND_UGT = 9
+	ALU_COND_UGE = 10
+	ALU_COND_CS  = 11
+	ALU_COND_CC  = 12
+	ALU_COND_VS  = 13
+	ALU_COND_VC  = 14
+	ALU_COND_UN  = 15
+	ALU_COND_ORD = 16
+)
+
+var ALUConditionNames = []string{
+	"AL",
+	"EQ",
+	"NE",
+	"LT",
+	"LE",
+	"GT",
+	"GE",
+	"ULT",
+	"ULE",
+	"UGT",
+	"UGE",
+	"CS",
+	"CC",
+	"VS",
+	"VC",
+	"UN",
+	"ORD",
+}
+
+func ALUConditionValid(cond int) bool {
+	return cond >= 0 && cond < len(ALUConditionNames)
+}
+
+// ALUCondition reports whether the condition holds for the flags. Unknown conditions never hold.
+func ALUCondition(flags uint64, cond int) bool {
+	z := flags&ALU_FLAGS_ZERO != 0
+	n := flags&ALU_FLAGS_NEGATIVE != 0
+	c := flags&ALU_FLAGS_CARRY != 0
+	v := flags&ALU_FLAGS_OVERFLOW != 0
+	unordered := flags&ALU_FLAGS_UNORDERED != 0
+	switch cond {
+	case ALU_COND_AL:
+		return true
+	case ALU_COND_NE:
+		return unordered || !z
+	case ALU_COND_CS:
+		return c
+	case ALU_COND_CC:
+		return !c
+	case ALU_COND_VS:
+		return v
+	case ALU_COND_VC:
+		return !v
+	case ALU_COND_UN:
+		return unordered
+	case ALU_COND_ORD:
+		return !unordered
+	}
+	if unordered {
+		return false
+	}
+	switch cond {
+	case ALU_COND_EQ:
+		return z
+	case ALU_COND_LT:
+		return n != v
+	case ALU_COND_LE:
+		return z || n != v
+	case ALU_COND_GT:
+		return !z && n == v
+	case ALU_COND_GE:
+		return n == v
+	case ALU_COND_ULT:
+		return c
+	case ALU_COND_ULE:
+		return c || z
+	case ALU_COND_UGT:
+		return !c && !z
+	case ALU_COND_UGE:
+		return !c
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package Onyx1ALU
+
+import (
+	"math"
+	"testing"
+)
+
+func TestALUCondition(t *testing.T) {
+	cases := []struct {
+		a, b int64
+		cond int
+		want bool
+	}{
+		{1, 2, ALU_COND_LT, true},
+		{-1, 2, ALU_COND_LT, true},
+		{-1, 2, ALU_COND_ULT, false},
+		{-1, 2, ALU_COND_UGT, true},
+		{2, 2, ALU_COND_LE, true},
+		{2, 2, ALU_COND_ULE, true},
+		{2, 2, ALU_COND_GT, false},
+		{2, 2, ALU_COND_GE, true},
+		{2, 2, ALU_COND_UGE, true},
+		{math.MinInt64, 1, ALU_COND_LT, true},
+		{math.MaxInt64, -1, ALU_COND_GT, true},
+		{math.MaxInt64, -1, ALU_COND_VS, true},
+		{3, 4, ALU_COND_NE, true},
+		{3, 3, ALU_COND_EQ, true},
+		{0, 1, ALU_COND_CS, true},
+		{1, 0, ALU_COND_CC, true},
+	}
+	for _, c := range cases {
+		_, _, flags := ALUInt64(ALU_OP_CMPINT64, c.a, c.b)
+		if ALUCondition(flags, c.cond) != c.want {
+			t.Errorf("ALUCondition %s on CMP %d, %d Expected %v, got %v (%b)",
+				ALUConditionNames[c.cond], c.a, c.b, c.want, !c.want, flags)
+		}
+	}
+	_, _, flags := ALUFloat64(ALU_OP_FCMP64, -1.5, 2.0)
+	if !ALUCondition(flags, ALU_COND_LT) || ALUCondition(flags, ALU_COND_GE) {
+		t.Errorf("ALUCondition Expected -1.5 < 2.0, got %b", flags)
+	}
+	_, _, flags = ALUFloat64(ALU_OP_FCMP64, math.NaN(), 2.0)
+	for _, cond := range []int{ALU_COND_EQ, ALU_COND_LT, ALU_COND_LE, ALU_COND_GT, ALU_COND_GE, ALU_COND_ORD} {
+		if ALUCondition(flags, cond) {
+			t.Errorf("ALUCondition %s Expected false for NaN, got true", ALUConditionNames[cond])
+		}
+	}
+	if !ALUCondition(flags, ALU_COND_NE) || !ALUCondition(flags, ALU_COND_UN) {
+		t.Errorf("ALUCondition Expected NE and UN for NaN, got %b", flags)
+	}
+	if ALUConditionValid(len(ALUConditionNames)) || ALUCondition(0, 99) {
+		t.Errorf("ALUCondition Expected unknown condition to be invalid")
+	}
+}
@@ -0,0 +1,108 @@
+package Onyx1ALU
+
+import "math"
+
+// ALUConvert performs the conversion ops on raw 64-bit register values. float64 values
+// are passed as their IEEE bits, float32 values as their bits in the low 32 bits, and
+// int32 values sign extended. Results out of range of the integer type raise FPINVALID
+// and OVERFLOW and return the most negative integer. A NaN source returns it too but
+// raises FPINVALID alone, and neither case raises FPINEXACT.
+func ALUConvert(op int, parm uint64, control uint64) (out uint64, flags uint64) {
+	info, ok := aluOps[op]
+	if !ok || info.convertOp == nil {
+		return 0, ALU_FLAGS_INVALIDOP | ALU_FLAGS_ERROR
+	}
+	return info.convertOp(parm, control)
+}
+
+func convertI64F64(parm uint64, control uint64) (out uint64, flags uint64) {
+	v := int64(parm)
+	f := float64(v)
+	// float64(MaxInt64) rounds up to 2^63, which can't be converted back
+	errSign := -1
+	if f < 0x1p63 {
+		errSign = signOf(float64(v - int64(f)))
+	}
+	f, flags = roundResult(f, errSign, control, math.Nextafter)
+	return math.Float64bits(f), flags | floatResultFlags(f)
+}
+
+func convertI32F32(parm uint64, control uint64) (out uint64, flags uint64) {
+	v := int64(int32(parm))
+	f := float32(v)
+	f, flags = roundResult(f, signOf(float64(v-int64(f))), control, math.Nextafter32)
+	return uint64(math.Float32bits(f)), flags | floatResultFlags(float64(f))
+}
+
+func convertF64I64(parm uint64, control uint64) (out uint64, flags uint64) {
+	v, flags := floatToInt(math.Float64frombits(parm), control, math.MinInt64, math.MaxInt64)
+	return uint64(v), flags
+}
+
+func convertF32I32(parm uint64, control uint64) (out uint64, flags uint64) {
+	v, flags := floatToInt(float64(math.Float32frombits(uint32(parm))), control, math.MinInt32, math.MaxInt32)
+	return uint64(v), flags
+}
+
+// convertFixedMode converts float64 to int64 with a fixed rounding mode, for FTRUNC,
+// FFLOOR and FCEIL
+func convertFixedMode(mode uint64) convertOpFunc {
+	return func(parm uint64, control uint64) (out uint64, flags uint64) {
+		v, flags := floatToInt(math.Float64frombits(parm), mode, math.MinInt64, math.MaxInt64)
+		return uint64(v), flags
+	}
+}
+
+// convertRound rounds halfway cases away from zero like C's round(), the control word
+// rounding mode is what CVTF64I64 is for
+func convertRound(parm uint64, control uint64) (out uint64, flags uint64) {
+	f := math.Float64frombits(parm)
+	v, flags := floatToInt(math.Round(f), ALU_FPCW_ROUND_ZERO, math.MinInt64, math.MaxInt64)
+	if flags&ALU_FLAGS_FPINVALID == 0 && math.Round(f) != f {
+		flags |= ALU_FLAGS_FPINEXACT
+	}
+	return uint64(v), flags
+}
+
+func convertF64F32(parm uint64, control uint64) (out uint64, flags uint64) {
+	f64 := math.Float64frombits(parm)
+	if math.IsNaN(f64) || math.IsInf(f64, 0) {
+		f := float32(f64)
+		return uint64(math.Float32bits(f)), floatResultFlags(f64)
+	}
+	f, flags := roundFloat64ToFloat32(f64, 0, control, func() float32 { return float32(f64) })
+	return uint64(math.Float32bits(f)), flags | floatResultFlags(float64(f))
+}
+
+func convertF32F64(parm uint64, control uint64) (out uint64, flags uint64) {
+	f := float64(math.Float32frombits(uint32(parm)))
+	return math.Float64bits(f), floatResultFlags(f)
+}
+
+// floatToInt rounds f to an integer under the rounding mode and checks it fits in [lo, hi]
+func floatToInt(f float64, control uint64, lo int64, hi int64) (out int64, flags uint64) {
+	if math.IsNaN(f) {
+		return lo, ALU_FLAGS_FPINVALID
+	}
+	var r float64
+	switch control & ALU_FPCW_ROUND_MASK {
+	case ALU_FPCW_ROUND_ZERO:
+		r = math.Trunc(f)
+	case ALU_FPCW_ROUND_UP:
+		r = math.Ceil(f)
+	case ALU_FPCW_ROUND_DOWN:
+		r = math.Floor(f)
+	default:
+		r = math.RoundToEven(f)
+	}
+	// float64(hi) may round up past hi, so the top of the range is checked as >=
+	if r < float64(lo) || r >= float64(hi)+1 {
+		return lo, ALU_FLAGS_FPINVALID | ALU_FLAGS_OVERFLOW
+	}
+	if r != f {
+		flags |= ALU_FLAGS_FPINEXACT
+	}
+	out = int64(r)
+	flags |= resultFlags(out)
+	return out, flags
+}
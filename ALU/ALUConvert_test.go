@@ -0,0 +1,101 @@
+package Onyx1ALU
+
+import (
+	"math"
+	"testing"
+)
+
+func TestALUConvert(t *testing.T) {
+	out, flags := ALUConvert(ALU_OP_CVTI64F64, uint64(1<<53+1), ALU_FPCW_ROUND_NEAREST)
+	if math.Float64frombits(out) != 0x1p53 || flags&ALU_FLAGS_FPINEXACT == 0 {
+		t.Errorf("OP_CVTI64F64 Expected 2^53 INEXACT, got %v %b", math.Float64frombits(out), flags)
+	}
+	out, flags = ALUConvert(ALU_OP_CVTI64F64, uint64(1<<53+1), ALU_FPCW_ROUND_UP)
+	if math.Float64frombits(out) != 0x1p53+2 {
+		t.Errorf("OP_CVTI64F64 Expected 2^53+2, got %v %b", math.Float64frombits(out), flags)
+	}
+	out, flags = ALUConvert(ALU_OP_CVTI64F64, uint64(math.MaxInt64), ALU_FPCW_ROUND_ZERO)
+	if math.Float64frombits(out) != math.Nextafter(0x1p63, 0) {
+		t.Errorf("OP_CVTI64F64 Expected largest float below 2^63, got %v %b", math.Float64frombits(out), flags)
+	}
+	minus3 := int64(-3)
+	out, flags = ALUConvert(ALU_OP_CVTI32F32, uint64(minus3), ALU_FPCW_ROUND_NEAREST)
+	if math.Float32frombits(uint32(out)) != -3 || flags&ALU_FLAGS_NEGATIVE == 0 {
+		t.Errorf("OP_CVTI32F32 Expected -3, got %v %b", math.Float32frombits(uint32(out)), flags)
+	}
+	out, flags = ALUConvert(ALU_OP_CVTF64I64, math.Float64bits(-2.5), ALU_FPCW_ROUND_NEAREST)
+	if int64(out) != -2 || flags&ALU_FLAGS_FPINEXACT == 0 {
+		t.Errorf("OP_CVTF64I64 Expected -2 INEXACT, got %d %b", int64(out), flags)
+	}
+	out, flags = ALUConvert(ALU_OP_CVTF64I64, math.Float64bits(-2.5), ALU_FPCW_ROUND_DOWN)
+	if int64(out) != -3 {
+		t.Errorf("OP_CVTF64I64 Expected -3, got %d %b", int64(out), flags)
+	}
+	out, flags = ALUConvert(ALU_OP_CVTF64I64, math.Float64bits(0x1p63), ALU_FPCW_ROUND_NEAREST)
+	if int64(out) != math.MinInt64 || flags&ALU_FLAGS_FPINVALID == 0 || flags&ALU_FLAGS_OVERFLOW == 0 {
+		t.Errorf("OP_CVTF64I64 Expected INVALID OVERFLOW, got %d %b", int64(out), flags)
+	}
+	out, flags = ALUConvert(ALU_OP_CVTF64I64, math.Float64bits(math.NaN()), ALU_FPCW_ROUND_NEAREST)
+	if flags&ALU_FLAGS_FPINVALID == 0 {
+		t.Errorf("OP_CVTF64I64 Expected INVALID for NaN, got %d %b", int64(out), flags)
+	}
+	out, flags = ALUConvert(ALU_OP_CVTF32I32, uint64(math.Float32bits(3e9)), ALU_FPCW_ROUND_NEAREST)
+	if flags&ALU_FLAGS_FPINVALID == 0 {
+		t.Errorf("OP_CVTF32I32 Expected INVALID, got %d %b", int64(out), flags)
+	}
+	out, flags = ALUConvert(ALU_OP_CVTF32I32, uint64(math.Float32bits(-7)), ALU_FPCW_ROUND_NEAREST)
+	if int64(out) != -7 {
+		t.Errorf("OP_CVTF32I32 Expected -7, got %d %b", int64(out), flags)
+	}
+	out, flags = ALUConvert(ALU_OP_CVTF64F32, math.Float64bits(0.1), ALU_FPCW_ROUND_NEAREST)
+	if math.Float32frombits(uint32(out)) != float32(0.1) || flags&ALU_FLAGS_FPINEXACT == 0 {
+		t.Errorf("OP_CVTF64F32 Expected 0.1 INEXACT, got %v %b", math.Float32frombits(uint32(out)), flags)
+	}
+	out, flags = ALUConvert(ALU_OP_CVTF64F32, math.Float64bits(1e300), ALU_FPCW_ROUND_NEAREST)
+	if !math.IsInf(float64(math.Float32frombits(uint32(out))), 1) || flags&ALU_FLAGS_FPOVERFLOW == 0 {
+		t.Errorf("OP_CVTF64F32 Expected +Inf OVERFLOW, got %v %b", math.Float32frombits(uint32(out)), flags)
+	}
+	out, flags = ALUConvert(ALU_OP_CVTF32F64, uint64(math.Float32bits(0.5)), ALU_FPCW_ROUND_NEAREST)
+	if math.Float64frombits(out) != 0.5 || flags&ALU_FLAGS_FPINEXACT != 0 {
+		t.Errorf("OP_CVTF32F64 Expected 0.5, got %v %b", math.Float64frombits(out), flags)
+	}
+	// NaN is invalid but not an overflow, and saturated results are never inexact
+	for _, c := range []struct {
+		op    int
+		in    uint64
+		flags uint64
+	}{
+		{ALU_OP_CVTF64I64, math.Float64bits(math.NaN()), ALU_FLAGS_FPINVALID},
+		{ALU_OP_CVTF32I32, uint64(math.Float32bits(float32(math.NaN()))), ALU_FLAGS_FPINVALID},
+		{ALU_OP_FTRUNC64, math.Float64bits(math.NaN()), ALU_FLAGS_FPINVALID},
+		{ALU_OP_FROUND64, math.Float64bits(math.NaN()), ALU_FLAGS_FPINVALID},
+		{ALU_OP_FROUND64, math.Float64bits(-1e300), ALU_FLAGS_FPINVALID | ALU_FLAGS_OVERFLOW},
+		{ALU_OP_FROUND64, math.Float64bits(math.Inf(1)), ALU_FLAGS_FPINVALID | ALU_FLAGS_OVERFLOW},
+		{ALU_OP_CVTF32I32, uint64(math.Float32bits(-3e9)), ALU_FLAGS_FPINVALID | ALU_FLAGS_OVERFLOW},
+		{ALU_OP_FCEIL64, math.Float64bits(0x1p63), ALU_FLAGS_FPINVALID | ALU_FLAGS_OVERFLOW},
+	} {
+		out, flags = ALUConvert(c.op, c.in, ALU_FPCW_ROUND_NEAREST)
+		if flags != c.flags {
+			t.Errorf("Op %x Expected flags %b, got %b", c.op, c.flags, flags)
+		}
+		if c.op == ALU_OP_CVTF32I32 && int64(out) != math.MinInt32 || c.op != ALU_OP_CVTF32I32 && int64(out) != math.MinInt64 {
+			t.Errorf("Op %x Expected the most negative integer, got %d", c.op, int64(out))
+		}
+	}
+	for _, c := range []struct {
+		op   int
+		in   float64
+		want int64
+	}{
+		{ALU_OP_FTRUNC64, -2.7, -2},
+		{ALU_OP_FFLOOR64, -2.2, -3},
+		{ALU_OP_FCEIL64, 2.2, 3},
+		{ALU_OP_FROUND64, 2.5, 3},
+		{ALU_OP_FROUND64, -2.5, -3},
+	} {
+		out, flags = ALUConvert(c.op, math.Float64bits(c.in), ALU_FPCW_ROUND_NEAREST)
+		if int64(out) != c.want || flags&ALU_FLAGS_FPINEXACT == 0 {
+			t.Errorf("Op %x Expected %d from %v, got %d %b", c.op, c.want, c.in, int64(out), flags)
+		}
+	}
+}
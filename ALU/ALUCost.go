@@ -0,0 +1,105 @@
+package Onyx1ALU
+
+import (
+	"GolangCPUParts/Configuration"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// CPUDescriptor parameter keys read by ALUCostModel_Initialize. An op is set with
+// "alu.cycles.<MNEMONIC>", all ops of one kind with "alu.cycles.kind.<KIND>" using the
+// names in ALUKindNames, and "alu.cycles.scale" multiplies the registry defaults.
+const (
+	ALU_PARAM_CYCLES       = "alu.cycles."
+	ALU_PARAM_CYCLES_KIND  = "alu.cycles.kind."
+	ALU_PARAM_CYCLES_SCALE = "alu.cycles.scale"
+)
+
+// ALUCostModel holds the cycle cost of every ALU op for one machine profile, and the
+// number of cycles charged against it so far
+type ALUCostModel struct {
+	cycles  map[int]uint64
+	elapsed uint64
+}
+
+// ALUCostModel_Initialize builds the cost model for a CPU. Ops keep their registry cost
+// unless the descriptor's Parameters override it, a mnemonic taking precedence over a
+// kind, and a kind over the scaled default.
+func ALUCostModel_Initialize(cpu Configuration.CPUDescriptor) (*ALUCostModel, error) {
+	scale := uint64(1)
+	kinds := map[int]uint64{}
+	ops := map[int]uint64{}
+	for k, v := range cpu.Parameters {
+		if !strings.HasPrefix(k, ALU_PARAM_CYCLES) {
+			continue
+		}
+		n, err := strconv.ParseUint(v, 0, 64)
+		if err != nil {
+			return nil, errors.New("Invalid cycle count " + v + " for " + k)
+		}
+		switch {
+		case k == ALU_PARAM_CYCLES_SCALE:
+			scale = n
+		case strings.HasPrefix(k, ALU_PARAM_CYCLES_KIND):
+			kind := aluKindByName(strings.TrimPrefix(k, ALU_PARAM_CYCLES_KIND))
+			if kind == 0 {
+				return nil, errors.New("Unknown ALU kind in " + k)
+			}
+			kinds[kind] = n
+		default:
+			info, ok := ALULookupMnemonic(strings.TrimPrefix(k, ALU_PARAM_CYCLES))
+			if !ok {
+				return nil, errors.New("Unknown ALU mnemonic in " + k)
+			}
+			ops[info.Op] = n
+		}
+	}
+	cm := &ALUCostModel{cycles: make(map[int]uint64, len(aluOps))}
+	for op, info := range aluOps {
+		cm.cycles[op] = info.Cycles * scale
+		if n, ok := kinds[info.Kind]; ok {
+			cm.cycles[op] = n
+		}
+		if n, ok := ops[op]; ok {
+			cm.cycles[op] = n
+		}
+	}
+	return cm, nil
+}
+
+func aluKindByName(name string) int {
+	for i, v := range ALUKindNames {
+		if i > 0 && strings.EqualFold(v, name) {
+			return i
+		}
+	}
+	return 0
+}
+
+// Cost returns the cycles op takes, 0 for an unknown op
+func (cm *ALUCostModel) Cost(op int) uint64 {
+	return cm.cycles[op]
+}
+
+// Charge advances the cycle count by the cost of op and returns that cost
+func (cm *ALUCostModel) Charge(op int) uint64 {
+	c := cm.cycles[op]
+	cm.elapsed += c
+	return c
+}
+
+// Advance adds n cycles for work outside the ALU, such as a load or a jump
+func (cm *ALUCostModel) Advance(n uint64) {
+	cm.elapsed += n
+}
+
+// Elapsed returns the cycles charged since the model was built or last reset
+func (cm *ALUCostModel) Elapsed() uint64 {
+	return cm.elapsed
+}
+
+// Reset zeroes the cycle count, keeping the costs
+func (cm *ALUCostModel) Reset() {
+	cm.elapsed = 0
+}
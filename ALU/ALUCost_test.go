@@ -0,0 +1,72 @@
+package Onyx1ALU
+
+import (
+	"GolangCPUParts/Configuration"
+	"testing"
+)
+
+func TestCostModelDefaults(t *testing.T) {
+	cm, err := ALUCostModel_Initialize(Configuration.CPUDescriptor{})
+	if err != nil {
+		t.Fatalf("ALUCostModel Expected no error, got %s", err)
+	}
+	if cm.Cost(ALU_OP_ADDINT64) != 1 || cm.Cost(ALU_OP_DIVINT64) != 40 || cm.Cost(ALU_OP_FSIN64) != 100 {
+		t.Errorf("ALUCostModel Expected the registry costs, got %d %d %d",
+			cm.Cost(ALU_OP_ADDINT64), cm.Cost(ALU_OP_DIVINT64), cm.Cost(ALU_OP_FSIN64))
+	}
+	cm.Charge(ALU_OP_DIVINT64)
+	cm.Charge(ALU_OP_ADDINT64)
+	cm.Advance(2)
+	if cm.Elapsed() != 43 {
+		t.Errorf("ALUCostModel Expected 43 cycles elapsed, got %d", cm.Elapsed())
+	}
+	cm.Reset()
+	if cm.Charge(-1) != 0 || cm.Elapsed() != 0 {
+		t.Errorf("ALUCostModel Expected an unknown op to cost nothing, got %d", cm.Elapsed())
+	}
+}
+
+func TestCostModelParameters(t *testing.T) {
+	cm, err := ALUCostModel_Initialize(Configuration.CPUDescriptor{Parameters: map[string]string{
+		"alu.cycles.scale":        "3",
+		"alu.cycles.kind.float64": "200",
+		"alu.cycles.fsqrt":        "0x50",
+		"serial":                  "ignored",
+	}})
+	if err != nil {
+		t.Fatalf("ALUCostModel Expected no error, got %s", err)
+	}
+	if cm.Cost(ALU_OP_MULTINT64) != 12 || cm.Cost(ALU_OP_FSIN64) != 200 || cm.Cost(ALU_OP_FSQRT64) != 80 {
+		t.Errorf("ALUCostModel Expected 12 200 80, got %d %d %d",
+			cm.Cost(ALU_OP_MULTINT64), cm.Cost(ALU_OP_FSIN64), cm.Cost(ALU_OP_FSQRT64))
+	}
+	for _, params := range []map[string]string{
+		{"alu.cycles.DIV": "slow"},
+		{"alu.cycles.NOSUCHOP": "1"},
+		{"alu.cycles.kind.NOSUCHKIND": "1"},
+	} {
+		_, err = ALUCostModel_Initialize(Configuration.CPUDescriptor{Parameters: params})
+		if err == nil {
+			t.Errorf("ALUCostModel Expected an error for %v", params)
+		}
+	}
+}
+
+func TestCostModelProfiles(t *testing.T) {
+	s, _ := Configuration.MockConfig()
+	cfg, err := Configuration.LoadConfiguration(s)
+	if err != nil {
+		t.Fatalf("ALUCostModel Expected the mock configuration to load, got %s", err)
+	}
+	for _, v := range cfg.Configuration {
+		if _, err := ALUCostModel_Initialize(v.Description.CPU); err != nil {
+			t.Errorf("ALUCostModel Expected profile %s to load, got %s", v.Name, err)
+		}
+	}
+	// The Vax overrides DIV and FSIN and keeps the registry's cost for everything else
+	vax, _ := ALUCostModel_Initialize(cfg.GetConfigByName("Vax-11/780-64MB").Description.CPU)
+	mul, _ := ALULookupOp(ALU_OP_MULTINT64)
+	if vax.Cost(ALU_OP_DIVINT64) != 60 || vax.Cost(ALU_OP_FSIN64) != 400 || vax.Cost(ALU_OP_MULTINT64) != mul.Cycles {
+		t.Errorf("ALUCostModel Expected the Vax DIV and FSIN overrides, got %d %d", vax.Cost(ALU_OP_DIVINT64), vax.Cost(ALU_OP_FSIN64))
+	}
+}
@@ -0,0 +1,315 @@
+package Onyx1ALU
+
+import "math/big"
+
+/*
+   Packed decimal fields follow the IBM layout: two digits per byte, most significant
+   first, with the low nibble of the last byte holding the sign. A, C, E and F are plus,
+   B and D are minus, and results are always written with C or D. An n-byte field holds
+   2n-1 digits, and fields may be 1 to 16 bytes long.
+
+   Zoned decimal has one digit per byte in the low nibble with an F zone, except the last
+   byte whose zone carries the sign.
+*/
+
+const (
+	DecimalMaxLength = 16
+
+	DecimalSignPlus     = 0xC
+	DecimalSignMinus    = 0xD
+	DecimalSignUnsigned = 0xF
+	DecimalZone         = 0xF
+
+	// Edit pattern control characters
+	DecimalEditDigitSelector     = 0x20
+	DecimalEditSignificanceStart = 0x21
+	DecimalEditFieldSeparator    = 0x22
+)
+
+// ALUDecimal performs packed decimal arithmetic. Results of DADD, DSUB and DMUL take the
+// length of parmA and set DECIMALOVERFLOW, keeping the low digits, when they don't fit.
+// DDIV returns the quotient in outA and the remainder, sized like parmB, in outB.
+// DCMP only sets flags. DPACK converts the zoned parmA to packed and DUNPACK the reverse.
+// DEDIT formats parmA through the edit pattern in parmB, whose first byte is the fill
+// character; digits are written as ASCII so patterns are written in ASCII as well.
+func ALUDecimal(op int, parmA []byte, parmB []byte) (outA []byte, outB []byte, flags uint64) {
+	info, ok := aluOps[op]
+	if !ok || info.decimalOp == nil {
+		return nil, nil, ALU_FLAGS_ERROR | ALU_FLAGS_INVALIDOP
+	}
+	return info.decimalOp(parmA, parmB)
+}
+
+// decimalOperands converts both operands of the arithmetic ops, failing on a bad digit or sign
+func decimalOperands(parmA []byte, parmB []byte) (a *big.Int, b *big.Int, ok bool) {
+	if a, ok = decimalToBig(parmA); !ok {
+		return nil, nil, false
+	}
+	if b, ok = decimalToBig(parmB); !ok {
+		return nil, nil, false
+	}
+	return a, b, true
+}
+
+// decimalResult stores r in a field of the given length and computes its flags
+func decimalResult(r *big.Int, length int) (outA []byte, flags uint64) {
+	outA, fits := bigToDecimal(r, length)
+	if !fits {
+		flags |= ALU_FLAGS_DECIMALOVERFLOW
+	}
+	flags |= decimalResultFlags(outA)
+	return outA, flags
+}
+
+// decimalArith implements DADD, DSUB and DMUL with the big.Int method computing the result
+func decimalArith(f func(r *big.Int, a *big.Int, b *big.Int) *big.Int) decimalOpFunc {
+	return func(parmA []byte, parmB []byte) (outA []byte, outB []byte, flags uint64) {
+		a, b, ok := decimalOperands(parmA, parmB)
+		if !ok {
+			return nil, nil, ALU_FLAGS_ERROR | ALU_FLAGS_INVALIDDIGIT
+		}
+		outA, flags = decimalResult(f(new(big.Int), a, b), len(parmA))
+		return outA, nil, flags
+	}
+}
+
+// decimalDivide is truncating division, the remainder takes the sign of the dividend
+func decimalDivide(parmA []byte, parmB []byte) (outA []byte, outB []byte, flags uint64) {
+	a, b, ok := decimalOperands(parmA, parmB)
+	if !ok {
+		return nil, nil, ALU_FLAGS_ERROR | ALU_FLAGS_INVALIDDIGIT
+	}
+	if b.Sign() == 0 {
+		return nil, nil, ALU_FLAGS_ERROR | ALU_FLAGS_DIVIDEBYZERO
+	}
+	q, rem := new(big.Int).QuoRem(a, b, new(big.Int))
+	outB, fits := bigToDecimal(rem, len(parmB))
+	outA, flags = decimalResult(q, len(parmA))
+	if !fits {
+		flags |= ALU_FLAGS_DECIMALOVERFLOW
+	}
+	return outA, outB, flags
+}
+
+// decimalCompare sets flags as FCMP64 does, so the signed and unsigned less-than
+// conditions both work
+func decimalCompare(parmA []byte, parmB []byte) (outA []byte, outB []byte, flags uint64) {
+	a, b, ok := decimalOperands(parmA, parmB)
+	if !ok {
+		return nil, nil, ALU_FLAGS_ERROR | ALU_FLAGS_INVALIDDIGIT
+	}
+	switch a.Cmp(b) {
+	case 0:
+		flags |= ALU_FLAGS_ZERO
+	case -1:
+		flags |= ALU_FLAGS_NEGATIVE | ALU_FLAGS_CARRY
+	}
+	return parmA, parmB, flags
+}
+
+func isPlusSign(n byte) bool {
+	return n == 0xA || n == 0xC || n == 0xE || n == 0xF
+}
+
+func isMinusSign(n byte) bool {
+	return n == 0xB || n == 0xD
+}
+
+// decimalDigits splits a packed field into its digits and sign, checking every nibble
+func decimalDigits(field []byte) (digits []byte, negative bool, ok bool) {
+	if len(field) == 0 || len(field) > DecimalMaxLength {
+		return nil, false, false
+	}
+	digits = make([]byte, 0, len(field)*2-1)
+	for i, b := range field {
+		hi, lo := b>>4, b&0xF
+		if hi > 9 {
+			return nil, false, false
+		}
+		digits = append(digits, hi)
+		if i == len(field)-1 {
+			if !isPlusSign(lo) && !isMinusSign(lo) {
+				return nil, false, false
+			}
+			negative = isMinusSign(lo)
+		} else {
+			if lo > 9 {
+				return nil, false, false
+			}
+			digits = append(digits, lo)
+		}
+	}
+	return digits, negative, true
+}
+
+func decimalToBig(field []byte) (*big.Int, bool) {
+	digits, negative, ok := decimalDigits(field)
+	if !ok {
+		return nil, false
+	}
+	v := new(big.Int)
+	ten := big.NewInt(10)
+	for _, d := range digits {
+		v.Mul(v, ten)
+		v.Add(v, big.NewInt(int64(d)))
+	}
+	if negative {
+		v.Neg(v)
+	}
+	return v, true
+}
+
+// bigToDecimal packs v into a field of the given length, reporting whether all the digits fit
+func bigToDecimal(v *big.Int, length int) (field []byte, fits bool) {
+	field = make([]byte, length)
+	mag := new(big.Int).Abs(v)
+	ten := big.NewInt(10)
+	digit := new(big.Int)
+	sign := byte(DecimalSignPlus)
+	// Digits are filled from the right, starting next to the sign nibble
+	for pos := 2*length - 2; pos >= 0; pos-- {
+		mag.QuoRem(mag, ten, digit)
+		d := byte(digit.Int64())
+		if pos%2 == 0 {
+			field[pos/2] |= d << 4
+		} else {
+			field[pos/2] |= d
+		}
+	}
+	fits = mag.Sign() == 0
+	if v.Sign() < 0 && !decimalIsZero(field) {
+		sign = DecimalSignMinus
+	}
+	field[length-1] |= sign
+	return field, fits
+}
+
+func decimalIsZero(field []byte) bool {
+	for i, b := range field {
+		if i == len(field)-1 {
+			b &= 0xF0
+		}
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func decimalResultFlags(field []byte) (flags uint64) {
+	if decimalIsZero(field) {
+		flags |= ALU_FLAGS_ZERO
+	}
+	if field[len(field)-1]&0xF == DecimalSignMinus {
+		flags |= ALU_FLAGS_NEGATIVE
+	}
+	return flags
+}
+
+func packZoned(zoned []byte, _ []byte) (outA []byte, outB []byte, flags uint64) {
+	n := len(zoned)
+	if n == 0 || n > 2*DecimalMaxLength-1 {
+		return nil, nil, ALU_FLAGS_ERROR | ALU_FLAGS_INVALIDOP
+	}
+	sign := zoned[n-1] >> 4
+	if !isPlusSign(sign) && !isMinusSign(sign) {
+		// ASCII digits carry a 3 zone, treat them as unsigned
+		sign = DecimalSignPlus
+	}
+	if isPlusSign(sign) {
+		sign = DecimalSignPlus
+	} else {
+		sign = DecimalSignMinus
+	}
+	outA = make([]byte, n/2+1)
+	for i := 0; i < n; i++ {
+		d := zoned[i] & 0xF
+		if d > 9 {
+			return nil, nil, ALU_FLAGS_ERROR | ALU_FLAGS_INVALIDDIGIT
+		}
+		// The last digit sits in the high nibble of the last byte, next to the sign
+		pos := 2*len(outA) - 2 - (n - 1 - i)
+		if pos%2 == 0 {
+			outA[pos/2] |= d << 4
+		} else {
+			outA[pos/2] |= d
+		}
+	}
+	outA[len(outA)-1] |= sign
+	if decimalIsZero(outA) {
+		outA[len(outA)-1] = outA[len(outA)-1]&0xF0 | DecimalSignPlus
+	}
+	flags |= decimalResultFlags(outA)
+	return outA, nil, flags
+}
+
+func unpackDecimal(packed []byte, _ []byte) (outA []byte, outB []byte, flags uint64) {
+	digits, negative, ok := decimalDigits(packed)
+	if !ok {
+		return nil, nil, ALU_FLAGS_ERROR | ALU_FLAGS_INVALIDDIGIT
+	}
+	outA = make([]byte, len(digits))
+	for i, d := range digits {
+		outA[i] = DecimalZone<<4 | d
+	}
+	sign := byte(DecimalSignPlus)
+	if negative && !decimalIsZero(packed) {
+		sign = DecimalSignMinus
+	}
+	outA[len(outA)-1] = sign<<4 | digits[len(digits)-1]
+	flags |= decimalResultFlags(packed)
+	return outA, nil, flags
+}
+
+// editDecimal works like the IBM ED instruction. Digit selectors and significance starters
+// each consume a source digit, printing the fill character for leading zeros until
+// significance starts. Message characters print while significance is on, and a plus sign
+// turns significance off after the last digit so trailing "CR" or "-" text is blanked.
+func editDecimal(packed []byte, pattern []byte) (outA []byte, outB []byte, flags uint64) {
+	digits, negative, ok := decimalDigits(packed)
+	if !ok {
+		return nil, nil, ALU_FLAGS_ERROR | ALU_FLAGS_INVALIDDIGIT
+	}
+	if len(pattern) == 0 {
+		return nil, nil, ALU_FLAGS_ERROR | ALU_FLAGS_INVALIDOP
+	}
+	fill := pattern[0]
+	outA = make([]byte, len(pattern))
+	outA[0] = fill
+	significant := false
+	next := 0
+	for i := 1; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case DecimalEditDigitSelector, DecimalEditSignificanceStart:
+			if next >= len(digits) {
+				return nil, nil, ALU_FLAGS_ERROR | ALU_FLAGS_INVALIDOP
+			}
+			d := digits[next]
+			next++
+			if significant || d != 0 {
+				outA[i] = '0' + d
+				significant = true
+			} else {
+				outA[i] = fill
+			}
+			if c == DecimalEditSignificanceStart {
+				significant = true
+			}
+			if next == len(digits) && !negative {
+				significant = false
+			}
+		case DecimalEditFieldSeparator:
+			outA[i] = fill
+			significant = false
+		default:
+			if significant {
+				outA[i] = c
+			} else {
+				outA[i] = fill
+			}
+		}
+	}
+	flags |= decimalResultFlags(packed)
+	return outA, nil, flags
+}
@@ -0,0 +1,83 @@
+package Onyx1ALU
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestALUDecimalArithmetic(t *testing.T) {
+	outA, outB, flags := ALUDecimal(ALU_OP_DADD, []byte{0x12, 0x3C}, []byte{0x00, 0x7D})
+	if !bytes.Equal(outA, []byte{0x11, 0x6C}) {
+		t.Errorf("OP_DADD Expected 116+, got %x %x %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUDecimal(ALU_OP_DSUB, []byte{0x00, 0x5C}, []byte{0x7C})
+	if !bytes.Equal(outA, []byte{0x00, 0x2D}) || flags&ALU_FLAGS_NEGATIVE == 0 {
+		t.Errorf("OP_DSUB Expected 2- NEGATIVE, got %x %x %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUDecimal(ALU_OP_DADD, []byte{0x99, 0x9C}, []byte{0x1C})
+	if !bytes.Equal(outA, []byte{0x00, 0x0C}) || flags&ALU_FLAGS_DECIMALOVERFLOW == 0 || flags&ALU_FLAGS_ZERO == 0 {
+		t.Errorf("OP_DADD Expected 000+ DECIMALOVERFLOW, got %x %x %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUDecimal(ALU_OP_DMUL, []byte{0x00, 0x01, 0x2C}, []byte{0x1D})
+	if !bytes.Equal(outA, []byte{0x00, 0x01, 0x2D}) || flags&ALU_FLAGS_DECIMALOVERFLOW != 0 {
+		t.Errorf("OP_DMUL Expected 12-, got %x %x %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUDecimal(ALU_OP_DDIV, []byte{0x00, 0x10, 0x0C}, []byte{0x3C})
+	if !bytes.Equal(outA, []byte{0x00, 0x03, 0x3C}) || !bytes.Equal(outB, []byte{0x1C}) {
+		t.Errorf("OP_DDIV Expected 33+ remainder 1+, got %x %x %b", outA, outB, flags)
+	}
+	// The remainder takes every digit of the divisor's field: 1997 / 999- is 1- remainder 998
+	outA, outB, flags = ALUDecimal(ALU_OP_DDIV, []byte{0x01, 0x99, 0x7C}, []byte{0x99, 0x9D})
+	if !bytes.Equal(outA, []byte{0x00, 0x00, 0x1D}) || !bytes.Equal(outB, []byte{0x99, 0x8C}) || flags&ALU_FLAGS_DECIMALOVERFLOW != 0 {
+		t.Errorf("OP_DDIV Expected 1- remainder 998+, got %x %x %b", outA, outB, flags)
+	}
+	_, _, flags = ALUDecimal(ALU_OP_DDIV, []byte{0x1C}, []byte{0x0C})
+	if flags&ALU_FLAGS_DIVIDEBYZERO == 0 {
+		t.Errorf("OP_DDIV Expected DIVIDEBYZERO, got %b", flags)
+	}
+	_, _, flags = ALUDecimal(ALU_OP_DCMP, []byte{0x1D}, []byte{0x00, 0x0C})
+	if !ALUCondition(flags, ALU_COND_LT) {
+		t.Errorf("OP_DCMP Expected 1- < 0+, got %b", flags)
+	}
+	_, _, flags = ALUDecimal(ALU_OP_DCMP, []byte{0x0D}, []byte{0x00, 0x0C})
+	if !ALUCondition(flags, ALU_COND_EQ) {
+		t.Errorf("OP_DCMP Expected -0 == +0, got %b", flags)
+	}
+	_, _, flags = ALUDecimal(ALU_OP_DADD, []byte{0x1A, 0x1C}, []byte{0x1C})
+	if flags&ALU_FLAGS_INVALIDDIGIT == 0 {
+		t.Errorf("OP_DADD Expected INVALIDDIGIT, got %b", flags)
+	}
+	_, _, flags = ALUDecimal(ALU_OP_DADD, []byte{0x11, 0x13}, []byte{0x1C})
+	if flags&ALU_FLAGS_INVALIDDIGIT == 0 {
+		t.Errorf("OP_DADD Expected INVALIDDIGIT for a bad sign, got %b", flags)
+	}
+}
+
+func TestALUDecimalPackEdit(t *testing.T) {
+	outA, outB, flags := ALUDecimal(ALU_OP_DPACK, []byte{0xF1, 0xF2, 0xD3}, nil)
+	if !bytes.Equal(outA, []byte{0x12, 0x3D}) {
+		t.Errorf("OP_DPACK Expected 123-, got %x %x %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUDecimal(ALU_OP_DPACK, []byte("4096"), nil)
+	if !bytes.Equal(outA, []byte{0x04, 0x09, 0x6C}) {
+		t.Errorf("OP_DPACK Expected 4096+, got %x %x %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUDecimal(ALU_OP_DUNPACK, []byte{0x12, 0x3D}, nil)
+	if !bytes.Equal(outA, []byte{0xF1, 0xF2, 0xD3}) {
+		t.Errorf("OP_DUNPACK Expected F1F2D3, got %x %x %b", outA, outB, flags)
+	}
+	pattern := []byte{' ', 0x20, 0x20, ',', 0x20, 0x20, 0x20, '.', 0x21, 0x20, 'C', 'R'}
+	outA, outB, flags = ALUDecimal(ALU_OP_DEDIT, []byte{0x01, 0x23, 0x45, 0x6D}, pattern)
+	if string(outA) != "  1,234.56CR" {
+		t.Errorf("OP_DEDIT Expected '  1,234.56CR', got '%s' %x %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUDecimal(ALU_OP_DEDIT, []byte{0x01, 0x23, 0x45, 0x6C}, pattern)
+	if string(outA) != "  1,234.56  " {
+		t.Errorf("OP_DEDIT Expected '  1,234.56  ', got '%s' %x %b", outA, outB, flags)
+	}
+	pattern = []byte{'*', 0x20, 0x20, ',', 0x20, 0x20, 0x21, '.', 0x20, 0x20}
+	outA, outB, flags = ALUDecimal(ALU_OP_DEDIT, []byte{0x00, 0x00, 0x00, 0x5C}, pattern)
+	if string(outA) != "*******.05" {
+		t.Errorf("OP_DEDIT Expected '*******.05', got '%s' %x %b", outA, outB, flags)
+	}
+}
@@ -0,0 +1,97 @@
+package Onyx1ALU
+
+import "math/big"
+
+// Control word for ALUFixed. The low bits hold n, the number of fraction bits of the Qm.n
+// operands, so Q1.15 at ALU_WIDTH_16 is 15. Without ALU_FIXED_ROUND results are truncated
+// towards minus infinity, as an arithmetic shift does, and with it they round to nearest
+// with ties going up. Without ALU_FIXED_SATURATE a result out of range wraps and sets
+// OVERFLOW, with it the result is clamped and SATURATED is set.
+const (
+	ALU_FIXED_FRAC_MASK = 0x0000_0000_0000_003F
+	ALU_FIXED_ROUND     = 0x0000_0000_0000_0040
+	ALU_FIXED_SATURATE  = 0x0000_0000_0000_0080
+)
+
+// ALUFixed runs QMUL, QDIV, QRECIP and QSQRT on signed Qm.n values of the given width.
+// The result is exact before rounding. QRECIP and QSQRT only use parmA. Dividing by zero
+// sets ERROR|DIVIDEBYZERO and the square root of a negative value ERROR|INVALIDOP, as does
+// a fraction as wide as the operands.
+func ALUFixed(op int, width int, parmA int64, parmB int64, control uint64) (outA int64, outB int64, flags uint64) {
+	info, ok := aluOps[op]
+	frac := uint(control & ALU_FIXED_FRAC_MASK)
+	if !ok || info.fixedOp == nil || !isValidWidth(width) || frac >= uint(width) {
+		return 0, 0, ALU_FLAGS_ERROR | ALU_FLAGS_INVALIDOP
+	}
+	a := big.NewInt(truncateToWidth(parmA, width))
+	b := big.NewInt(truncateToWidth(parmB, width))
+	r, flags := info.fixedOp(a, b, frac, control&ALU_FIXED_ROUND != 0)
+	if flags&ALU_FLAGS_ERROR != 0 {
+		return 0, 0, flags
+	}
+	max := big.NewInt(int64(uint64(1)<<uint(width-1) - 1))
+	min := new(big.Int).Not(max)
+	switch {
+	case r.Cmp(max) > 0 && control&ALU_FIXED_SATURATE != 0:
+		r, flags = max, flags|ALU_FLAGS_SATURATED
+	case r.Cmp(min) < 0 && control&ALU_FIXED_SATURATE != 0:
+		r, flags = min, flags|ALU_FLAGS_SATURATED
+	case r.Cmp(max) > 0 || r.Cmp(min) < 0:
+		flags |= ALU_FLAGS_OVERFLOW
+	}
+	// And works on the two's complement form, so this keeps the low 64 bits of a negative r too
+	low := new(big.Int).And(r, new(big.Int).SetUint64(^uint64(0))).Uint64()
+	outA = truncateToWidth(int64(low), width)
+	flags |= resultFlags(outA)
+	return outA, 0, flags
+}
+
+// fixedQuotient returns num/den rounded as ALU_FIXED_ROUND asks
+func fixedQuotient(num *big.Int, den *big.Int, round bool) *big.Int {
+	num, den = new(big.Int).Set(num), new(big.Int).Set(den)
+	if den.Sign() < 0 {
+		num.Neg(num)
+		den.Neg(den)
+	}
+	if round {
+		// floor((2*num + den) / (2*den)) is floor(num/den + 1/2)
+		num.Lsh(num, 1).Add(num, den)
+		den.Lsh(den, 1)
+	}
+	// With a positive divisor Div is floor division
+	return num.Div(num, den)
+}
+
+// fixedMul is (a*b) >> n
+func fixedMul(parmA *big.Int, parmB *big.Int, frac uint, round bool) (*big.Int, uint64) {
+	num := new(big.Int).Mul(parmA, parmB)
+	return fixedQuotient(num, new(big.Int).Lsh(big.NewInt(1), frac), round), 0
+}
+
+// fixedDiv is (a << n) / b
+func fixedDiv(parmA *big.Int, parmB *big.Int, frac uint, round bool) (*big.Int, uint64) {
+	if parmB.Sign() == 0 {
+		return nil, ALU_FLAGS_ERROR | ALU_FLAGS_DIVIDEBYZERO
+	}
+	return fixedQuotient(new(big.Int).Lsh(parmA, frac), parmB, round), 0
+}
+
+// fixedRecip is 1.0 / a, that is (1 << 2n) / a
+func fixedRecip(parmA *big.Int, parmB *big.Int, frac uint, round bool) (*big.Int, uint64) {
+	return fixedDiv(new(big.Int).Lsh(big.NewInt(1), frac), parmA, frac, round)
+}
+
+// fixedSqrt is the integer square root of a << n. The root of an integer is never exactly
+// halfway between two integers, so rounding needs no tie rule.
+func fixedSqrt(parmA *big.Int, parmB *big.Int, frac uint, round bool) (*big.Int, uint64) {
+	if parmA.Sign() < 0 {
+		return nil, ALU_FLAGS_ERROR | ALU_FLAGS_INVALIDOP
+	}
+	x := new(big.Int).Lsh(parmA, frac)
+	r := new(big.Int).Sqrt(x)
+	// r+1 is nearer when x - r*r > r, as (r+1/2)^2 = r*r + r + 1/4
+	if round && new(big.Int).Sub(x, new(big.Int).Mul(r, r)).Cmp(r) > 0 {
+		r.Add(r, big.NewInt(1))
+	}
+	return r, 0
+}
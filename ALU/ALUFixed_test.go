@@ -0,0 +1,122 @@
+package Onyx1ALU
+
+import (
+	"GolangCPUParts/Configuration"
+	"testing"
+)
+
+const q15 = 15
+const q16 = 16
+
+func TestFixedMul(t *testing.T) {
+	outA, _, flags := ALUFixed(ALU_OP_QMUL, ALU_WIDTH_16, 0x4000, 0x4000, q15)
+	if outA != 0x2000 || flags != 0 {
+		t.Errorf("OP_QMUL Expected 0.5*0.5 = 2000, got %x %b", outA, flags)
+	}
+	// -1 * -1 is the one Q1.15 product that doesn't fit
+	outA, _, flags = ALUFixed(ALU_OP_QMUL, ALU_WIDTH_16, -0x8000, -0x8000, q15|ALU_FIXED_SATURATE)
+	if outA != 0x7FFF || flags != ALU_FLAGS_SATURATED {
+		t.Errorf("OP_QMUL Expected 7fff with SATURATED, got %x %b", outA, flags)
+	}
+	outA, _, flags = ALUFixed(ALU_OP_QMUL, ALU_WIDTH_16, -0x8000, -0x8000, q15)
+	if outA != -0x8000 || flags != ALU_FLAGS_OVERFLOW|ALU_FLAGS_NEGATIVE {
+		t.Errorf("OP_QMUL Expected -8000 with OVERFLOW, got %x %b", outA, flags)
+	}
+	// 1/256 * 0.5 in Q8.8 is exactly half the smallest step
+	outA, _, _ = ALUFixed(ALU_OP_QMUL, ALU_WIDTH_16, 1, 0x80, 8)
+	if outA != 0 {
+		t.Errorf("OP_QMUL Expected 0 truncated, got %d", outA)
+	}
+	outA, _, _ = ALUFixed(ALU_OP_QMUL, ALU_WIDTH_16, 1, 0x80, 8|ALU_FIXED_ROUND)
+	if outA != 1 {
+		t.Errorf("OP_QMUL Expected 1 rounded, got %d", outA)
+	}
+	outA, _, _ = ALUFixed(ALU_OP_QMUL, ALU_WIDTH_16, -1, 0x80, 8)
+	if outA != -1 {
+		t.Errorf("OP_QMUL Expected -1 truncated towards minus infinity, got %d", outA)
+	}
+	outA, _, flags = ALUFixed(ALU_OP_QMUL, ALU_WIDTH_16, -1, 0x80, 8|ALU_FIXED_ROUND)
+	if outA != 0 || flags != ALU_FLAGS_ZERO {
+		t.Errorf("OP_QMUL Expected 0 rounding the tie up, got %d %b", outA, flags)
+	}
+	outA, _, _ = ALUFixed(ALU_OP_QMUL, ALU_WIDTH_64, 3<<32, -5<<32, 32)
+	if outA != -15<<32 {
+		t.Errorf("OP_QMUL Expected -15.0 in Q32.32, got %x", outA)
+	}
+}
+
+func TestFixedDivide(t *testing.T) {
+	outA, _, _ := ALUFixed(ALU_OP_QDIV, ALU_WIDTH_32, 2<<q16, 3<<q16, q16)
+	if outA != 43690 {
+		t.Errorf("OP_QDIV Expected 2/3 truncated, got %d", outA)
+	}
+	outA, _, _ = ALUFixed(ALU_OP_QDIV, ALU_WIDTH_32, 2<<q16, 3<<q16, q16|ALU_FIXED_ROUND)
+	if outA != 43691 {
+		t.Errorf("OP_QDIV Expected 2/3 rounded, got %d", outA)
+	}
+	outA, _, flags := ALUFixed(ALU_OP_QDIV, ALU_WIDTH_32, 1<<q16, -3<<q16, q16|ALU_FIXED_ROUND)
+	if outA != -21845 || flags != ALU_FLAGS_NEGATIVE {
+		t.Errorf("OP_QDIV Expected -1/3 rounded, got %d %b", outA, flags)
+	}
+	outA, _, flags = ALUFixed(ALU_OP_QDIV, ALU_WIDTH_16, 0x4000, 0x2000, q15|ALU_FIXED_SATURATE)
+	if outA != 0x7FFF || flags != ALU_FLAGS_SATURATED {
+		t.Errorf("OP_QDIV Expected 0.5/0.25 to saturate, got %x %b", outA, flags)
+	}
+	_, _, flags = ALUFixed(ALU_OP_QDIV, ALU_WIDTH_32, 1, 0, q16)
+	if flags != ALU_FLAGS_ERROR|ALU_FLAGS_DIVIDEBYZERO {
+		t.Errorf("OP_QDIV Expected DIVIDEBYZERO, got %b", flags)
+	}
+	outA, _, _ = ALUFixed(ALU_OP_QRECIP, ALU_WIDTH_32, 4<<q16, 0, q16)
+	if outA != 1<<(q16-2) {
+		t.Errorf("OP_QRECIP Expected 0.25, got %x", outA)
+	}
+	_, _, flags = ALUFixed(ALU_OP_QRECIP, ALU_WIDTH_32, 0, 0, q16)
+	if flags != ALU_FLAGS_ERROR|ALU_FLAGS_DIVIDEBYZERO {
+		t.Errorf("OP_QRECIP Expected DIVIDEBYZERO, got %b", flags)
+	}
+}
+
+func TestFixedSqrt(t *testing.T) {
+	// sqrt(2) is 92681.9 in Q16.16
+	outA, _, _ := ALUFixed(ALU_OP_QSQRT, ALU_WIDTH_32, 2<<q16, 0, q16)
+	if outA != 92681 {
+		t.Errorf("OP_QSQRT Expected sqrt(2) truncated, got %d", outA)
+	}
+	outA, _, _ = ALUFixed(ALU_OP_QSQRT, ALU_WIDTH_32, 2<<q16, 0, q16|ALU_FIXED_ROUND)
+	if outA != 92682 {
+		t.Errorf("OP_QSQRT Expected sqrt(2) rounded, got %d", outA)
+	}
+	outA, _, _ = ALUFixed(ALU_OP_QSQRT, ALU_WIDTH_32, 4<<q16, 0, q16|ALU_FIXED_ROUND)
+	if outA != 2<<q16 {
+		t.Errorf("OP_QSQRT Expected 2.0 exactly, got %x", outA)
+	}
+	_, _, flags := ALUFixed(ALU_OP_QSQRT, ALU_WIDTH_32, -1, 0, q16)
+	if flags != ALU_FLAGS_ERROR|ALU_FLAGS_INVALIDOP {
+		t.Errorf("OP_QSQRT Expected INVALIDOP for a negative value, got %b", flags)
+	}
+	_, _, flags = ALUFixed(ALU_OP_QSQRT, ALU_WIDTH_16, 1, 0, q16)
+	if flags != ALU_FLAGS_ERROR|ALU_FLAGS_INVALIDOP {
+		t.Errorf("OP_QSQRT Expected INVALIDOP for 16 fraction bits at width 16, got %b", flags)
+	}
+}
+
+func TestFixedExecute(t *testing.T) {
+	info, _ := ALULookupMnemonic("QMUL")
+	outA, _, flags := info.Execute(ALU_WIDTH_16, 0x8000, 0x8000, 0, 0, q15|ALU_FIXED_SATURATE)
+	if outA != 0x7FFF || flags != ALU_FLAGS_SATURATED {
+		t.Errorf("OP_QMUL Expected 7fff through Execute, got %x %b", outA, flags)
+	}
+	noFPU := Configuration.CPUDescriptor{FeatureA: Configuration.FeatureA_NoFPU}
+	if ALUOpAvailable(ALU_OP_FADD64, noFPU) || ALUOpAvailable(ALU_OP_FMA64, noFPU) || ALUOpAvailable(ALU_OP_CVTI64F64, noFPU) {
+		t.Errorf("ALUOpAvailable Expected no float ops without an FPU")
+	}
+	if !ALUOpAvailable(ALU_OP_QMUL, noFPU) || !ALUOpAvailable(ALU_OP_FADD64, Configuration.CPUDescriptor{}) {
+		t.Errorf("ALUOpAvailable Expected QMUL without an FPU and FADD with one")
+	}
+	for _, op := range []int{ALU_OP_DADD, ALU_OP_AESENC, ALU_OP_BIGADD, ALU_OP_FADD80} {
+		info, _ := ALULookupOp(op)
+		if ALUOpAvailable(op, Configuration.CPUDescriptor{}) || info.Executable() {
+			t.Errorf("ALUOpAvailable Expected %s, which Execute can't run, to be unavailable", info.Mnemonic)
+		}
+	}
+}
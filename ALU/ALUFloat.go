@@ -0,0 +1,308 @@
+package Onyx1ALU
+
+import (
+	"math"
+	"math/big"
+)
+
+// The float control word passed to ALUFloat64WithControl. Only the rounding mode is
+// defined so far, it lives in the low two bits.
+const (
+	ALU_FPCW_ROUND_NEAREST = 0x0000_0000_0000_0000
+	ALU_FPCW_ROUND_ZERO    = 0x0000_0000_0000_0001
+	ALU_FPCW_ROUND_UP      = 0x0000_0000_0000_0002
+	ALU_FPCW_ROUND_DOWN    = 0x0000_0000_0000_0003
+	ALU_FPCW_ROUND_MASK    = 0x0000_0000_0000_0003
+)
+
+const (
+	minNormalFloat64 = 0x1p-1022
+	// Enough bits to hold any a*b+c of float64 values exactly
+	fmaExactPrecision = 2400
+	// Below this the error terms of the rounded ops could themselves underflow, so
+	// the operands get scaled up by 2^256 before the error is computed
+	tinyFloat64 = 0x1p-900
+)
+
+func floatResultFlags(outA float64) (flags uint64) {
+	if outA < 0 {
+		flags |= ALU_FLAGS_NEGATIVE
+	}
+	if outA == 0 {
+		flags |= ALU_FLAGS_ZERO
+	}
+	return flags
+}
+
+func signOf(v float64) int {
+	if v > 0 {
+		return 1
+	}
+	if v < 0 {
+		return -1
+	}
+	return 0
+}
+
+// overflowResult is what an overflowing result becomes under each rounding mode,
+// maxValue being the largest finite value of the result type
+func overflowResult[T float32 | float64](negative bool, control uint64, maxValue T) T {
+	switch control & ALU_FPCW_ROUND_MASK {
+	case ALU_FPCW_ROUND_ZERO:
+		if negative {
+			return -maxValue
+		}
+		return maxValue
+	case ALU_FPCW_ROUND_UP:
+		if negative {
+			return -maxValue
+		}
+	case ALU_FPCW_ROUND_DOWN:
+		if !negative {
+			return maxValue
+		}
+	}
+	if negative {
+		return T(math.Inf(-1))
+	}
+	return T(math.Inf(1))
+}
+
+// roundResult moves r, the round-to-nearest result, to the result the rounding mode
+// wants. errSign is the sign of the exact result minus r, next is the Nextafter for T.
+func roundResult[T float32 | float64](r T, errSign int, control uint64, next func(T, T) T) (T, uint64) {
+	if errSign == 0 {
+		return r, 0
+	}
+	switch control & ALU_FPCW_ROUND_MASK {
+	case ALU_FPCW_ROUND_ZERO:
+		if (r > 0 && errSign < 0) || (r < 0 && errSign > 0) {
+			r = next(r, 0)
+		}
+	case ALU_FPCW_ROUND_UP:
+		if errSign > 0 {
+			r = next(r, T(math.Inf(1)))
+		}
+	case ALU_FPCW_ROUND_DOWN:
+		if errSign < 0 {
+			r = next(r, T(math.Inf(-1)))
+		}
+	}
+	return r, ALU_FLAGS_FPINEXACT
+}
+
+// The error functions below return the sign of (exact result - r) for the
+// round-to-nearest result r, using the usual error-free transformations
+
+func addError(parmA float64, parmB float64, r float64) int {
+	bb := r - parmA
+	return signOf((parmA - (r - bb)) + (parmB - bb))
+}
+
+func mulError(parmA float64, parmB float64, r float64) int {
+	if math.Abs(r) >= tinyFloat64 {
+		return signOf(math.FMA(parmA, parmB, -r))
+	}
+	if math.Abs(parmA) < math.Abs(parmB) {
+		parmA = math.Ldexp(parmA, 256)
+	} else {
+		parmB = math.Ldexp(parmB, 256)
+	}
+	return signOf(math.FMA(parmA, parmB, -math.Ldexp(r, 256)))
+}
+
+func divError(parmA float64, parmB float64, r float64) int {
+	if math.Abs(r) < tinyFloat64 || math.Abs(parmA) < tinyFloat64 {
+		parmA = math.Ldexp(parmA, 256)
+		r = math.Ldexp(r, 256)
+	}
+	s := signOf(math.FMA(-r, parmB, parmA))
+	if parmB < 0 {
+		s = -s
+	}
+	return s
+}
+
+func sqrtError(parmA float64, r float64) int {
+	if parmA < tinyFloat64 {
+		parmA = math.Ldexp(parmA, 256)
+		r = math.Ldexp(r, 128)
+	}
+	return signOf(math.FMA(-r, r, parmA))
+}
+
+// nearestArith64 does one of the correctly rounded ops (add, subtract, multiply, divide
+// and square root) rounding to nearest. It returns the sign of (exact result - outA) for
+// the caller to apply a rounding mode with, or done when the special cases (NaN,
+// infinities, division by zero and overflow) have already settled the result and flags.
+func nearestArith64(op int, parmA float64, parmB float64) (outA float64, errSign int, flags uint64, done bool) {
+	switch op {
+	case ALU_OP_FADD64:
+		outA = parmA + parmB
+	case ALU_OP_FSUB64:
+		parmB = -parmB
+		outA = parmA + parmB
+	case ALU_OP_FMULT64:
+		outA = parmA * parmB
+	case ALU_OP_FDIV64:
+		outA = parmA / parmB
+	case ALU_OP_FSQRT64:
+		parmB = 0
+		outA = math.Sqrt(parmA)
+	}
+	if math.IsNaN(outA) {
+		if !math.IsNaN(parmA) && !math.IsNaN(parmB) {
+			flags |= ALU_FLAGS_FPINVALID
+		}
+		return outA, 0, flags, true
+	}
+	inputsFinite := !math.IsInf(parmA, 0) && !math.IsInf(parmB, 0)
+	if math.IsInf(outA, 0) {
+		if op == ALU_OP_FDIV64 && parmB == 0 && inputsFinite {
+			return outA, 0, ALU_FLAGS_DIVIDEBYZERO, true
+		}
+		if inputsFinite {
+			return outA, 0, ALU_FLAGS_FPOVERFLOW | ALU_FLAGS_FPINEXACT, true
+		}
+		return outA, 0, flags, true
+	}
+	if !inputsFinite {
+		return outA, 0, flags, true
+	}
+	switch op {
+	case ALU_OP_FADD64, ALU_OP_FSUB64:
+		errSign = addError(parmA, parmB, outA)
+	case ALU_OP_FMULT64:
+		errSign = mulError(parmA, parmB, outA)
+	case ALU_OP_FDIV64:
+		errSign = divError(parmA, parmB, outA)
+	case ALU_OP_FSQRT64:
+		errSign = sqrtError(parmA, outA)
+	}
+	return outA, errSign, flags, false
+}
+
+// exactZeroSign gives an exact zero sum its IEEE sign: +0, except -0 when rounding down
+// or when both addends are -0
+func exactZeroSign[T float32 | float64](op int, parmA T, parmB T, control uint64) T {
+	negB := math.Signbit(float64(parmB))
+	if op == ALU_OP_FSUB64 {
+		negB = !negB
+	}
+	negA := math.Signbit(float64(parmA))
+	if (negA && negB) || (negA != negB && control&ALU_FPCW_ROUND_MASK == ALU_FPCW_ROUND_DOWN) {
+		return T(math.Copysign(0, -1))
+	}
+	return 0
+}
+
+// roundedArith64 does the correctly rounded ops under the control word's rounding mode
+// and raises the IEEE exceptions
+func roundedArith64(op int, parmA float64, parmB float64, control uint64) (outA float64, flags uint64) {
+	outA, errSign, flags, done := nearestArith64(op, parmA, parmB)
+	if done {
+		if flags&ALU_FLAGS_FPOVERFLOW != 0 {
+			outA = overflowResult(outA < 0, control, math.MaxFloat64)
+		}
+		return outA, flags
+	}
+	if errSign == 0 && outA == 0 && (op == ALU_OP_FADD64 || op == ALU_OP_FSUB64) {
+		outA = exactZeroSign(op, parmA, parmB, control)
+	}
+	outA, flags = roundResult(outA, errSign, control, math.Nextafter)
+	if math.IsInf(outA, 0) {
+		flags |= ALU_FLAGS_FPOVERFLOW
+	}
+	if flags&ALU_FLAGS_FPINEXACT != 0 && math.Abs(outA) < minNormalFloat64 {
+		flags |= ALU_FLAGS_FPUNDERFLOW
+	}
+	return outA, flags
+}
+
+// transcendentalFlags raises the exceptions for the library functions. They are not
+// correctly rounded, so they ignore the rounding mode and never report INEXACT on
+// their own, only along with an overflow or underflow.
+func transcendentalFlags(outA float64, parms ...float64) (flags uint64) {
+	anyNaN, anyInf, anyZero := false, false, false
+	for _, p := range parms {
+		anyNaN = anyNaN || math.IsNaN(p)
+		anyInf = anyInf || math.IsInf(p, 0)
+		anyZero = anyZero || p == 0
+	}
+	if math.IsNaN(outA) {
+		if !anyNaN {
+			flags |= ALU_FLAGS_FPINVALID
+		}
+		return flags
+	}
+	if math.IsInf(outA, 0) && !anyInf {
+		// An infinite result from an exact zero is a pole, such as log(0)
+		if anyZero {
+			return ALU_FLAGS_DIVIDEBYZERO
+		}
+		return ALU_FLAGS_FPOVERFLOW | ALU_FLAGS_FPINEXACT
+	}
+	if outA != 0 && math.Abs(outA) < minNormalFloat64 {
+		flags |= ALU_FLAGS_FPUNDERFLOW | ALU_FLAGS_FPINEXACT
+	}
+	return flags
+}
+
+// minMaxNum is the IEEE minNum/maxNum: a NaN operand is ignored in favour of the other
+// one, and -0 is treated as less than +0
+func minMaxNum(op int, parmA float64, parmB float64) float64 {
+	if math.IsNaN(parmA) {
+		return parmB
+	}
+	if math.IsNaN(parmB) {
+		return parmA
+	}
+	if op == ALU_OP_FMIN64 {
+		return math.Min(parmA, parmB)
+	}
+	return math.Max(parmA, parmB)
+}
+
+// ALUFloat64FMA is ALU_OP_FMA64, parmA * parmB + parmC with a single rounding under the
+// control word's rounding mode. Like ALUBitFieldInsert it takes three operands, so it
+// has its own entry point.
+func ALUFloat64FMA(parmA float64, parmB float64, parmC float64, control uint64) (outA float64, flags uint64) {
+	outA = math.FMA(parmA, parmB, parmC)
+	if math.IsNaN(outA) {
+		if !math.IsNaN(parmA) && !math.IsNaN(parmB) && !math.IsNaN(parmC) {
+			flags |= ALU_FLAGS_FPINVALID
+		}
+		return outA, flags
+	}
+	inputsFinite := !math.IsInf(parmA, 0) && !math.IsInf(parmB, 0) && !math.IsInf(parmC, 0)
+	if !inputsFinite {
+		return outA, flags | floatResultFlags(outA)
+	}
+	if math.IsInf(outA, 0) {
+		outA = overflowResult(outA < 0, control, math.MaxFloat64)
+		flags |= ALU_FLAGS_FPOVERFLOW | ALU_FLAGS_FPINEXACT
+		return outA, flags | floatResultFlags(outA)
+	}
+	// The exact a*b+c can span the whole exponent range, so the rounding error is
+	// measured against it in big.Float rather than with an error-free transformation
+	exact := new(big.Float).SetPrec(fmaExactPrecision).SetFloat64(parmA)
+	exact.Mul(exact, new(big.Float).SetFloat64(parmB))
+	exact.Add(exact, new(big.Float).SetFloat64(parmC))
+	errSign := exact.Cmp(new(big.Float).SetFloat64(outA))
+	if errSign == 0 && outA == 0 {
+		// Exact zero, the sign follows the rules for a sum of the product and parmC
+		product := parmA * parmB
+		if product == 0 {
+			product = math.Copysign(0, parmA) * math.Copysign(1, parmB)
+		}
+		outA = exactZeroSign(ALU_OP_FADD64, product, parmC, control)
+	}
+	outA, flags = roundResult(outA, errSign, control, math.Nextafter)
+	if math.IsInf(outA, 0) {
+		flags |= ALU_FLAGS_FPOVERFLOW
+	}
+	if flags&ALU_FLAGS_FPINEXACT != 0 && math.Abs(outA) < minNormalFloat64 {
+		flags |= ALU_FLAGS_FPUNDERFLOW
+	}
+	return outA, flags | floatResultFlags(outA)
+}
@@ -0,0 +1,73 @@
+package Onyx1ALU
+
+import "math"
+
+const minNormalFloat32 = 0x1p-126
+
+func ALUFloat32(op int, parmA float32, parmB float32) (outA float32, outB float32, flags uint64) {
+	return ALUFloat32WithControl(op, parmA, parmB, ALU_FPCW_ROUND_NEAREST)
+}
+
+// ALUFloat32WithControl is the single precision counterpart of ALUFloat64WithControl,
+// with the same flags and rounding modes
+func ALUFloat32WithControl(op int, parmA float32, parmB float32, control uint64) (outA float32, outB float32, flags uint64) {
+	info, ok := aluOps[op]
+	if !ok || info.float32Op == nil {
+		flags |= ALU_FLAGS_INVALIDOP
+		flags |= ALU_FLAGS_ERROR
+		return 0, 0, flags
+	}
+	return info.float32Op(parmA, parmB, control)
+}
+
+// float32Arith implements a float32 op with the float64 op it is computed with
+func float32Arith(op64 int) float32OpFunc {
+	return func(parmA float32, parmB float32, control uint64) (outA float32, outB float32, flags uint64) {
+		outA, flags = roundedArith32(op64, parmA, parmB, control)
+		flags |= floatResultFlags(float64(outA))
+		return outA, outB, flags
+	}
+}
+
+func float32Cmp(parmA float32, parmB float32, control uint64) (outA float32, outB float32, flags uint64) {
+	_, _, flags = floatCmp(float64(parmA), float64(parmB), control)
+	return parmA, parmB, flags
+}
+
+// roundedArith32 computes in float64, where float32 operands can't overflow or underflow,
+// then rounds once more to float32. Rounding to nearest twice is safe for these ops since
+// float64 has more than twice the precision, and for the directed modes the float64
+// error sign tells which way to go when the float64 result is already a float32 value.
+func roundedArith32(op64 int, parmA float32, parmB float32, control uint64) (outA float32, flags uint64) {
+	r64, errSign64, flags, done := nearestArith64(op64, float64(parmA), float64(parmB))
+	if done {
+		return float32(r64), flags
+	}
+	return roundFloat64ToFloat32(r64, errSign64, control, func() float32 {
+		return exactZeroSign(op64, parmA, parmB, control)
+	})
+}
+
+// roundFloat64ToFloat32 rounds r64, which is errSign64 away from the exact result, to a
+// float32 under the rounding mode. zero supplies the sign of an exact zero result.
+func roundFloat64ToFloat32(r64 float64, errSign64 int, control uint64, zero func() float32) (outA float32, flags uint64) {
+	outA = float32(r64)
+	if math.IsInf(float64(outA), 0) && !math.IsInf(r64, 0) {
+		return overflowResult(outA < 0, control, float32(math.MaxFloat32)), ALU_FLAGS_FPOVERFLOW | ALU_FLAGS_FPINEXACT
+	}
+	errSign := signOf(r64 - float64(outA))
+	if errSign == 0 {
+		errSign = errSign64
+	}
+	if errSign == 0 && outA == 0 && zero != nil {
+		outA = zero()
+	}
+	outA, flags = roundResult(outA, errSign, control, math.Nextafter32)
+	if math.IsInf(float64(outA), 0) && !math.IsInf(r64, 0) {
+		flags |= ALU_FLAGS_FPOVERFLOW
+	}
+	if flags&ALU_FLAGS_FPINEXACT != 0 && math.Abs(float64(outA)) < minNormalFloat32 {
+		flags |= ALU_FLAGS_FPUNDERFLOW
+	}
+	return outA, flags
+}
@@ -0,0 +1,354 @@
+package Onyx1ALU
+
+import (
+	"math"
+	"math/big"
+)
+
+// Float80 is the x87 extended precision format: a sign bit and 15-bit exponent biased by
+// 16383, and a 64-bit significand with an explicit integer bit in bit 63. Exponent 0
+// holds zeroes and denormals, 0x7FFF infinities and NaNs. Encodings with the integer bit
+// clear where it should be set (unnormals, pseudo-infinities and pseudo-NaNs) are
+// invalid operands, as on the 387 and later.
+type Float80 struct {
+	SignExponent uint16
+	Mantissa     uint64
+}
+
+const (
+	float80Bias        = 16383
+	float80MaxExponent = 0x7FFF
+	float80MinExponent = 1 - float80Bias
+	float80IntegerBit  = 1 << 63
+	float80QuietBit    = 1 << 62
+	// Wide enough that truncating the exact result loses nothing needed to round it
+	float80WorkPrecision = 200
+)
+
+// Float80Indefinite is the NaN produced by invalid operations
+var Float80Indefinite = Float80{SignExponent: 0xFFFF, Mantissa: 0xC000_0000_0000_0000}
+
+func (x Float80) exponent() int { return int(x.SignExponent & float80MaxExponent) }
+
+func (x Float80) Signbit() bool { return x.SignExponent&0x8000 != 0 }
+
+func (x Float80) IsNaN() bool {
+	return x.exponent() == float80MaxExponent && x.Mantissa&float80IntegerBit != 0 && x.Mantissa<<1 != 0
+}
+
+func (x Float80) IsInf() bool {
+	return x.exponent() == float80MaxExponent && x.Mantissa == float80IntegerBit
+}
+
+func (x Float80) IsZero() bool { return x.exponent() == 0 && x.Mantissa == 0 }
+
+// isValid rejects the encodings whose integer bit disagrees with the exponent
+func (x Float80) isValid() bool {
+	return x.exponent() == 0 || x.Mantissa&float80IntegerBit != 0
+}
+
+func (x Float80) neg() Float80 {
+	x.SignExponent ^= 0x8000
+	return x
+}
+
+func float80Zero(negative bool) Float80 {
+	if negative {
+		return Float80{SignExponent: 0x8000}
+	}
+	return Float80{}
+}
+
+func float80Inf(negative bool) Float80 {
+	return Float80{SignExponent: float80Zero(negative).SignExponent | float80MaxExponent, Mantissa: float80IntegerBit}
+}
+
+// toBig converts a finite Float80 exactly
+func (x Float80) toBig() *big.Float {
+	e := x.exponent() - float80Bias
+	if x.exponent() == 0 {
+		e = float80MinExponent
+	}
+	b := new(big.Float).SetPrec(float80WorkPrecision).SetUint64(x.Mantissa)
+	b.SetMantExp(b, e-63)
+	if x.Signbit() {
+		b.Neg(b)
+	}
+	return b
+}
+
+// Float80FromFloat64 widens a float64, which is always exact
+func Float80FromFloat64(f float64) Float80 {
+	bits := math.Float64bits(f)
+	sign := uint16(bits>>63) << 15
+	switch {
+	case math.IsNaN(f):
+		return Float80{SignExponent: sign | float80MaxExponent, Mantissa: float80IntegerBit | (bits&(1<<52-1))<<11}
+	case math.IsInf(f, 0):
+		return Float80{SignExponent: sign | float80MaxExponent, Mantissa: float80IntegerBit}
+	case f == 0:
+		return Float80{SignExponent: sign}
+	}
+	frac, exp := math.Frexp(math.Abs(f))
+	return Float80{SignExponent: sign | uint16(exp-1+float80Bias), Mantissa: uint64(frac * 0x1p64)}
+}
+
+// Float64 narrows x to a float64 under the control word's rounding mode, with the
+// same flags as ALUFloat64
+func (x Float80) Float64(control uint64) (out float64, flags uint64) {
+	switch {
+	case !x.isValid():
+		return math.NaN(), ALU_FLAGS_FPINVALID
+	case x.IsNaN():
+		// The top of the payload carries over and the result is quiet
+		bits := uint64(x.SignExponent>>15)<<63 | 0x7FF<<52 | 1<<51 | (x.Mantissa>>11)&(1<<52-1)
+		return math.Float64frombits(bits), 0
+	case x.IsInf() && x.Signbit():
+		return math.Inf(-1), ALU_FLAGS_NEGATIVE
+	case x.IsInf():
+		return math.Inf(1), 0
+	}
+	out, acc := x.toBig().Float64()
+	if math.IsInf(out, 0) {
+		out = overflowResult(x.Signbit(), control, math.MaxFloat64)
+		return out, ALU_FLAGS_FPOVERFLOW | ALU_FLAGS_FPINEXACT | floatResultFlags(out)
+	}
+	errSign := 0
+	switch acc {
+	case big.Below:
+		errSign = 1
+	case big.Above:
+		errSign = -1
+	}
+	out, flags = roundResult(out, errSign, control, math.Nextafter)
+	if math.IsInf(out, 0) {
+		flags |= ALU_FLAGS_FPOVERFLOW
+	}
+	if flags&ALU_FLAGS_FPINEXACT != 0 && math.Abs(out) < minNormalFloat64 {
+		flags |= ALU_FLAGS_FPUNDERFLOW
+	}
+	return out, flags | floatResultFlags(out)
+}
+
+// ALUFloat80 performs the extended precision ops under a float control word, raising
+// the same IEEE exceptions as ALUFloat64WithControl. FSQRT80 only uses parmA.
+func ALUFloat80(op int, parmA Float80, parmB Float80, control uint64) (outA Float80, outB Float80, flags uint64) {
+	info, ok := aluOps[op]
+	if !ok || info.float80Op == nil {
+		return Float80{}, Float80{}, ALU_FLAGS_ERROR | ALU_FLAGS_INVALIDOP
+	}
+	return info.float80Op(parmA, parmB, control)
+}
+
+func float80ResultFlags(x Float80) (flags uint64) {
+	if x.IsNaN() {
+		return 0
+	}
+	if x.IsZero() {
+		return ALU_FLAGS_ZERO
+	}
+	if x.Signbit() {
+		flags |= ALU_FLAGS_NEGATIVE
+	}
+	return flags
+}
+
+// roundFloat80 rounds t, which is the exact result truncated toward zero, to a Float80.
+// sticky says the exact result is a little larger in magnitude than t.
+func roundFloat80(t *big.Float, sticky bool, control uint64) (out Float80, flags uint64) {
+	negative := t.Signbit()
+	abs := new(big.Float).Abs(t)
+	// abs is in [2^e, 2^(e+1)), and q is the exponent of the last significand bit, which
+	// stops going down at the denormals
+	e := abs.MantExp(nil) - 1
+	q := max(e, float80MinExponent) - 63
+	scaled := new(big.Float).SetMantExp(abs, -q)
+	nInt, _ := scaled.Int(nil)
+	n := nInt.Uint64()
+	frac := new(big.Float).Sub(scaled, new(big.Float).SetUint64(n))
+	inexact := sticky || frac.Sign() != 0
+	roundUp := false
+	switch control & ALU_FPCW_ROUND_MASK {
+	case ALU_FPCW_ROUND_NEAREST:
+		half := frac.Cmp(big.NewFloat(0.5))
+		roundUp = half > 0 || (half == 0 && (sticky || n&1 != 0))
+	case ALU_FPCW_ROUND_UP:
+		roundUp = inexact && !negative
+	case ALU_FPCW_ROUND_DOWN:
+		roundUp = inexact && negative
+	}
+	if roundUp {
+		n++
+		if n == 0 {
+			n = float80IntegerBit
+			q++
+		}
+	}
+	biased := 0
+	if n&float80IntegerBit != 0 {
+		biased = q + 63 + float80Bias
+	}
+	if biased >= float80MaxExponent {
+		return float80Overflow(negative, control), ALU_FLAGS_FPOVERFLOW | ALU_FLAGS_FPINEXACT
+	}
+	out = Float80{SignExponent: float80Zero(negative).SignExponent | uint16(biased), Mantissa: n}
+	if inexact {
+		flags |= ALU_FLAGS_FPINEXACT
+		if biased == 0 {
+			flags |= ALU_FLAGS_FPUNDERFLOW
+		}
+	}
+	return out, flags
+}
+
+// float80Overflow is overflowResult for Float80
+func float80Overflow(negative bool, control uint64) Float80 {
+	maxFinite := Float80{SignExponent: float80Zero(negative).SignExponent | (float80MaxExponent - 1), Mantissa: ^uint64(0)}
+	switch control & ALU_FPCW_ROUND_MASK {
+	case ALU_FPCW_ROUND_ZERO:
+		return maxFinite
+	case ALU_FPCW_ROUND_UP:
+		if negative {
+			return maxFinite
+		}
+	case ALU_FPCW_ROUND_DOWN:
+		if !negative {
+			return maxFinite
+		}
+	}
+	return float80Inf(negative)
+}
+
+// float80Arith implements FADD80, FSUB80, FMULT80, FDIV80 and FSQRT80. The special
+// cases are settled first, then the finite result is computed truncated at
+// float80WorkPrecision bits and rounded once.
+func float80Arith(op int) float80OpFunc {
+	return func(parmA Float80, parmB Float80, control uint64) (outA Float80, outB Float80, flags uint64) {
+		if op == ALU_OP_FSQRT80 {
+			parmB = Float80{}
+		}
+		if op == ALU_OP_FSUB80 {
+			parmB = parmB.neg()
+		}
+		if !parmA.isValid() || !parmB.isValid() {
+			return Float80Indefinite, outB, ALU_FLAGS_FPINVALID
+		}
+		// NaNs propagate quietly, as they do in ALUFloat64
+		if parmA.IsNaN() {
+			parmA.Mantissa |= float80QuietBit
+			return parmA, outB, 0
+		}
+		if parmB.IsNaN() {
+			parmB.Mantissa |= float80QuietBit
+			return parmB, outB, 0
+		}
+		if outA, flags, done := float80Special(op, parmA, parmB); done {
+			return outA, outB, flags | float80ResultFlags(outA)
+		}
+		t := new(big.Float).SetPrec(float80WorkPrecision).SetMode(big.ToZero)
+		a, b := parmA.toBig(), parmB.toBig()
+		switch op {
+		case ALU_OP_FADD80, ALU_OP_FSUB80:
+			t.Add(a, b)
+		case ALU_OP_FMULT80:
+			t.Mul(a, b)
+		case ALU_OP_FDIV80:
+			t.Quo(a, b)
+		case ALU_OP_FSQRT80:
+			// Sqrt doesn't report its accuracy, so square the result to find which side
+			// of the root it landed on, stepping it down to truncate
+			t.Sqrt(a)
+			sq := new(big.Float).SetPrec(2*float80WorkPrecision).Mul(t, t)
+			if sq.Cmp(a) > 0 {
+				t.Sub(t, new(big.Float).SetMantExp(big.NewFloat(1), t.MantExp(nil)-float80WorkPrecision))
+				sq.Mul(t, t)
+			}
+			return float80Finish(t, sq.Cmp(a) != 0, control)
+		}
+		if t.Sign() == 0 {
+			// An exact zero sum is +0, or -0 when both addends are -0 or when rounding down
+			negative := parmA.Signbit() && parmB.Signbit()
+			if parmA.Signbit() != parmB.Signbit() && control&ALU_FPCW_ROUND_MASK == ALU_FPCW_ROUND_DOWN {
+				negative = true
+			}
+			outA = float80Zero(negative)
+			return outA, outB, float80ResultFlags(outA)
+		}
+		return float80Finish(t, t.Acc() != big.Exact, control)
+	}
+}
+
+func float80Finish(t *big.Float, sticky bool, control uint64) (outA Float80, outB Float80, flags uint64) {
+	outA, flags = roundFloat80(t, sticky, control)
+	return outA, outB, flags | float80ResultFlags(outA)
+}
+
+// float80Special settles the results involving infinities and zeroes that don't need
+// any arithmetic, the operands already being valid and not NaN
+func float80Special(op int, parmA Float80, parmB Float80) (outA Float80, flags uint64, done bool) {
+	signXor := parmA.Signbit() != parmB.Signbit()
+	switch op {
+	case ALU_OP_FADD80, ALU_OP_FSUB80:
+		switch {
+		case parmA.IsInf() && parmB.IsInf() && signXor:
+			return Float80Indefinite, ALU_FLAGS_FPINVALID, true
+		case parmA.IsInf():
+			return parmA, 0, true
+		case parmB.IsInf():
+			return parmB, 0, true
+		}
+	case ALU_OP_FMULT80:
+		switch {
+		case (parmA.IsInf() && parmB.IsZero()) || (parmA.IsZero() && parmB.IsInf()):
+			return Float80Indefinite, ALU_FLAGS_FPINVALID, true
+		case parmA.IsInf() || parmB.IsInf():
+			return float80Inf(signXor), 0, true
+		case parmA.IsZero() || parmB.IsZero():
+			return float80Zero(signXor), 0, true
+		}
+	case ALU_OP_FDIV80:
+		switch {
+		case (parmA.IsInf() && parmB.IsInf()) || (parmA.IsZero() && parmB.IsZero()):
+			return Float80Indefinite, ALU_FLAGS_FPINVALID, true
+		case parmA.IsInf():
+			return float80Inf(signXor), 0, true
+		case parmB.IsZero():
+			return float80Inf(signXor), ALU_FLAGS_DIVIDEBYZERO, true
+		case parmA.IsZero() || parmB.IsInf():
+			return float80Zero(signXor), 0, true
+		}
+	case ALU_OP_FSQRT80:
+		switch {
+		case parmA.IsZero():
+			return parmA, 0, true
+		case parmA.Signbit():
+			return Float80Indefinite, ALU_FLAGS_FPINVALID, true
+		case parmA.IsInf():
+			return parmA, 0, true
+		}
+	}
+	return Float80{}, 0, false
+}
+
+// float80Cmp sets flags as floatCmp does
+func float80Cmp(parmA Float80, parmB Float80, control uint64) (outA Float80, outB Float80, flags uint64) {
+	if !parmA.isValid() || !parmB.isValid() || parmA.IsNaN() || parmB.IsNaN() {
+		return parmA, parmB, ALU_FLAGS_UNORDERED
+	}
+	a, b := parmA.toBigOrInf(), parmB.toBigOrInf()
+	switch a.Cmp(b) {
+	case 0:
+		flags |= ALU_FLAGS_ZERO
+	case -1:
+		flags |= ALU_FLAGS_NEGATIVE
+		flags |= ALU_FLAGS_CARRY
+	}
+	return parmA, parmB, flags
+}
+
+func (x Float80) toBigOrInf() *big.Float {
+	if x.IsInf() {
+		return new(big.Float).SetInf(x.Signbit())
+	}
+	return x.toBig()
+}
@@ -0,0 +1,175 @@
+package Onyx1ALU
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+var float80One = Float80{SignExponent: 0x3FFF, Mantissa: 0x8000_0000_0000_0000}
+var float80Three = Float80{SignExponent: 0x4000, Mantissa: 0xC000_0000_0000_0000}
+var float80MinNormal = Float80{SignExponent: 0x0001, Mantissa: 0x8000_0000_0000_0000}
+var float80MinDenormal = Float80{SignExponent: 0x0000, Mantissa: 1}
+var float80Max = Float80{SignExponent: 0x7FFE, Mantissa: 0xFFFF_FFFF_FFFF_FFFF}
+
+func TestFloat80Arith(t *testing.T) {
+	// 1 + 2^-63 is exact in extended precision but not in float64
+	tiny := Float80{SignExponent: 0x3FFF - 63, Mantissa: 0x8000_0000_0000_0000}
+	outA, _, flags := ALUFloat80(ALU_OP_FADD80, float80One, tiny, ALU_FPCW_ROUND_NEAREST)
+	if outA != (Float80{0x3FFF, 0x8000_0000_0000_0001}) || flags != 0 {
+		t.Errorf("OP_FADD80 Expected 1+2^-63 exactly, got %x %b", outA, flags)
+	}
+	tiny.SignExponent--
+	outA, _, flags = ALUFloat80(ALU_OP_FADD80, float80One, tiny, ALU_FPCW_ROUND_NEAREST)
+	if outA != float80One || flags != ALU_FLAGS_FPINEXACT {
+		t.Errorf("OP_FADD80 Expected 1+2^-64 to round to even, got %x %b", outA, flags)
+	}
+	outA, _, _ = ALUFloat80(ALU_OP_FADD80, float80One, tiny, ALU_FPCW_ROUND_UP)
+	if outA != (Float80{0x3FFF, 0x8000_0000_0000_0001}) {
+		t.Errorf("OP_FADD80 Expected 1+2^-64 to round up, got %x", outA)
+	}
+	outA, _, flags = ALUFloat80(ALU_OP_FDIV80, float80One, float80Three, ALU_FPCW_ROUND_NEAREST)
+	if outA != (Float80{0x3FFD, 0xAAAA_AAAA_AAAA_AAAB}) || flags != ALU_FLAGS_FPINEXACT {
+		t.Errorf("OP_FDIV80 Expected 1/3, got %x %b", outA, flags)
+	}
+	outA, _, _ = ALUFloat80(ALU_OP_FDIV80, float80One, float80Three.neg(), ALU_FPCW_ROUND_ZERO)
+	if outA != (Float80{0xBFFD, 0xAAAA_AAAA_AAAA_AAAA}) {
+		t.Errorf("OP_FDIV80 Expected -1/3 truncated, got %x", outA)
+	}
+	outA, _, flags = ALUFloat80(ALU_OP_FSQRT80, Float80FromFloat64(2), Float80{}, ALU_FPCW_ROUND_NEAREST)
+	if outA != (Float80{0x3FFF, 0xB504_F333_F9DE_6484}) || flags != ALU_FLAGS_FPINEXACT {
+		t.Errorf("OP_FSQRT80 Expected sqrt(2), got %x %b", outA, flags)
+	}
+	outA, _, flags = ALUFloat80(ALU_OP_FSQRT80, Float80FromFloat64(9), Float80{}, ALU_FPCW_ROUND_NEAREST)
+	if outA != float80Three || flags != 0 {
+		t.Errorf("OP_FSQRT80 Expected 3 exactly, got %x %b", outA, flags)
+	}
+	outA, _, flags = ALUFloat80(ALU_OP_FSUB80, float80Three, float80Three, ALU_FPCW_ROUND_DOWN)
+	if outA != float80Zero(true) || flags != ALU_FLAGS_ZERO {
+		t.Errorf("OP_FSUB80 Expected -0 rounding down, got %x %b", outA, flags)
+	}
+}
+
+func TestFloat80Exceptions(t *testing.T) {
+	outA, _, flags := ALUFloat80(ALU_OP_FMULT80, float80Max, Float80FromFloat64(2), ALU_FPCW_ROUND_NEAREST)
+	if !outA.IsInf() || flags != ALU_FLAGS_FPOVERFLOW|ALU_FLAGS_FPINEXACT {
+		t.Errorf("OP_FMULT80 Expected Inf with OVERFLOW, got %x %b", outA, flags)
+	}
+	outA, _, _ = ALUFloat80(ALU_OP_FMULT80, float80Max, Float80FromFloat64(2), ALU_FPCW_ROUND_ZERO)
+	if outA != float80Max {
+		t.Errorf("OP_FMULT80 Expected the largest finite value rounding to zero, got %x", outA)
+	}
+	outA, _, flags = ALUFloat80(ALU_OP_FDIV80, float80MinNormal, Float80FromFloat64(2), ALU_FPCW_ROUND_NEAREST)
+	if outA != (Float80{0, 0x4000_0000_0000_0000}) || flags != 0 {
+		t.Errorf("OP_FDIV80 Expected an exact denormal, got %x %b", outA, flags)
+	}
+	outA, _, flags = ALUFloat80(ALU_OP_FDIV80, float80MinDenormal, Float80FromFloat64(2), ALU_FPCW_ROUND_NEAREST)
+	if !outA.IsZero() || flags != ALU_FLAGS_FPUNDERFLOW|ALU_FLAGS_FPINEXACT|ALU_FLAGS_ZERO {
+		t.Errorf("OP_FDIV80 Expected 0 with UNDERFLOW, got %x %b", outA, flags)
+	}
+	outA, _, _ = ALUFloat80(ALU_OP_FDIV80, float80MinDenormal, Float80FromFloat64(2), ALU_FPCW_ROUND_UP)
+	if outA != float80MinDenormal {
+		t.Errorf("OP_FDIV80 Expected the smallest denormal rounding up, got %x", outA)
+	}
+	outA, _, flags = ALUFloat80(ALU_OP_FSUB80, float80MinNormal, float80MinDenormal, ALU_FPCW_ROUND_NEAREST)
+	if outA != (Float80{0, 0x7FFF_FFFF_FFFF_FFFF}) || flags != 0 {
+		t.Errorf("OP_FSUB80 Expected the largest denormal, got %x %b", outA, flags)
+	}
+	outA, _, flags = ALUFloat80(ALU_OP_FDIV80, float80One.neg(), Float80{}, ALU_FPCW_ROUND_NEAREST)
+	if outA != float80Inf(true) || flags != ALU_FLAGS_DIVIDEBYZERO|ALU_FLAGS_NEGATIVE {
+		t.Errorf("OP_FDIV80 Expected -Inf with DIVIDEBYZERO, got %x %b", outA, flags)
+	}
+	outA, _, flags = ALUFloat80(ALU_OP_FSUB80, float80Inf(false), float80Inf(false), ALU_FPCW_ROUND_NEAREST)
+	if !outA.IsNaN() || flags != ALU_FLAGS_FPINVALID {
+		t.Errorf("OP_FSUB80 Expected NaN with FPINVALID, got %x %b", outA, flags)
+	}
+	outA, _, flags = ALUFloat80(ALU_OP_FSQRT80, float80One.neg(), Float80{}, ALU_FPCW_ROUND_NEAREST)
+	if !outA.IsNaN() || flags != ALU_FLAGS_FPINVALID {
+		t.Errorf("OP_FSQRT80 Expected NaN with FPINVALID, got %x %b", outA, flags)
+	}
+	unnormal := Float80{SignExponent: 0x3FFF, Mantissa: 0x4000_0000_0000_0000}
+	_, _, flags = ALUFloat80(ALU_OP_FADD80, unnormal, float80One, ALU_FPCW_ROUND_NEAREST)
+	if flags != ALU_FLAGS_FPINVALID {
+		t.Errorf("OP_FADD80 Expected FPINVALID for an unnormal, got %b", flags)
+	}
+	_, _, flags = ALUFloat80(ALU_OP_FCMP80, float80One, float80Three, ALU_FPCW_ROUND_NEAREST)
+	if flags != ALU_FLAGS_NEGATIVE|ALU_FLAGS_CARRY {
+		t.Errorf("OP_FCMP80 Expected 1 < 3, got %b", flags)
+	}
+}
+
+func TestFloat80Conversions(t *testing.T) {
+	for _, f := range []float64{0.1, -3.75, math.MaxFloat64, 5e-324, math.Inf(-1)} {
+		back, flags := Float80FromFloat64(f).Float64(ALU_FPCW_ROUND_NEAREST)
+		if back != f || flags&ALU_FLAGS_FPINEXACT != 0 {
+			t.Errorf("Float80 Expected %g to round trip, got %g %b", f, back, flags)
+		}
+	}
+	third, _, _ := ALUFloat80(ALU_OP_FDIV80, float80One, float80Three, ALU_FPCW_ROUND_NEAREST)
+	f, flags := third.Float64(ALU_FPCW_ROUND_NEAREST)
+	if f != 1.0/3 || flags != ALU_FLAGS_FPINEXACT {
+		t.Errorf("Float80 Expected 1/3 as a float64, got %g %b", f, flags)
+	}
+	f, flags = float80Max.Float64(ALU_FPCW_ROUND_DOWN)
+	if f != math.MaxFloat64 || flags != ALU_FLAGS_FPOVERFLOW|ALU_FLAGS_FPINEXACT {
+		t.Errorf("Float80 Expected MaxFloat64 rounding down, got %g %b", f, flags)
+	}
+	f, flags = float80MinNormal.Float64(ALU_FPCW_ROUND_UP)
+	if f != 5e-324 || flags != ALU_FLAGS_FPUNDERFLOW|ALU_FLAGS_FPINEXACT {
+		t.Errorf("Float80 Expected the smallest denormal rounding up, got %g %b", f, flags)
+	}
+	if !Float80FromFloat64(math.NaN()).IsNaN() {
+		t.Errorf("Float80 Expected NaN to stay NaN")
+	}
+}
+
+// float80Big is the test's own exact conversion of a normal Float80
+func float80Big(x Float80) *big.Float {
+	b := new(big.Float).SetUint64(x.Mantissa)
+	b.SetMantExp(b, int(x.SignExponent&0x7FFF)-16383-63)
+	if x.SignExponent&0x8000 != 0 {
+		b.Neg(b)
+	}
+	return b
+}
+
+func TestFloat80AgainstBigFloat(t *testing.T) {
+	modes := map[uint64]big.RoundingMode{
+		ALU_FPCW_ROUND_NEAREST: big.ToNearestEven,
+		ALU_FPCW_ROUND_ZERO:    big.ToZero,
+		ALU_FPCW_ROUND_UP:      big.ToPositiveInf,
+		ALU_FPCW_ROUND_DOWN:    big.ToNegativeInf,
+	}
+	r := rand.New(rand.NewSource(3))
+	random := func() Float80 {
+		return Float80{SignExponent: uint16(r.Intn(2))<<15 | uint16(0x3FFF-40+r.Intn(80)), Mantissa: r.Uint64() | 1<<63}
+	}
+	for i := 0; i < 2000; i++ {
+		a, b := random(), random()
+		for control, mode := range modes {
+			for _, op := range []int{ALU_OP_FADD80, ALU_OP_FSUB80, ALU_OP_FMULT80, ALU_OP_FDIV80, ALU_OP_FSQRT80} {
+				want := new(big.Float).SetPrec(64).SetMode(mode)
+				switch op {
+				case ALU_OP_FADD80:
+					want.Add(float80Big(a), float80Big(b))
+				case ALU_OP_FSUB80:
+					want.Sub(float80Big(a), float80Big(b))
+				case ALU_OP_FMULT80:
+					want.Mul(float80Big(a), float80Big(b))
+				case ALU_OP_FDIV80:
+					want.Quo(float80Big(a), float80Big(b))
+				case ALU_OP_FSQRT80:
+					// big.Float.Sqrt only rounds correctly to nearest, so take a much
+					// wider root and round that
+					a.SignExponent &= 0x7FFF
+					want.Set(new(big.Float).SetPrec(300).Sqrt(float80Big(a)))
+				}
+				outA, _, _ := ALUFloat80(op, a, b, control)
+				if float80Big(outA).Cmp(want) != 0 {
+					t.Errorf("ALUFloat80 op %d mode %d Expected %g, got %g", op, control, want, float80Big(outA))
+				}
+			}
+		}
+	}
+}
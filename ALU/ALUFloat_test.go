@@ -0,0 +1,187 @@
+package Onyx1ALU
+
+import (
+	"math"
+	"testing"
+)
+
+func TestALUFloat64Rounding(t *testing.T) {
+	nearest, _, flags := ALUFloat64(ALU_OP_FDIV64, 1, 3)
+	if nearest != 1.0/3.0 || flags&ALU_FLAGS_FPINEXACT == 0 {
+		t.Errorf("OP_FDIV64 Expected 1/3 INEXACT, got %v %b", nearest, flags)
+	}
+	up, _, _ := ALUFloat64WithControl(ALU_OP_FDIV64, 1, 3, ALU_FPCW_ROUND_UP)
+	down, _, _ := ALUFloat64WithControl(ALU_OP_FDIV64, 1, 3, ALU_FPCW_ROUND_DOWN)
+	zero, _, _ := ALUFloat64WithControl(ALU_OP_FDIV64, 1, 3, ALU_FPCW_ROUND_ZERO)
+	if up != math.Nextafter(nearest, 1) || down != nearest || zero != nearest {
+		t.Errorf("OP_FDIV64 Expected rounding 1/3 up to the next value, got %v %v %v", up, down, zero)
+	}
+	down, _, _ = ALUFloat64WithControl(ALU_OP_FDIV64, -1, 3, ALU_FPCW_ROUND_DOWN)
+	if down != math.Nextafter(-nearest, -1) {
+		t.Errorf("OP_FDIV64 Expected -1/3 rounded down, got %v", down)
+	}
+	outA, _, flags := ALUFloat64WithControl(ALU_OP_FADD64, 1, 0x1p-60, ALU_FPCW_ROUND_UP)
+	if outA != math.Nextafter(1, 2) || flags&ALU_FLAGS_FPINEXACT == 0 {
+		t.Errorf("OP_FADD64 Expected 1+ulp INEXACT, got %v %b", outA, flags)
+	}
+	outA, _, flags = ALUFloat64WithControl(ALU_OP_FSUB64, 1, 0x1p-60, ALU_FPCW_ROUND_ZERO)
+	if outA != math.Nextafter(1, 0) {
+		t.Errorf("OP_FSUB64 Expected 1-ulp, got %v %b", outA, flags)
+	}
+	outA, _, flags = ALUFloat64WithControl(ALU_OP_FSQRT64, 2, 0, ALU_FPCW_ROUND_DOWN)
+	if outA != math.Nextafter(math.Sqrt2, 0) || flags&ALU_FLAGS_FPINEXACT == 0 {
+		t.Errorf("OP_FSQRT64 Expected sqrt(2) rounded down, got %v %b", outA, flags)
+	}
+	outA, _, flags = ALUFloat64(ALU_OP_FADD64, 1.5, 2.25)
+	if outA != 3.75 || flags&ALU_FLAGS_FPINEXACT != 0 {
+		t.Errorf("OP_FADD64 Expected exact 3.75, got %v %b", outA, flags)
+	}
+	outA, _, _ = ALUFloat64WithControl(ALU_OP_FSUB64, 1, 1, ALU_FPCW_ROUND_DOWN)
+	if outA != 0 || !math.Signbit(outA) {
+		t.Errorf("OP_FSUB64 Expected -0 rounding down, got %v", outA)
+	}
+}
+
+func TestALUFloat64Exceptions(t *testing.T) {
+	outA, _, flags := ALUFloat64(ALU_OP_FDIV64, -1, 0)
+	if !math.IsInf(outA, -1) || flags&ALU_FLAGS_DIVIDEBYZERO == 0 || flags&ALU_FLAGS_ERROR != 0 {
+		t.Errorf("OP_FDIV64 Expected -Inf DIVIDEBYZERO, got %v %b", outA, flags)
+	}
+	outA, _, flags = ALUFloat64(ALU_OP_FDIV64, 0, 0)
+	if !math.IsNaN(outA) || flags&ALU_FLAGS_FPINVALID == 0 {
+		t.Errorf("OP_FDIV64 Expected NaN INVALID, got %v %b", outA, flags)
+	}
+	outA, _, flags = ALUFloat64(ALU_OP_FSQRT64, -1, 0)
+	if !math.IsNaN(outA) || flags&ALU_FLAGS_FPINVALID == 0 {
+		t.Errorf("OP_FSQRT64 Expected NaN INVALID, got %v %b", outA, flags)
+	}
+	outA, _, flags = ALUFloat64(ALU_OP_FADD64, math.NaN(), 1)
+	if !math.IsNaN(outA) || flags&ALU_FLAGS_FPINVALID != 0 {
+		t.Errorf("OP_FADD64 Expected quiet NaN propagation, got %v %b", outA, flags)
+	}
+	outA, _, flags = ALUFloat64(ALU_OP_FMULT64, math.MaxFloat64, 2)
+	if !math.IsInf(outA, 1) || flags&ALU_FLAGS_FPOVERFLOW == 0 || flags&ALU_FLAGS_FPINEXACT == 0 {
+		t.Errorf("OP_FMULT64 Expected +Inf OVERFLOW, got %v %b", outA, flags)
+	}
+	outA, _, flags = ALUFloat64WithControl(ALU_OP_FMULT64, math.MaxFloat64, 2, ALU_FPCW_ROUND_ZERO)
+	if outA != math.MaxFloat64 || flags&ALU_FLAGS_FPOVERFLOW == 0 {
+		t.Errorf("OP_FMULT64 Expected MaxFloat64 OVERFLOW, got %v %b", outA, flags)
+	}
+	outA, _, flags = ALUFloat64(ALU_OP_FDIV64, 0x1p-1022, 3)
+	if flags&ALU_FLAGS_FPUNDERFLOW == 0 || flags&ALU_FLAGS_FPINEXACT == 0 {
+		t.Errorf("OP_FDIV64 Expected UNDERFLOW, got %v %b", outA, flags)
+	}
+	up, _, _ := ALUFloat64WithControl(ALU_OP_FMULT64, 0x1p-1070, 0x1.4p-3, ALU_FPCW_ROUND_UP)
+	down, _, flags := ALUFloat64WithControl(ALU_OP_FMULT64, 0x1p-1070, 0x1.4p-3, ALU_FPCW_ROUND_DOWN)
+	if up != 0x1.8p-1073 || down != 0x1p-1073 || flags&ALU_FLAGS_FPUNDERFLOW == 0 {
+		t.Errorf("OP_FMULT64 Expected subnormal rounding, got %v %v %b", up, down, flags)
+	}
+	outA, _, flags = ALUFloat64(ALU_OP_FEXP64, 1000, 0)
+	if !math.IsInf(outA, 1) || flags&ALU_FLAGS_FPOVERFLOW == 0 {
+		t.Errorf("OP_FEXP64 Expected +Inf OVERFLOW, got %v %b", outA, flags)
+	}
+	outA, _, flags = ALUFloat64(ALU_OP_FLN64, 0, 0)
+	if !math.IsInf(outA, -1) || flags&ALU_FLAGS_DIVIDEBYZERO == 0 {
+		t.Errorf("OP_FLN64 Expected -Inf DIVIDEBYZERO, got %v %b", outA, flags)
+	}
+	outA, _, flags = ALUFloat64(ALU_OP_FSIN64, math.Inf(1), 0)
+	if !math.IsNaN(outA) || flags&ALU_FLAGS_FPINVALID == 0 {
+		t.Errorf("OP_FSIN64 Expected NaN INVALID, got %v %b", outA, flags)
+	}
+	outA, _, flags = ALUFloat64(ALU_OP_FSIN64, 0, 0)
+	if outA != 0 || flags&ALU_FLAGS_ZERO == 0 {
+		t.Errorf("OP_FSIN64 Expected 0 ZERO, got %v %b", outA, flags)
+	}
+}
+
+func TestALUFloat32(t *testing.T) {
+	outA, outB, flags := ALUFloat32(ALU_OP_FADD32, 1.5, 2.25)
+	if outA != 3.75 || flags&ALU_FLAGS_FPINEXACT != 0 {
+		t.Errorf("OP_FADD32 Expected 3.75, got %v %v %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUFloat32(ALU_OP_FDIV32, 1, 3)
+	if outA != float32(1.0)/float32(3.0) || flags&ALU_FLAGS_FPINEXACT == 0 {
+		t.Errorf("OP_FDIV32 Expected 1/3 INEXACT, got %v %v %b", outA, outB, flags)
+	}
+	up, _, _ := ALUFloat32WithControl(ALU_OP_FDIV32, 1, 3, ALU_FPCW_ROUND_UP)
+	down, _, _ := ALUFloat32WithControl(ALU_OP_FDIV32, 1, 3, ALU_FPCW_ROUND_DOWN)
+	if up <= down || math.Nextafter32(down, 1) != up {
+		t.Errorf("OP_FDIV32 Expected 1/3 bracketed by one ulp, got %v %v", up, down)
+	}
+	outA, outB, flags = ALUFloat32(ALU_OP_FMULT32, math.MaxFloat32, 2)
+	if !math.IsInf(float64(outA), 1) || flags&ALU_FLAGS_FPOVERFLOW == 0 {
+		t.Errorf("OP_FMULT32 Expected +Inf OVERFLOW, got %v %v %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUFloat32WithControl(ALU_OP_FMULT32, math.MaxFloat32, 2, ALU_FPCW_ROUND_ZERO)
+	if outA != math.MaxFloat32 || flags&ALU_FLAGS_FPOVERFLOW == 0 {
+		t.Errorf("OP_FMULT32 Expected MaxFloat32 OVERFLOW, got %v %v %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUFloat32(ALU_OP_FDIV32, 0x1p-126, 3)
+	if flags&ALU_FLAGS_FPUNDERFLOW == 0 {
+		t.Errorf("OP_FDIV32 Expected UNDERFLOW, got %v %v %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUFloat32(ALU_OP_FSQRT32, -4, 0)
+	if !math.IsNaN(float64(outA)) || flags&ALU_FLAGS_FPINVALID == 0 {
+		t.Errorf("OP_FSQRT32 Expected NaN INVALID, got %v %v %b", outA, outB, flags)
+	}
+	_, _, flags = ALUFloat32(ALU_OP_FCMP32, 1, 2)
+	if flags&ALU_FLAGS_CARRY == 0 {
+		t.Errorf("OP_FCMP32 Expected CARRY, got %b", flags)
+	}
+}
+
+func TestALUFloat64FMA(t *testing.T) {
+	outA, flags := ALUFloat64FMA(2, 3, 4, ALU_FPCW_ROUND_NEAREST)
+	if outA != 10 || flags&ALU_FLAGS_FPINEXACT != 0 {
+		t.Errorf("OP_FMA64 Expected 10, got %v %b", outA, flags)
+	}
+	// (1+2^-30)^2 - 1 is exact with one rounding but loses 2^-60 with two
+	a := 1 + 0x1p-30
+	outA, flags = ALUFloat64FMA(a, a, -1, ALU_FPCW_ROUND_NEAREST)
+	if outA != 0x1p-29+0x1p-60 || flags&ALU_FLAGS_FPINEXACT != 0 {
+		t.Errorf("OP_FMA64 Expected single rounding, got %v %b", outA, flags)
+	}
+	outA, _ = ALUFloat64FMA(1, 1, 0x1p-60, ALU_FPCW_ROUND_UP)
+	if outA != math.Nextafter(1, 2) {
+		t.Errorf("OP_FMA64 Expected 1+ulp rounding up, got %v", outA)
+	}
+	outA, _ = ALUFloat64FMA(1, 1, 0x1p-60, ALU_FPCW_ROUND_DOWN)
+	if outA != 1 {
+		t.Errorf("OP_FMA64 Expected 1 rounding down, got %v", outA)
+	}
+	outA, flags = ALUFloat64FMA(math.Inf(1), 0, 1, ALU_FPCW_ROUND_NEAREST)
+	if !math.IsNaN(outA) || flags&ALU_FLAGS_FPINVALID == 0 {
+		t.Errorf("OP_FMA64 Expected NaN INVALID, got %v %b", outA, flags)
+	}
+	outA, flags = ALUFloat64FMA(math.MaxFloat64, 2, 0, ALU_FPCW_ROUND_ZERO)
+	if outA != math.MaxFloat64 || flags&ALU_FLAGS_FPOVERFLOW == 0 {
+		t.Errorf("OP_FMA64 Expected MaxFloat64 OVERFLOW, got %v %b", outA, flags)
+	}
+}
+
+func TestALUFloat64MinMaxNum(t *testing.T) {
+	outA, _, _ := ALUFloat64(ALU_OP_FMIN64, math.NaN(), 3)
+	if outA != 3 {
+		t.Errorf("OP_FMIN64 Expected 3 ignoring NaN, got %v", outA)
+	}
+	outA, _, _ = ALUFloat64(ALU_OP_FMAX64, 3, math.NaN())
+	if outA != 3 {
+		t.Errorf("OP_FMAX64 Expected 3 ignoring NaN, got %v", outA)
+	}
+	outA, _, _ = ALUFloat64(ALU_OP_FMIN64, 0, math.Copysign(0, -1))
+	if !math.Signbit(outA) {
+		t.Errorf("OP_FMIN64 Expected -0, got %v", outA)
+	}
+	outA, _, flags := ALUFloat64(ALU_OP_FREM64, 1, 0)
+	if !math.IsNaN(outA) || flags&ALU_FLAGS_FPINVALID == 0 {
+		t.Errorf("OP_FREM64 Expected NaN INVALID, got %v %b", outA, flags)
+	}
+	outA, _, flags = ALUFloat64(ALU_OP_FPOW64, 0, -1)
+	if !math.IsInf(outA, 1) || flags&ALU_FLAGS_DIVIDEBYZERO == 0 {
+		t.Errorf("OP_FPOW64 Expected +Inf DIVIDEBYZERO, got %v %b", outA, flags)
+	}
+	outA, _, flags = ALUFloat64(ALU_OP_FPOW64, 10, 400)
+	if !math.IsInf(outA, 1) || flags&ALU_FLAGS_FPOVERFLOW == 0 {
+		t.Errorf("OP_FPOW64 Expected +Inf OVERFLOW, got %v %b", outA, flags)
+	}
+}
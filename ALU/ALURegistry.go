@@ -0,0 +1,371 @@
+package Onyx1ALU
+
+import (
+	"GolangCPUParts/Configuration"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// Operand kinds, telling which entry point an op belongs to
+const (
+	ALU_KIND_INT      = 1
+	ALU_KIND_FLOAT64  = 2
+	ALU_KIND_FLOAT32  = 3
+	ALU_KIND_CONVERT  = 4
+	ALU_KIND_DECIMAL  = 5
+	ALU_KIND_VECTOR   = 6
+	ALU_KIND_CHECKSUM = 7
+	ALU_KIND_AES      = 8
+	ALU_KIND_BIGINT   = 9
+	ALU_KIND_FLOAT80  = 10
+	ALU_KIND_FIXED    = 11
+)
+
+var ALUKindNames = []string{"", "INT", "FLOAT64", "FLOAT32", "CONVERT", "DECIMAL", "VECTOR", "CHECKSUM", "AES", "BIGINT", "FLOAT80", "FIXED"}
+
+type intOpFunc func(width int, parmA int64, parmB int64, flagsIn uint64) (outA int64, outB int64, flags uint64)
+type float64OpFunc func(parmA float64, parmB float64, control uint64) (outA float64, outB float64, flags uint64)
+type float32OpFunc func(parmA float32, parmB float32, control uint64) (outA float32, outB float32, flags uint64)
+type convertOpFunc func(parm uint64, control uint64) (out uint64, flags uint64)
+type vectorOpFunc func(l vectorLane) (r uint64, saturated bool)
+type decimalOpFunc func(parmA []byte, parmB []byte) (outA []byte, outB []byte, flags uint64)
+type checksumOpFunc func(crc uint64, p []byte) uint64
+type aesOpFunc func(state [16]byte, key [16]byte) [16]byte
+type bigIntOpFunc func(parmA []uint64, parmB []uint64) (outA []uint64, outB []uint64, flags uint64)
+type float80OpFunc func(parmA Float80, parmB Float80, control uint64) (outA Float80, outB Float80, flags uint64)
+type fixedOpFunc func(parmA *big.Int, parmB *big.Int, frac uint, round bool) (r *big.Int, flags uint64)
+type ternaryOpFunc func(parmA uint64, parmB uint64, parmC uint64, control uint64) (out uint64, flags uint64)
+
+// ALUOpInfo describes one ALU op. Arity is the number of source operands and Results the
+// number of outputs, 0 for the compares that only set flags and 2 when outB is a result.
+// Cycles is the default cost of the op in machine cycles.
+type ALUOpInfo struct {
+	Op       int
+	Mnemonic string
+	Kind     int
+	Arity    int
+	Results  int
+	Cycles   uint64
+
+	// Exactly one implementation is set, matching Kind, or ternaryOp for the three operand ops
+	intOp      intOpFunc
+	float64Op  float64OpFunc
+	float32Op  float32OpFunc
+	convertOp  convertOpFunc
+	vectorOp   vectorOpFunc
+	decimalOp  decimalOpFunc
+	checksumOp checksumOpFunc
+	aesOp      aesOpFunc
+	bigIntOp   bigIntOpFunc
+	float80Op  float80OpFunc
+	fixedOp    fixedOpFunc
+	ternaryOp  ternaryOpFunc
+}
+
+var aluOps = map[int]*ALUOpInfo{}
+var aluMnemonics = map[string]*ALUOpInfo{}
+
+func registerALUOps(infos ...ALUOpInfo) {
+	for i := range infos {
+		info := infos[i]
+		if _, ok := aluOps[info.Op]; ok {
+			panic("duplicate ALU op " + info.Mnemonic)
+		}
+		if _, ok := aluMnemonics[info.Mnemonic]; ok {
+			panic("duplicate ALU mnemonic " + info.Mnemonic)
+		}
+		aluOps[info.Op] = &info
+		aluMnemonics[info.Mnemonic] = &info
+	}
+}
+
+func intOpInfo(op int, mnemonic string, arity int, results int, cycles uint64, f intOpFunc) ALUOpInfo {
+	return ALUOpInfo{Op: op, Mnemonic: mnemonic, Kind: ALU_KIND_INT, Arity: arity, Results: results, Cycles: cycles, intOp: f}
+}
+
+func float64OpInfo(op int, mnemonic string, arity int, results int, cycles uint64, f float64OpFunc) ALUOpInfo {
+	return ALUOpInfo{Op: op, Mnemonic: mnemonic, Kind: ALU_KIND_FLOAT64, Arity: arity, Results: results, Cycles: cycles, float64Op: f}
+}
+
+func float32OpInfo(op int, mnemonic string, arity int, results int, cycles uint64, f float32OpFunc) ALUOpInfo {
+	return ALUOpInfo{Op: op, Mnemonic: mnemonic, Kind: ALU_KIND_FLOAT32, Arity: arity, Results: results, Cycles: cycles, float32Op: f}
+}
+
+func convertOpInfo(op int, mnemonic string, cycles uint64, f convertOpFunc) ALUOpInfo {
+	return ALUOpInfo{Op: op, Mnemonic: mnemonic, Kind: ALU_KIND_CONVERT, Arity: 1, Results: 1, Cycles: cycles, convertOp: f}
+}
+
+func vectorOpInfo(op int, mnemonic string, cycles uint64, f vectorOpFunc) ALUOpInfo {
+	return ALUOpInfo{Op: op, Mnemonic: mnemonic, Kind: ALU_KIND_VECTOR, Arity: 2, Results: 1, Cycles: cycles, vectorOp: f}
+}
+
+func decimalOpInfo(op int, mnemonic string, arity int, results int, cycles uint64, f decimalOpFunc) ALUOpInfo {
+	return ALUOpInfo{Op: op, Mnemonic: mnemonic, Kind: ALU_KIND_DECIMAL, Arity: arity, Results: results, Cycles: cycles, decimalOp: f}
+}
+
+func checksumOpInfo(op int, mnemonic string, cycles uint64, f checksumOpFunc) ALUOpInfo {
+	return ALUOpInfo{Op: op, Mnemonic: mnemonic, Kind: ALU_KIND_CHECKSUM, Arity: 2, Results: 1, Cycles: cycles, checksumOp: f}
+}
+
+func aesOpInfo(op int, mnemonic string, arity int, cycles uint64, f aesOpFunc) ALUOpInfo {
+	return ALUOpInfo{Op: op, Mnemonic: mnemonic, Kind: ALU_KIND_AES, Arity: arity, Results: 1, Cycles: cycles, aesOp: f}
+}
+
+func bigIntOpInfo(op int, mnemonic string, results int, cycles uint64, f bigIntOpFunc) ALUOpInfo {
+	return ALUOpInfo{Op: op, Mnemonic: mnemonic, Kind: ALU_KIND_BIGINT, Arity: 2, Results: results, Cycles: cycles, bigIntOp: f}
+}
+
+func float80OpInfo(op int, mnemonic string, arity int, results int, cycles uint64, f float80OpFunc) ALUOpInfo {
+	return ALUOpInfo{Op: op, Mnemonic: mnemonic, Kind: ALU_KIND_FLOAT80, Arity: arity, Results: results, Cycles: cycles, float80Op: f}
+}
+
+func fixedOpInfo(op int, mnemonic string, arity int, cycles uint64, f fixedOpFunc) ALUOpInfo {
+	return ALUOpInfo{Op: op, Mnemonic: mnemonic, Kind: ALU_KIND_FIXED, Arity: arity, Results: 1, Cycles: cycles, fixedOp: f}
+}
+
+func ternaryOpInfo(op int, mnemonic string, kind int, cycles uint64, f ternaryOpFunc) ALUOpInfo {
+	return ALUOpInfo{Op: op, Mnemonic: mnemonic, Kind: kind, Arity: 3, Results: 1, Cycles: cycles, ternaryOp: f}
+}
+
+func bitFieldInsert(parmA uint64, parmB uint64, parmC uint64, control uint64) (out uint64, flags uint64) {
+	outA, flags := ALUBitFieldInsert(int64(parmA), int64(parmB), int64(parmC))
+	return uint64(outA), flags
+}
+
+func fusedMultiplyAdd(parmA uint64, parmB uint64, parmC uint64, control uint64) (out uint64, flags uint64) {
+	outA, flags := ALUFloat64FMA(math.Float64frombits(parmA), math.Float64frombits(parmB), math.Float64frombits(parmC), control)
+	return math.Float64bits(outA), flags
+}
+
+// The registry is filled in init so the implementations can call back into the entry points
+func init() {
+	registerALUOps(
+		intOpInfo(ALU_OP_ADDINT64, "ADD", 2, 1, 1, intAdd),
+		intOpInfo(ALU_OP_SUBINT64, "SUB", 2, 1, 1, intSub),
+		intOpInfo(ALU_OP_MULTINT64, "MUL", 2, 1, 4, intMul),
+		intOpInfo(ALU_OP_DIVINT64, "DIV", 2, 2, 40, intDiv),
+		intOpInfo(ALU_OP_ANDINT64, "AND", 2, 1, 1, intAnd),
+		intOpInfo(ALU_OP_NOTINT64, "NOT", 1, 1, 1, intNot),
+		intOpInfo(ALU_OP_ORINT64, "OR", 2, 1, 1, intOr),
+		intOpInfo(ALU_OP_XORINT64, "XOR", 2, 1, 1, intXor),
+		intOpInfo(ALU_OP_SHLINT64, "SHL", 2, 1, 1, intShl),
+		intOpInfo(ALU_OP_SHRINT64, "SHR", 2, 1, 1, intShr),
+		intOpInfo(ALU_OP_ADCINT64, "ADC", 2, 1, 1, intAdc),
+		intOpInfo(ALU_OP_SBCINT64, "SBC", 2, 1, 1, intSbc),
+		intOpInfo(ALU_OP_UADDINT64, "UADD", 2, 1, 1, intUadd),
+		intOpInfo(ALU_OP_USUBINT64, "USUB", 2, 1, 1, intUsub),
+		intOpInfo(ALU_OP_UMULINT64, "UMUL", 2, 2, 4, intUmul),
+		intOpInfo(ALU_OP_UDIVINT64, "UDIV", 2, 2, 40, intUdiv),
+		intOpInfo(ALU_OP_SHRLINT64, "SHRL", 2, 1, 1, intShrl),
+		intOpInfo(ALU_OP_ROLINT64, "ROL", 2, 1, 1, withOp(ALU_OP_ROLINT64, rotateWidth)),
+		intOpInfo(ALU_OP_RORINT64, "ROR", 2, 1, 1, withOp(ALU_OP_RORINT64, rotateWidth)),
+		intOpInfo(ALU_OP_RCLINT64, "RCL", 2, 1, 1, withOp(ALU_OP_RCLINT64, rotateWidth)),
+		intOpInfo(ALU_OP_RCRINT64, "RCR", 2, 1, 1, withOp(ALU_OP_RCRINT64, rotateWidth)),
+		intOpInfo(ALU_OP_POPCNT64, "POPCNT", 1, 1, 2, withOp(ALU_OP_POPCNT64, countBitsWidth)),
+		intOpInfo(ALU_OP_CLZINT64, "CLZ", 1, 1, 2, withOp(ALU_OP_CLZINT64, countBitsWidth)),
+		intOpInfo(ALU_OP_CTZINT64, "CTZ", 1, 1, 2, withOp(ALU_OP_CTZINT64, countBitsWidth)),
+		intOpInfo(ALU_OP_BTINT64, "BT", 2, 1, 1, withOp(ALU_OP_BTINT64, testBitWidth)),
+		intOpInfo(ALU_OP_BTSINT64, "BTS", 2, 1, 1, withOp(ALU_OP_BTSINT64, testBitWidth)),
+		intOpInfo(ALU_OP_BTRINT64, "BTR", 2, 1, 1, withOp(ALU_OP_BTRINT64, testBitWidth)),
+		intOpInfo(ALU_OP_BTCINT64, "BTC", 2, 1, 1, withOp(ALU_OP_BTCINT64, testBitWidth)),
+		intOpInfo(ALU_OP_BFEXTINT64, "BFEXT", 2, 1, 2, extractBitField),
+		ternaryOpInfo(ALU_OP_BFINSINT64, "BFINS", ALU_KIND_INT, 2, bitFieldInsert),
+		intOpInfo(ALU_OP_CMPINT64, "CMP", 2, 0, 1, intCmp),
+		intOpInfo(ALU_OP_TESTINT64, "TEST", 2, 0, 1, intTest),
+
+		float64OpInfo(ALU_OP_FADD64, "FADD", 2, 1, 4, floatArith(ALU_OP_FADD64)),
+		float64OpInfo(ALU_OP_FSUB64, "FSUB", 2, 1, 4, floatArith(ALU_OP_FSUB64)),
+		float64OpInfo(ALU_OP_FMULT64, "FMUL", 2, 1, 6, floatArith(ALU_OP_FMULT64)),
+		float64OpInfo(ALU_OP_FDIV64, "FDIV", 2, 1, 30, floatArith(ALU_OP_FDIV64)),
+		float64OpInfo(ALU_OP_FSQRT64, "FSQRT", 1, 1, 35, floatArith(ALU_OP_FSQRT64)),
+		float64OpInfo(ALU_OP_FSIN64, "FSIN", 1, 1, 100, floatUnary(math.Sin)),
+		float64OpInfo(ALU_OP_FCOS64, "FCOS", 1, 1, 100, floatUnary(math.Cos)),
+		float64OpInfo(ALU_OP_FTAN64, "FTAN", 1, 1, 120, floatUnary(math.Tan)),
+		float64OpInfo(ALU_OP_FLN64, "FLN", 1, 1, 90, floatUnary(math.Log)),
+		float64OpInfo(ALU_OP_FEXP64, "FEXP", 1, 1, 90, floatUnary(math.Exp)),
+		float64OpInfo(ALU_OP_FCMP64, "FCMP", 2, 0, 2, floatCmp),
+		ternaryOpInfo(ALU_OP_FMA64, "FMA", ALU_KIND_FLOAT64, 6, fusedMultiplyAdd),
+		float64OpInfo(ALU_OP_FMIN64, "FMIN", 2, 1, 2, floatMinMax(ALU_OP_FMIN64)),
+		float64OpInfo(ALU_OP_FMAX64, "FMAX", 2, 1, 2, floatMinMax(ALU_OP_FMAX64)),
+		float64OpInfo(ALU_OP_FABS64, "FABS", 1, 1, 1, floatAbs),
+		float64OpInfo(ALU_OP_FNEG64, "FNEG", 1, 1, 1, floatNeg),
+		float64OpInfo(ALU_OP_FCOPYSIGN64, "FCOPYSIGN", 2, 1, 1, floatCopysign),
+		float64OpInfo(ALU_OP_FREM64, "FREM", 2, 1, 40, floatRem),
+		float64OpInfo(ALU_OP_FATAN264, "FATAN2", 2, 1, 120, floatBinary(math.Atan2)),
+		float64OpInfo(ALU_OP_FPOW64, "FPOW", 2, 1, 150, floatBinary(math.Pow)),
+		float64OpInfo(ALU_OP_FLOG1064, "FLOG10", 1, 1, 90, floatUnary(math.Log10)),
+		float64OpInfo(ALU_OP_FLOG264, "FLOG2", 1, 1, 90, floatUnary(math.Log2)),
+
+		float32OpInfo(ALU_OP_FADD32, "FADDS", 2, 1, 3, float32Arith(ALU_OP_FADD64)),
+		float32OpInfo(ALU_OP_FSUB32, "FSUBS", 2, 1, 3, float32Arith(ALU_OP_FSUB64)),
+		float32OpInfo(ALU_OP_FMULT32, "FMULS", 2, 1, 4, float32Arith(ALU_OP_FMULT64)),
+		float32OpInfo(ALU_OP_FDIV32, "FDIVS", 2, 1, 18, float32Arith(ALU_OP_FDIV64)),
+		float32OpInfo(ALU_OP_FSQRT32, "FSQRTS", 1, 1, 20, float32Arith(ALU_OP_FSQRT64)),
+		float32OpInfo(ALU_OP_FCMP32, "FCMPS", 2, 0, 2, float32Cmp),
+
+		convertOpInfo(ALU_OP_CVTI64F64, "CVTI64F64", 4, convertI64F64),
+		convertOpInfo(ALU_OP_CVTF64I64, "CVTF64I64", 4, convertF64I64),
+		convertOpInfo(ALU_OP_CVTI32F32, "CVTI32F32", 4, convertI32F32),
+		convertOpInfo(ALU_OP_CVTF32I32, "CVTF32I32", 4, convertF32I32),
+		convertOpInfo(ALU_OP_CVTF64F32, "CVTF64F32", 4, convertF64F32),
+		convertOpInfo(ALU_OP_CVTF32F64, "CVTF32F64", 2, convertF32F64),
+		convertOpInfo(ALU_OP_FTRUNC64, "FTRUNC", 4, convertFixedMode(ALU_FPCW_ROUND_ZERO)),
+		convertOpInfo(ALU_OP_FROUND64, "FROUND", 4, convertRound),
+		convertOpInfo(ALU_OP_FFLOOR64, "FFLOOR", 4, convertFixedMode(ALU_FPCW_ROUND_DOWN)),
+		convertOpInfo(ALU_OP_FCEIL64, "FCEIL", 4, convertFixedMode(ALU_FPCW_ROUND_UP)),
+
+		decimalOpInfo(ALU_OP_DADD, "DADD", 2, 1, 12, decimalArith((*big.Int).Add)),
+		decimalOpInfo(ALU_OP_DSUB, "DSUB", 2, 1, 12, decimalArith((*big.Int).Sub)),
+		decimalOpInfo(ALU_OP_DMUL, "DMUL", 2, 1, 60, decimalArith((*big.Int).Mul)),
+		decimalOpInfo(ALU_OP_DDIV, "DDIV", 2, 2, 120, decimalDivide),
+		decimalOpInfo(ALU_OP_DCMP, "DCMP", 2, 0, 10, decimalCompare),
+		decimalOpInfo(ALU_OP_DPACK, "DPACK", 1, 1, 8, packZoned),
+		decimalOpInfo(ALU_OP_DUNPACK, "DUNPACK", 1, 1, 8, unpackDecimal),
+		decimalOpInfo(ALU_OP_DEDIT, "DEDIT", 2, 1, 30, editDecimal),
+
+		vectorOpInfo(ALU_OP_VADD, "VADD", 1, vectorAdd),
+		vectorOpInfo(ALU_OP_VSUB, "VSUB", 1, vectorSub),
+		vectorOpInfo(ALU_OP_VADDS, "VADDS", 1, vectorAddS),
+		vectorOpInfo(ALU_OP_VADDUS, "VADDUS", 1, vectorAddUS),
+		vectorOpInfo(ALU_OP_VSUBS, "VSUBS", 1, vectorSubS),
+		vectorOpInfo(ALU_OP_VSUBUS, "VSUBUS", 1, vectorSubUS),
+		vectorOpInfo(ALU_OP_VMULLO, "VMULLO", 4, vectorMulLo),
+		vectorOpInfo(ALU_OP_VMULHI, "VMULHI", 4, vectorMulHi),
+		vectorOpInfo(ALU_OP_VMULHIU, "VMULHIU", 4, vectorMulHiU),
+		vectorOpInfo(ALU_OP_VMIN, "VMIN", 1, vectorMin),
+		vectorOpInfo(ALU_OP_VMAX, "VMAX", 1, vectorMax),
+		vectorOpInfo(ALU_OP_VMINU, "VMINU", 1, vectorMinU),
+		vectorOpInfo(ALU_OP_VMAXU, "VMAXU", 1, vectorMaxU),
+		vectorOpInfo(ALU_OP_VCMPEQ, "VCMPEQ", 1, vectorCmpEq),
+		vectorOpInfo(ALU_OP_VCMPGT, "VCMPGT", 1, vectorCmpGt),
+		vectorOpInfo(ALU_OP_VCMPGTU, "VCMPGTU", 1, vectorCmpGtU),
+		vectorOpInfo(ALU_OP_VSHUF, "VSHUF", 2, vectorShuf),
+
+		checksumOpInfo(ALU_OP_CRC32, "CRC32", 3, checksumCRC32),
+		checksumOpInfo(ALU_OP_CRC32C, "CRC32C", 3, checksumCRC32C),
+		checksumOpInfo(ALU_OP_CRC16CCITT, "CRC16", 3, checksumCRC16CCITT),
+		intOpInfo(ALU_OP_CLMUL64, "CLMUL", 2, 2, 6, intClmul),
+		aesOpInfo(ALU_OP_AESENC, "AESENC", 2, 4, aesRound(false, true)),
+		aesOpInfo(ALU_OP_AESENCLAST, "AESENCLAST", 2, 4, aesRound(false, false)),
+		aesOpInfo(ALU_OP_AESDEC, "AESDEC", 2, 4, aesRound(true, true)),
+		aesOpInfo(ALU_OP_AESDECLAST, "AESDECLAST", 2, 4, aesRound(true, false)),
+		aesOpInfo(ALU_OP_AESIMC, "AESIMC", 1, 4, aesInvMixKey),
+
+		bigIntOpInfo(ALU_OP_BIGADD, "BIGADD", 1, 4, bigAdd),
+		bigIntOpInfo(ALU_OP_BIGSUB, "BIGSUB", 1, 4, bigSub),
+		bigIntOpInfo(ALU_OP_BIGMUL, "BIGMUL", 1, 40, bigMul),
+		bigIntOpInfo(ALU_OP_BIGDIVMOD, "BIGDIVMOD", 2, 200, bigDivMod),
+		bigIntOpInfo(ALU_OP_BIGSHL, "BIGSHL", 1, 4, bigShift(ALU_OP_BIGSHL)),
+		bigIntOpInfo(ALU_OP_BIGSHR, "BIGSHR", 1, 4, bigShift(ALU_OP_BIGSHR)),
+		bigIntOpInfo(ALU_OP_BIGSHRL, "BIGSHRL", 1, 4, bigShift(ALU_OP_BIGSHRL)),
+		bigIntOpInfo(ALU_OP_BIGCMP, "BIGCMP", 0, 4, bigCmp),
+
+		float80OpInfo(ALU_OP_FADD80, "FADDX", 2, 1, 5, float80Arith(ALU_OP_FADD80)),
+		float80OpInfo(ALU_OP_FSUB80, "FSUBX", 2, 1, 5, float80Arith(ALU_OP_FSUB80)),
+		float80OpInfo(ALU_OP_FMULT80, "FMULX", 2, 1, 8, float80Arith(ALU_OP_FMULT80)),
+		float80OpInfo(ALU_OP_FDIV80, "FDIVX", 2, 1, 40, float80Arith(ALU_OP_FDIV80)),
+		float80OpInfo(ALU_OP_FSQRT80, "FSQRTX", 1, 1, 45, float80Arith(ALU_OP_FSQRT80)),
+		float80OpInfo(ALU_OP_FCMP80, "FCMPX", 2, 0, 3, float80Cmp),
+
+		fixedOpInfo(ALU_OP_QMUL, "QMUL", 2, 4, fixedMul),
+		fixedOpInfo(ALU_OP_QDIV, "QDIV", 2, 40, fixedDiv),
+		fixedOpInfo(ALU_OP_QRECIP, "QRECIP", 1, 40, fixedRecip),
+		fixedOpInfo(ALU_OP_QSQRT, "QSQRT", 1, 35, fixedSqrt),
+	)
+}
+
+// ALULookupOp returns the description of an op code
+func ALULookupOp(op int) (info ALUOpInfo, ok bool) {
+	p, ok := aluOps[op]
+	if !ok {
+		return ALUOpInfo{}, false
+	}
+	return *p, true
+}
+
+// ALULookupMnemonic returns the op with the given mnemonic, ignoring case
+func ALULookupMnemonic(mnemonic string) (info ALUOpInfo, ok bool) {
+	p, ok := aluMnemonics[strings.ToUpper(mnemonic)]
+	if !ok {
+		return ALUOpInfo{}, false
+	}
+	return *p, true
+}
+
+// ALUOps lists every registered op in op code order
+func ALUOps() []ALUOpInfo {
+	ops := make([]ALUOpInfo, 0, len(aluOps))
+	for _, p := range aluOps {
+		ops = append(ops, *p)
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].Op < ops[j].Op })
+	return ops
+}
+
+// ALUOpAvailable reports whether a CPU has the hardware for an op. Only the ops Execute
+// can run on registers are available, and profiles without an FPU, per
+// Configuration.FeatureA_NoFPU, have none of the floating point or float conversion ops
+// and use the fixed point ops instead.
+func ALUOpAvailable(op int, cpu Configuration.CPUDescriptor) bool {
+	info, ok := aluOps[op]
+	if !ok || !info.Executable() {
+		return false
+	}
+	if cpu.FeatureA&Configuration.FeatureA_NoFPU != 0 {
+		switch info.Kind {
+		case ALU_KIND_FLOAT64, ALU_KIND_FLOAT32, ALU_KIND_CONVERT:
+			return false
+		}
+	}
+	return true
+}
+
+// Executable reports whether Execute can run the op. The decimal, AES, big integer and
+// FLOAT80 ops can't, as their operands don't fit in registers.
+func (info ALUOpInfo) Executable() bool {
+	switch info.Kind {
+	case ALU_KIND_DECIMAL, ALU_KIND_AES, ALU_KIND_BIGINT, ALU_KIND_FLOAT80:
+		return false
+	}
+	return info.Kind != 0
+}
+
+// Execute runs the op on raw 64-bit register values, the way a CPU holds them. Integers
+// and vectors use width as the operand or lane width, float64 values are passed as their
+// IEEE bits and float32 values in the low 32 bits, as for ALUConvert. parmC is only used
+// by the three operand ops. For the checksum ops parmA is the running checksum and width
+// the size of the data in parmB, and the fixed point ops take their fraction bits and
+// modes from control. Decimal ops work on memory fields, AES ops on 128-bit
+// values, big integer ops on limb vectors and the FLOAT80 ops on 80-bit values, so they
+// must go through ALUDecimal, ALUAESRound, ALUBigInt and ALUFloat80.
+func (info ALUOpInfo) Execute(width int, parmA uint64, parmB uint64, parmC uint64, flagsIn uint64, control uint64) (outA uint64, outB uint64, flags uint64) {
+	switch {
+	case info.ternaryOp != nil:
+		outA, flags = info.ternaryOp(parmA, parmB, parmC, control)
+		return outA, 0, flags
+	case info.intOp != nil:
+		a, b, flags := ALUIntWidthWithFlags(info.Op, width, int64(parmA), int64(parmB), flagsIn)
+		return uint64(a), uint64(b), flags
+	case info.float64Op != nil:
+		a, b, flags := info.float64Op(math.Float64frombits(parmA), math.Float64frombits(parmB), control)
+		return math.Float64bits(a), math.Float64bits(b), flags
+	case info.float32Op != nil:
+		a, b, flags := info.float32Op(math.Float32frombits(uint32(parmA)), math.Float32frombits(uint32(parmB)), control)
+		return uint64(math.Float32bits(a)), uint64(math.Float32bits(b)), flags
+	case info.convertOp != nil:
+		outA, flags = info.convertOp(parmA, control)
+		return outA, 0, flags
+	case info.vectorOp != nil:
+		a, b, flags := ALUVector(info.Op, width, int64(parmA), int64(parmB))
+		return uint64(a), uint64(b), flags
+	case info.checksumOp != nil:
+		outA, flags = ALUChecksum(info.Op, width, parmA, parmB)
+		return outA, 0, flags
+	case info.fixedOp != nil:
+		a, b, flags := ALUFixed(info.Op, width, int64(parmA), int64(parmB), control)
+		return uint64(a), uint64(b), flags
+	}
+	return 0, 0, ALU_FLAGS_ERROR | ALU_FLAGS_INVALIDOP
+}
@@ -0,0 +1,84 @@
+package Onyx1ALU
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRegistryCoversAllOps(t *testing.T) {
+	ops := ALUOps()
+	if len(ops) != ALU_OP_QSQRT {
+		t.Errorf("ALUOps Expected %d ops, got %d", ALU_OP_QSQRT, len(ops))
+	}
+	for i, info := range ops {
+		if info.Op != i+1 {
+			t.Errorf("ALUOps Expected op %d at %d, got %d", i+1, i, info.Op)
+		}
+		if info.Mnemonic == "" || info.Kind == 0 || info.Cycles == 0 || info.Arity == 0 {
+			t.Errorf("ALUOps Expected complete metadata for op %d, got %+v", info.Op, info)
+		}
+	}
+}
+
+func TestRegistryLookup(t *testing.T) {
+	info, ok := ALULookupMnemonic("udiv")
+	if !ok || info.Op != ALU_OP_UDIVINT64 || info.Kind != ALU_KIND_INT || info.Results != 2 {
+		t.Errorf("ALULookupMnemonic Expected UDIV, got %+v %t", info, ok)
+	}
+	info, ok = ALULookupOp(ALU_OP_FCMP64)
+	if !ok || info.Mnemonic != "FCMP" || info.Results != 0 {
+		t.Errorf("ALULookupOp Expected FCMP, got %+v %t", info, ok)
+	}
+	_, ok = ALULookupOp(0)
+	if ok {
+		t.Errorf("ALULookupOp Expected op 0 to be unknown")
+	}
+}
+
+func TestUnknownOpFlags(t *testing.T) {
+	want := uint64(ALU_FLAGS_ERROR | ALU_FLAGS_INVALIDOP)
+	_, _, flags := ALUInt64(0x7FFF, 1, 2)
+	if flags != want {
+		t.Errorf("ALUInt64 Expected ERROR|INVALIDOP for an unknown op, got %b", flags)
+	}
+	_, _, flags = ALUFloat64(0x7FFF, 1, 2)
+	if flags != want {
+		t.Errorf("ALUFloat64 Expected ERROR|INVALIDOP for an unknown op, got %b", flags)
+	}
+	_, _, flags = ALUFloat32(ALU_OP_ADDINT64, 1, 2)
+	if flags != want {
+		t.Errorf("ALUFloat32 Expected ERROR|INVALIDOP for an int op, got %b", flags)
+	}
+	_, _, flags = ALUFloat64(ALU_OP_FMA64, 1, 2)
+	if flags != want {
+		t.Errorf("ALUFloat64 Expected ERROR|INVALIDOP for FMA, got %b", flags)
+	}
+}
+
+func TestExecute(t *testing.T) {
+	info, _ := ALULookupOp(ALU_OP_ADDINT64)
+	outA, _, flags := info.Execute(ALU_WIDTH_8, 0x7F, 1, 0, 0, 0)
+	if outA != 0xFFFF_FFFF_FFFF_FF80 || flags&ALU_FLAGS_OVERFLOW == 0 {
+		t.Errorf("Execute ADD Expected -128 with OVERFLOW, got %x %b", outA, flags)
+	}
+	info, _ = ALULookupOp(ALU_OP_FMA64)
+	outA, _, _ = info.Execute(0, math.Float64bits(2), math.Float64bits(3), math.Float64bits(1), 0, ALU_FPCW_ROUND_NEAREST)
+	if math.Float64frombits(outA) != 7 {
+		t.Errorf("Execute FMA Expected 7, got %f", math.Float64frombits(outA))
+	}
+	info, _ = ALULookupOp(ALU_OP_BFINSINT64)
+	outA, _, _ = info.Execute(ALU_WIDTH_64, 0xFF00, 0x5, uint64(BitFieldSpec(8, 4)), 0, 0)
+	if outA != 0xF500 {
+		t.Errorf("Execute BFINS Expected f500, got %x", outA)
+	}
+	info, _ = ALULookupOp(ALU_OP_FADD32)
+	outA, _, _ = info.Execute(0, uint64(math.Float32bits(1.5)), uint64(math.Float32bits(2)), 0, 0, 0)
+	if math.Float32frombits(uint32(outA)) != 3.5 {
+		t.Errorf("Execute FADDS Expected 3.5, got %f", math.Float32frombits(uint32(outA)))
+	}
+	info, _ = ALULookupOp(ALU_OP_DADD)
+	_, _, flags = info.Execute(0, 0, 0, 0, 0, 0)
+	if flags&ALU_FLAGS_INVALIDOP == 0 {
+		t.Errorf("Execute DADD Expected INVALIDOP, got %b", flags)
+	}
+}
@@ -0,0 +1,116 @@
+package Onyx1ALU
+
+// vectorLane is one lane of the operands, both as unsigned and sign extended values
+type vectorLane struct {
+	width  int
+	lanes  int
+	mask   uint64
+	ua, ub uint64
+	sa, sb int64
+	parmA  uint64
+}
+
+// ALUVector treats the 64-bit operands as packed lanes of 8, 16 or 32 bits, lane 0 being
+// the least significant. The S and US ops saturate as signed and unsigned values and set
+// SATURATED if any lane was clamped. Compares set a lane to all ones when true. VSHUF
+// picks each result lane from parmA by the index in the same lane of parmB, or zeroes it
+// when the top bit of that parmB lane is set. ZERO is set when the whole result is zero.
+func ALUVector(op int, laneWidth int, parmA int64, parmB int64) (outA int64, outB int64, flags uint64) {
+	if laneWidth != ALU_WIDTH_8 && laneWidth != ALU_WIDTH_16 && laneWidth != ALU_WIDTH_32 {
+		return 0, 0, ALU_FLAGS_ERROR | ALU_FLAGS_INVALIDOP
+	}
+	info, ok := aluOps[op]
+	if !ok || info.vectorOp == nil {
+		return 0, 0, ALU_FLAGS_ERROR | ALU_FLAGS_INVALIDOP
+	}
+	l := vectorLane{width: laneWidth, lanes: 64 / laneWidth, mask: widthMask(laneWidth), parmA: uint64(parmA)}
+	var result uint64
+	for i := 0; i < l.lanes; i++ {
+		shift := uint(i * laneWidth)
+		l.ua = (uint64(parmA) >> shift) & l.mask
+		l.ub = (uint64(parmB) >> shift) & l.mask
+		l.sa = truncateToWidth(int64(l.ua), laneWidth)
+		l.sb = truncateToWidth(int64(l.ub), laneWidth)
+		r, saturated := info.vectorOp(l)
+		if saturated {
+			flags |= ALU_FLAGS_SATURATED
+		}
+		result |= (r & l.mask) << shift
+	}
+	outA = int64(result)
+	if outA == 0 {
+		flags |= ALU_FLAGS_ZERO
+	}
+	return outA, outB, flags
+}
+
+// saturateSigned clamps s to the signed range of the lane
+func saturateSigned(s int64, l vectorLane) (r uint64, saturated bool) {
+	maxSigned := int64(l.mask >> 1)
+	minSigned := -maxSigned - 1
+	if s > maxSigned {
+		return uint64(maxSigned), true
+	}
+	if s < minSigned {
+		return uint64(minSigned), true
+	}
+	return uint64(s), false
+}
+
+// laneMask turns a lane compare into all ones or all zeroes
+func laneMask(cond bool, l vectorLane) (r uint64, saturated bool) {
+	if cond {
+		return l.mask, false
+	}
+	return 0, false
+}
+
+func vectorAdd(l vectorLane) (uint64, bool) { return l.ua + l.ub, false }
+
+func vectorSub(l vectorLane) (uint64, bool) { return l.ua - l.ub, false }
+
+func vectorAddS(l vectorLane) (uint64, bool) { return saturateSigned(l.sa+l.sb, l) }
+
+func vectorSubS(l vectorLane) (uint64, bool) { return saturateSigned(l.sa-l.sb, l) }
+
+func vectorAddUS(l vectorLane) (uint64, bool) {
+	if r := l.ua + l.ub; r <= l.mask {
+		return r, false
+	}
+	return l.mask, true
+}
+
+func vectorSubUS(l vectorLane) (uint64, bool) {
+	if l.ua < l.ub {
+		return 0, true
+	}
+	return l.ua - l.ub, false
+}
+
+func vectorMulLo(l vectorLane) (uint64, bool) { return l.ua * l.ub, false }
+
+func vectorMulHi(l vectorLane) (uint64, bool) { return uint64((l.sa * l.sb) >> uint(l.width)), false }
+
+func vectorMulHiU(l vectorLane) (uint64, bool) { return (l.ua * l.ub) >> uint(l.width), false }
+
+func vectorMin(l vectorLane) (uint64, bool) { return uint64(min(l.sa, l.sb)), false }
+
+func vectorMax(l vectorLane) (uint64, bool) { return uint64(max(l.sa, l.sb)), false }
+
+func vectorMinU(l vectorLane) (uint64, bool) { return min(l.ua, l.ub), false }
+
+func vectorMaxU(l vectorLane) (uint64, bool) { return max(l.ua, l.ub), false }
+
+func vectorCmpEq(l vectorLane) (uint64, bool) { return laneMask(l.ua == l.ub, l) }
+
+func vectorCmpGt(l vectorLane) (uint64, bool) { return laneMask(l.sa > l.sb, l) }
+
+func vectorCmpGtU(l vectorLane) (uint64, bool) { return laneMask(l.ua > l.ub, l) }
+
+func vectorShuf(l vectorLane) (uint64, bool) {
+	if l.ub>>uint(l.width-1) != 0 {
+		return 0, false
+	}
+	from := uint(int(l.ub)%l.lanes) * uint(l.width)
+	return (l.parmA >> from) & l.mask, false
+}
@@ -0,0 +1,80 @@
+package Onyx1ALU
+
+import "testing"
+
+func TestALUVectorArithmetic(t *testing.T) {
+	outA, outB, flags := ALUVector(ALU_OP_VADD, ALU_WIDTH_8, 0x01FF_0203_0405_0607, 0x0101_0101_0101_0101)
+	if outA != 0x0200_0304_0506_0708 {
+		t.Errorf("OP_VADD8 Expected 0x0200030405060708, got %x %x %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUVector(ALU_OP_VADDUS, ALU_WIDTH_8, 0x01FF, 0x0101)
+	if outA != 0x02FF || flags&ALU_FLAGS_SATURATED == 0 {
+		t.Errorf("OP_VADDUS8 Expected 0x02FF SATURATED, got %x %x %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUVector(ALU_OP_VADDS, ALU_WIDTH_16, 0x7FF0_0001, 0x0100_0001)
+	if outA != 0x7FFF_0002 || flags&ALU_FLAGS_SATURATED == 0 {
+		t.Errorf("OP_VADDS16 Expected 0x7FFF0002 SATURATED, got %x %x %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUVector(ALU_OP_VSUBS, ALU_WIDTH_16, 0x8001, 0x0002)
+	if outA != 0x8000 || flags&ALU_FLAGS_SATURATED == 0 {
+		t.Errorf("OP_VSUBS16 Expected 0x8000 SATURATED, got %x %x %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUVector(ALU_OP_VSUBUS, ALU_WIDTH_32, 0x0000_0005_0000_0001, 0x0000_0002_0000_0002)
+	if outA != 0x0000_0003_0000_0000 || flags&ALU_FLAGS_SATURATED == 0 {
+		t.Errorf("OP_VSUBUS32 Expected 0x0000000300000000 SATURATED, got %x %x %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUVector(ALU_OP_VMULLO, ALU_WIDTH_16, 0x0100_0003, 0x0100_0004)
+	if outA != 0x0000_000C {
+		t.Errorf("OP_VMULLO16 Expected 0x0000000C, got %x %x %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUVector(ALU_OP_VMULHI, ALU_WIDTH_16, 0xFFFF_4000, 0x0002_0004)
+	if outA != 0xFFFF_0001 {
+		t.Errorf("OP_VMULHI16 Expected 0xFFFF0001, got %x %x %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUVector(ALU_OP_VMULHIU, ALU_WIDTH_16, 0xFFFF, 0x0002)
+	if outA != 0x0001 {
+		t.Errorf("OP_VMULHIU16 Expected 0x0001, got %x %x %b", outA, outB, flags)
+	}
+	_, _, flags = ALUVector(ALU_OP_VADD, ALU_WIDTH_64, 1, 1)
+	if flags&ALU_FLAGS_INVALIDOP == 0 {
+		t.Errorf("OP_VADD64 Expected INVALIDOP, got %b", flags)
+	}
+}
+
+func TestALUVectorCompareShuffle(t *testing.T) {
+	outA, outB, flags := ALUVector(ALU_OP_VMIN, ALU_WIDTH_8, 0x80_01, 0x01_02)
+	if outA != 0x80_01 {
+		t.Errorf("OP_VMIN8 Expected 0x8001, got %x %x %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUVector(ALU_OP_VMINU, ALU_WIDTH_8, 0x80_01, 0x01_02)
+	if outA != 0x01_01 {
+		t.Errorf("OP_VMINU8 Expected 0x0101, got %x %x %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUVector(ALU_OP_VMAXU, ALU_WIDTH_8, 0x80_01, 0x01_02)
+	if outA != 0x80_02 {
+		t.Errorf("OP_VMAXU8 Expected 0x8002, got %x %x %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUVector(ALU_OP_VCMPEQ, ALU_WIDTH_8, 0x11_22_33, 0x11_00_33)
+	// The upper lanes are zero in both operands so they compare equal too
+	if uint64(outA) != 0xFFFF_FFFF_FFFF_00FF {
+		t.Errorf("OP_VCMPEQ8 Expected 0xFFFFFFFFFFFF00FF, got %x %x %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUVector(ALU_OP_VCMPGT, ALU_WIDTH_16, 0xFFFF_0002, 0x0001_0001)
+	if outA != 0x0000_FFFF {
+		t.Errorf("OP_VCMPGT16 Expected 0x0000FFFF, got %x %x %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUVector(ALU_OP_VCMPGTU, ALU_WIDTH_16, 0xFFFF_0002, 0x0001_0001)
+	if outA != 0xFFFF_FFFF {
+		t.Errorf("OP_VCMPGTU16 Expected 0xFFFFFFFF, got %x %x %b", outA, outB, flags)
+	}
+	// Reverse the bytes and zero the top one
+	selectors := uint64(0x8001_0203_0405_0607)
+	outA, outB, flags = ALUVector(ALU_OP_VSHUF, ALU_WIDTH_8, 0x0807_0605_0403_0201, int64(selectors))
+	if outA != 0x0002_0304_0506_0708 {
+		t.Errorf("OP_VSHUF8 Expected 0x0002030405060708, got %x %x %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUVector(ALU_OP_VSHUF, ALU_WIDTH_32, 0x1111_1111_2222_2222, 0x0000_0000_0000_0000)
+	if outA != 0x2222_2222_2222_2222 {
+		t.Errorf("OP_VSHUF32 Expected 0x2222222222222222, got %x %x %b", outA, outB, flags)
+	}
+}
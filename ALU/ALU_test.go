@@ -41,8 +41,48 @@ func TestALUFloat64(t *testing.T) {
 		t.Errorf("OP_FEXPD64 Expected %f, got %f %f %b", math.Exp(parmA), outA, outB, flags)
 	}
 	outA, outB, flags = ALUFloat64(ALU_OP_FLN64, parmA, parmB)
+	if outA != math.Log(parmA) {
+		t.Errorf("OP_FLND64 Expected %f, got %f %f %b", math.Log(parmA), outA, outB, flags)
+	}
+	outA, outB, flags = ALUFloat64(ALU_OP_FLOG264, parmA, parmB)
 	if outA != math.Log2(parmA) {
-		t.Errorf("OP_FLND64 Expected %f, got %f %f %b", math.Log2(parmA), outA, outB, flags)
+		t.Errorf("OP_FLOG264 Expected %f, got %f %f %b", math.Log2(parmA), outA, outB, flags)
+	}
+	outA, outB, flags = ALUFloat64(ALU_OP_FLOG1064, parmA, parmB)
+	if outA != math.Log10(parmA) {
+		t.Errorf("OP_FLOG1064 Expected %f, got %f %f %b", math.Log10(parmA), outA, outB, flags)
+	}
+	outA, outB, flags = ALUFloat64(ALU_OP_FATAN264, parmA, parmB)
+	if outA != math.Atan2(parmA, parmB) {
+		t.Errorf("OP_FATAN264 Expected %f, got %f %f %b", math.Atan2(parmA, parmB), outA, outB, flags)
+	}
+	outA, outB, flags = ALUFloat64(ALU_OP_FPOW64, 2, 10)
+	if outA != 1024 {
+		t.Errorf("OP_FPOW64 Expected 1024, got %f %f %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUFloat64(ALU_OP_FREM64, parmA, parmB)
+	if outA != -10 {
+		t.Errorf("OP_FREM64 Expected -10, got %f %f %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUFloat64(ALU_OP_FMIN64, parmA, parmB)
+	if outA != parmB {
+		t.Errorf("OP_FMIN64 Expected %f, got %f %f %b", parmB, outA, outB, flags)
+	}
+	outA, outB, flags = ALUFloat64(ALU_OP_FMAX64, parmA, parmB)
+	if outA != parmA {
+		t.Errorf("OP_FMAX64 Expected %f, got %f %f %b", parmA, outA, outB, flags)
+	}
+	outA, outB, flags = ALUFloat64(ALU_OP_FABS64, -parmA, parmB)
+	if outA != parmA {
+		t.Errorf("OP_FABS64 Expected %f, got %f %f %b", parmA, outA, outB, flags)
+	}
+	outA, outB, flags = ALUFloat64(ALU_OP_FNEG64, parmA, parmB)
+	if outA != -parmA || flags&ALU_FLAGS_NEGATIVE == 0 {
+		t.Errorf("OP_FNEG64 Expected %f, got %f %f %b", -parmA, outA, outB, flags)
+	}
+	outA, outB, flags = ALUFloat64(ALU_OP_FCOPYSIGN64, parmA, -1)
+	if outA != -parmA {
+		t.Errorf("OP_FCOPYSIGN64 Expected %f, got %f %f %b", -parmA, outA, outB, flags)
 	}
 	outA, outB, flags = ALUFloat64(ALU_OP_FSQRT64, parmA, parmB)
 	if outA != math.Sqrt(parmA) {
@@ -90,3 +130,186 @@ func TestALUInt64(t *testing.T) {
 		t.Errorf("OP_SHRINT64 Expected 10, got %d %d %b", outA, outB, flags)
 	}
 }
+
+func TestALUIntWidth(t *testing.T) {
+	outA, outB, flags := ALUInt8(ALU_OP_ADDINT64, 100, 100)
+	if outA != -56 || flags&ALU_FLAGS_NEGATIVE == 0 || flags&ALU_FLAGS_CARRY != 0 {
+		t.Errorf("OP_ADDINT8 Expected -56 NEGATIVE, got %d %d %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUInt8(ALU_OP_ADDINT64, -1, 1)
+	if outA != 0 || flags&ALU_FLAGS_ZERO == 0 || flags&ALU_FLAGS_CARRY == 0 {
+		t.Errorf("OP_ADDINT8 Expected 0 ZERO CARRY, got %d %d %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUInt8(ALU_OP_SUBINT64, 1, 2)
+	if outA != -1 || flags&ALU_FLAGS_CARRY == 0 {
+		t.Errorf("OP_SUBINT8 Expected -1 CARRY, got %d %d %b", outA, outB, flags)
+	}
+	w16A, w16B, flags := ALUInt16(ALU_OP_MULTINT64, 0x100, 0x100)
+	if w16A != 0 || flags&ALU_FLAGS_ZERO == 0 {
+		t.Errorf("OP_MULTINT16 Expected 0 ZERO, got %d %d %b", w16A, w16B, flags)
+	}
+	w32A, w32B, flags := ALUInt32(ALU_OP_SHLINT64, 1, 31)
+	if w32A != -0x8000_0000 || flags&ALU_FLAGS_NEGATIVE == 0 {
+		t.Errorf("OP_SHLINT32 Expected -2147483648 NEGATIVE, got %d %d %b", w32A, w32B, flags)
+	}
+	_, _, flags = ALUInt32(ALU_OP_SHLINT64, 1, 32)
+	if flags&ALU_FLAGS_INVALIDOP == 0 {
+		t.Errorf("OP_SHLINT32 Expected INVALIDOP for a 32-bit shift, got %b", flags)
+	}
+	wA, wB, flags := ALUIntWidth(ALU_OP_ADDINT64, ALU_WIDTH_16, 0x1_FFFF, 1)
+	if wA != 0 || flags&ALU_FLAGS_ZERO == 0 || flags&ALU_FLAGS_CARRY == 0 {
+		t.Errorf("OP_ADDINT16 Expected 0 ZERO CARRY, got %d %d %b", wA, wB, flags)
+	}
+	_, _, flags = ALUIntWidth(ALU_OP_ADDINT64, 12, 1, 1)
+	if flags&ALU_FLAGS_INVALIDOP == 0 {
+		t.Errorf("ALUIntWidth Expected INVALIDOP for width 12, got %b", flags)
+	}
+}
+
+func TestALUInt64CarryOverflow(t *testing.T) {
+	outA, outB, flags := ALUInt64(ALU_OP_ADDINT64, math.MaxInt64, 1)
+	if outA != math.MinInt64 || flags&ALU_FLAGS_OVERFLOW == 0 || flags&ALU_FLAGS_CARRY != 0 {
+		t.Errorf("OP_ADDINT64 Expected MinInt64 OVERFLOW, got %d %d %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUInt64(ALU_OP_ADDINT64, -1, -1)
+	if outA != -2 || flags&ALU_FLAGS_OVERFLOW != 0 || flags&ALU_FLAGS_CARRY == 0 {
+		t.Errorf("OP_ADDINT64 Expected -2 CARRY, got %d %d %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUInt64(ALU_OP_SUBINT64, math.MinInt64, 1)
+	if outA != math.MaxInt64 || flags&ALU_FLAGS_OVERFLOW == 0 || flags&ALU_FLAGS_CARRY != 0 {
+		t.Errorf("OP_SUBINT64 Expected MaxInt64 OVERFLOW, got %d %d %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUInt64(ALU_OP_SUBINT64, 0, 1)
+	if outA != -1 || flags&ALU_FLAGS_CARRY == 0 || flags&ALU_FLAGS_OVERFLOW != 0 {
+		t.Errorf("OP_SUBINT64 Expected -1 CARRY, got %d %d %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUInt64(ALU_OP_MULTINT64, 1<<32, 1<<31)
+	if flags&ALU_FLAGS_OVERFLOW == 0 || flags&ALU_FLAGS_CARRY == 0 {
+		t.Errorf("OP_MULTINT64 Expected CARRY OVERFLOW, got %d %d %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUInt64(ALU_OP_MULTINT64, -1<<31, 1<<32)
+	if outA != math.MinInt64 || flags&ALU_FLAGS_OVERFLOW != 0 {
+		t.Errorf("OP_MULTINT64 Expected MinInt64 without OVERFLOW, got %d %d %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUInt64(ALU_OP_DIVINT64, math.MinInt64, -1)
+	if flags&ALU_FLAGS_OVERFLOW == 0 {
+		t.Errorf("OP_DIVINT64 Expected OVERFLOW, got %d %d %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUInt64(ALU_OP_SHLINT64, 0x4000_0000_0000_0001, 2)
+	if outA != 4 || flags&ALU_FLAGS_CARRY == 0 || flags&ALU_FLAGS_OVERFLOW == 0 {
+		t.Errorf("OP_SHLINT64 Expected 4 CARRY OVERFLOW, got %d %d %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUInt64(ALU_OP_SHRINT64, 6, 2)
+	if outA != 1 || flags&ALU_FLAGS_CARRY == 0 {
+		t.Errorf("OP_SHRINT64 Expected 1 CARRY, got %d %d %b", outA, outB, flags)
+	}
+	i8A, i8B, flags := ALUInt8(ALU_OP_MULTINT64, 16, 8)
+	if i8A != -128 || flags&ALU_FLAGS_OVERFLOW == 0 {
+		t.Errorf("OP_MULTINT8 Expected -128 OVERFLOW, got %d %d %b", i8A, i8B, flags)
+	}
+}
+
+func TestALUInt64CarryChain(t *testing.T) {
+	// 128-bit add of 0x0000_0001_FFFF_FFFF_FFFF_FFFF + 1 done as two 64-bit halves
+	lo, _, flags := ALUInt64(ALU_OP_ADDINT64, -1, 1)
+	hi, outB, flags := ALUInt64WithFlags(ALU_OP_ADCINT64, 1, 0, flags)
+	if lo != 0 || hi != 2 || flags&ALU_FLAGS_CARRY != 0 {
+		t.Errorf("OP_ADCINT64 Expected 2:0, got %d:%d %d %b", hi, lo, outB, flags)
+	}
+	outA, outB, flags := ALUInt64WithFlags(ALU_OP_ADCINT64, -1, 0, ALU_FLAGS_CARRY)
+	if outA != 0 || flags&ALU_FLAGS_CARRY == 0 || flags&ALU_FLAGS_ZERO == 0 {
+		t.Errorf("OP_ADCINT64 Expected 0 CARRY ZERO, got %d %d %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUInt64WithFlags(ALU_OP_ADCINT64, math.MaxInt64, 0, ALU_FLAGS_CARRY)
+	if outA != math.MinInt64 || flags&ALU_FLAGS_OVERFLOW == 0 {
+		t.Errorf("OP_ADCINT64 Expected MinInt64 OVERFLOW, got %d %d %b", outA, outB, flags)
+	}
+	// 128-bit subtract of 2:0 - 0:1 borrows out of the low half
+	lo, _, flags = ALUInt64(ALU_OP_SUBINT64, 0, 1)
+	hi, outB, flags = ALUInt64WithFlags(ALU_OP_SBCINT64, 2, 0, flags)
+	if lo != -1 || hi != 1 || flags&ALU_FLAGS_CARRY != 0 {
+		t.Errorf("OP_SBCINT64 Expected 1:-1, got %d:%d %d %b", hi, lo, outB, flags)
+	}
+	outA, outB, flags = ALUInt64WithFlags(ALU_OP_SBCINT64, 0, 0, ALU_FLAGS_CARRY)
+	if outA != -1 || flags&ALU_FLAGS_CARRY == 0 {
+		t.Errorf("OP_SBCINT64 Expected -1 CARRY, got %d %d %b", outA, outB, flags)
+	}
+	wA, wB, flags := ALUIntWidthWithFlags(ALU_OP_ADCINT64, ALU_WIDTH_8, 0x7F, 0x7F, ALU_FLAGS_CARRY)
+	if wA != -1 || flags&ALU_FLAGS_OVERFLOW == 0 || flags&ALU_FLAGS_CARRY != 0 {
+		t.Errorf("OP_ADCINT8 Expected -1 OVERFLOW, got %d %d %b", wA, wB, flags)
+	}
+}
+
+func TestALUInt64Unsigned(t *testing.T) {
+	outA, outB, flags := ALUInt64(ALU_OP_UADDINT64, -1, 2)
+	if outA != 1 || flags&ALU_FLAGS_CARRY == 0 || flags&ALU_FLAGS_NEGATIVE != 0 {
+		t.Errorf("OP_UADDINT64 Expected 1 CARRY, got %d %d %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUInt64(ALU_OP_USUBINT64, 1, 2)
+	if outA != -1 || flags&ALU_FLAGS_CARRY == 0 || flags&ALU_FLAGS_NEGATIVE != 0 {
+		t.Errorf("OP_USUBINT64 Expected 0xFFFFFFFFFFFFFFFF CARRY, got %d %d %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUInt64(ALU_OP_UMULINT64, -1, -1)
+	if uint64(outA) != 0xFFFF_FFFF_FFFF_FFFE || outB != 1 || flags&ALU_FLAGS_CARRY == 0 {
+		t.Errorf("OP_UMULINT64 Expected 0xFFFFFFFFFFFFFFFE:1 CARRY, got %x %x %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUInt64(ALU_OP_UMULINT64, 6, 7)
+	if outA != 0 || outB != 42 || flags&ALU_FLAGS_CARRY != 0 {
+		t.Errorf("OP_UMULINT64 Expected 0:42, got %d %d %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUInt64(ALU_OP_UDIVINT64, -1, 16)
+	if uint64(outA) != 0x0FFF_FFFF_FFFF_FFFF || outB != 15 {
+		t.Errorf("OP_UDIVINT64 Expected 0x0FFFFFFFFFFFFFFF 15, got %x %d %b", outA, outB, flags)
+	}
+	_, _, flags = ALUInt64(ALU_OP_UDIVINT64, 1, 0)
+	if flags&ALU_FLAGS_DIVIDEBYZERO == 0 {
+		t.Errorf("OP_UDIVINT64 Expected DIVIDEBYZERO, got %b", flags)
+	}
+	outA, outB, flags = ALUInt64(ALU_OP_SHRLINT64, -8, 2)
+	if uint64(outA) != 0x3FFF_FFFF_FFFF_FFFE || flags&ALU_FLAGS_NEGATIVE != 0 {
+		t.Errorf("OP_SHRLINT64 Expected 0x3FFFFFFFFFFFFFFE, got %x %d %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUInt64(ALU_OP_SHRINT64, -8, 2)
+	if outA != -2 {
+		t.Errorf("OP_SHRINT64 Expected -2, got %d %d %b", outA, outB, flags)
+	}
+	wA, wB, flags := ALUIntWidth(ALU_OP_UMULINT64, ALU_WIDTH_8, -1, 2)
+	if wA != 1 || wB != 0xFE || flags&ALU_FLAGS_CARRY == 0 {
+		t.Errorf("OP_UMULINT8 Expected 1:0xFE CARRY, got %x %x %b", wA, wB, flags)
+	}
+	wA, wB, flags = ALUIntWidth(ALU_OP_UDIVINT64, ALU_WIDTH_16, -1, 2)
+	if wA != 0x7FFF || wB != 1 {
+		t.Errorf("OP_UDIVINT16 Expected 0x7FFF 1, got %x %x %b", wA, wB, flags)
+	}
+	wA, wB, flags = ALUIntWidth(ALU_OP_SHRLINT64, ALU_WIDTH_8, -128, 7)
+	if wA != 1 || flags&ALU_FLAGS_CARRY != 0 {
+		t.Errorf("OP_SHRLINT8 Expected 1, got %x %x %b", wA, wB, flags)
+	}
+}
+
+func TestALUCompare(t *testing.T) {
+	outA, outB, flags := ALUInt64(ALU_OP_CMPINT64, 5, 5)
+	if outA != 5 || outB != 5 || flags&ALU_FLAGS_ZERO == 0 {
+		t.Errorf("OP_CMPINT64 Expected 5 5 ZERO, got %d %d %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUInt64(ALU_OP_CMPINT64, -1, 1)
+	if flags&ALU_FLAGS_NEGATIVE == 0 || flags&ALU_FLAGS_CARRY != 0 {
+		t.Errorf("OP_CMPINT64 Expected NEGATIVE without CARRY, got %d %d %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUInt64(ALU_OP_CMPINT64, 1, -1)
+	if flags&ALU_FLAGS_NEGATIVE != 0 || flags&ALU_FLAGS_CARRY == 0 {
+		t.Errorf("OP_CMPINT64 Expected CARRY without NEGATIVE, got %d %d %b", outA, outB, flags)
+	}
+	outA, outB, flags = ALUInt64(ALU_OP_TESTINT64, 0xF0, 0x0F)
+	if outA != 0xF0 || flags&ALU_FLAGS_ZERO == 0 {
+		t.Errorf("OP_TESTINT64 Expected 0xF0 ZERO, got %d %d %b", outA, outB, flags)
+	}
+	fA, fB, flags := ALUFloat64(ALU_OP_FCMP64, 1.0, 2.0)
+	if fA != 1.0 || flags&ALU_FLAGS_CARRY == 0 || flags&ALU_FLAGS_UNORDERED != 0 {
+		t.Errorf("OP_FCMP64 Expected CARRY, got %f %f %b", fA, fB, flags)
+	}
+	fA, fB, flags = ALUFloat64(ALU_OP_FCMP64, math.NaN(), 2.0)
+	if flags != ALU_FLAGS_UNORDERED {
+		t.Errorf("OP_FCMP64 Expected UNORDERED, got %f %f %b", fA, fB, flags)
+	}
+}
@@ -0,0 +1,324 @@
+package Onyx1Assembler
+
+import (
+	Onyx1ISA "GolangCPUParts/ISA"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Assembler turns Onyx1 assembly into machine code in two passes. The first pass sizes
+// every statement and gives the labels their addresses, the second evaluates the operands
+// and emits the code, so labels can be used before they are defined. A line is
+//
+//	label: MNEMONIC operands ; comment
+//
+// with every part optional. Mnemonics, directives and register names ignore case,
+// symbols do not. The directives are
+//
+//	.org expr              continue at address expr
+//	.byte expr|"str", ...  8-bit values
+//	.word expr, ...        16-bit values
+//	.dword expr, ...       32-bit values
+//	.quad expr, ...        64-bit values
+//	.ascii "str", ...      the bytes of the strings
+//	.align expr            pad with zeros to a multiple of expr, a power of two
+//	.equ name, expr        define a symbol, also written name = expr
+//	.include "file"        assemble file here, found relative to this file
+//	.macro NAME p1, p2     start a macro, used as NAME a1, a2, ending at .endm
+//
+// The expressions in .org, .align and .equ are evaluated in the first pass, so the symbols
+// they use must be defined earlier in the source.
+type Assembler struct {
+	// ReadFile reads .include files and the file given to AssembleFile, os.ReadFile unless set
+	ReadFile func(name string) ([]byte, error)
+
+	predefined map[string]uint64
+	symbols    map[string]uint64
+	labels     map[string]uint64
+	macros     map[string]*macro
+	expansions int
+	pass       int
+	pc         uint64
+	undefined  bool
+}
+
+func Assembler_Initialize() *Assembler {
+	return &Assembler{ReadFile: os.ReadFile, predefined: map[string]uint64{}}
+}
+
+// Define predefines a symbol for every program assembled, such as a port number
+func (a *Assembler) Define(name string, value uint64) {
+	a.predefined[name] = value
+}
+
+// AssembleFile assembles the file at path
+func (a *Assembler) AssembleFile(path string) (*Program, error) {
+	b, err := a.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return a.Assemble(path, string(b))
+}
+
+// Assemble assembles src, using file as its name in errors and for finding .include files.
+// Errors are reported as file:line: message.
+func (a *Assembler) Assemble(file string, src string) (*Program, error) {
+	a.symbols = map[string]uint64{}
+	for name, v := range a.predefined {
+		a.symbols[name] = v
+	}
+	a.labels = map[string]uint64{}
+	a.macros = map[string]*macro{}
+	a.expansions = 0
+	lines, err := a.preprocess(file, src)
+	if err != nil {
+		return nil, err
+	}
+	prog := Program{Symbols: a.symbols, Labels: a.labels}
+	var listing strings.Builder
+	for a.pass = 1; a.pass <= 2; a.pass++ {
+		a.pc = 0
+		for _, sl := range lines {
+			b, err := a.statement(sl)
+			if err != nil {
+				return nil, sl.errorf(err.Error())
+			}
+			addr := a.pc
+			if a.pass == 2 {
+				prog.emit(addr, b)
+				listLine(&listing, sl, addr, b)
+			}
+			a.pc = addr + uint64(len(b))
+		}
+	}
+	listSymbols(&listing, a.symbols)
+	prog.Listing = listing.String()
+	return &prog, nil
+}
+
+func (sl sourceLine) errorf(msg string) error {
+	return fmt.Errorf("%s:%d: %s", sl.file, sl.line, msg)
+}
+
+// symbolValue is the value of a symbol in an expression. In the first pass a symbol that
+// isn't defined yet is 0, and undefined is set for the directives that can't allow it.
+func (a *Assembler) symbolValue(name string) (uint64, error) {
+	if name == "." || name == "$" {
+		return a.pc, nil
+	}
+	if _, ok := registerNumber(name); ok {
+		return 0, errors.New("Register " + name + " used in an expression")
+	}
+	v, ok := a.symbols[name]
+	if !ok {
+		if a.pass == 1 {
+			a.undefined = true
+			return 0, nil
+		}
+		return 0, errors.New("Undefined symbol " + name)
+	}
+	return v, nil
+}
+
+// evaluateNow evaluates an expression that must be known in the first pass
+func (a *Assembler) evaluateNow(s string) (uint64, error) {
+	v, err := a.evaluate(s)
+	if err == nil && a.undefined {
+		return 0, errors.New("Expression must only use symbols defined before it")
+	}
+	return v, err
+}
+
+// define gives a symbol its value in the first pass
+func (a *Assembler) define(name string, v uint64) error {
+	if a.pass != 1 {
+		return nil
+	}
+	if _, ok := registerNumber(name); ok || name == "." || !isIdentStart(name[0]) {
+		return errors.New("Bad symbol name " + name)
+	}
+	if _, ok := a.symbols[name]; ok {
+		return errors.New("Symbol " + name + " already defined")
+	}
+	a.symbols[name] = v
+	return nil
+}
+
+// statement assembles one line, returning its bytes. .org moves the pc and .align returns
+// its padding. Everything else leaves the pc for the caller to advance.
+func (a *Assembler) statement(sl sourceLine) ([]byte, error) {
+	label, rest := splitLabel(sl.code)
+	for label != "" {
+		err := a.define(label, a.pc)
+		if err != nil {
+			return nil, err
+		}
+		if a.pass == 1 {
+			a.labels[label] = a.pc
+		}
+		label, rest = splitLabel(rest)
+	}
+	if rest == "" {
+		return nil, nil
+	}
+	if i := strings.IndexByte(rest, '='); i > 0 && isSymbol(strings.TrimSpace(rest[:i])) {
+		return nil, a.equate(strings.TrimSpace(rest[:i]), rest[i+1:])
+	}
+	mnemonic, operands := splitMnemonic(rest)
+	if strings.HasPrefix(mnemonic, ".") {
+		return a.directive(strings.ToLower(mnemonic), operands)
+	}
+	inst, ok, err := a.instruction(mnemonic, operands)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("Unknown instruction " + mnemonic)
+	}
+	inst.Address = a.pc
+	return Onyx1ISA.Encode(inst)
+}
+
+func isSymbol(s string) bool {
+	if s == "" || !isIdentStart(s[0]) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isIdentChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *Assembler) equate(name string, expr string) error {
+	if a.pass != 1 {
+		return nil
+	}
+	v, err := a.evaluateNow(expr)
+	if err != nil {
+		return err
+	}
+	return a.define(name, v)
+}
+
+func (a *Assembler) directive(name string, operands string) ([]byte, error) {
+	args := splitOperands(operands)
+	switch name {
+	case ".org":
+		if len(args) != 1 {
+			return nil, errors.New(".org needs an address")
+		}
+		v, err := a.evaluateNow(args[0])
+		if err != nil {
+			return nil, err
+		}
+		a.pc = v
+		return nil, nil
+	case ".align":
+		if len(args) != 1 {
+			return nil, errors.New(".align needs an alignment")
+		}
+		v, err := a.evaluateNow(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if v == 0 || v&(v-1) != 0 {
+			return nil, errors.New(".align must be a power of two")
+		}
+		return make([]byte, (v-a.pc%v)%v), nil
+	case ".equ":
+		if len(args) != 2 || !isSymbol(args[0]) {
+			return nil, errors.New(".equ needs a name and a value")
+		}
+		return nil, a.equate(args[0], args[1])
+	case ".byte":
+		return a.data(args, 1, true)
+	case ".word":
+		return a.data(args, 2, false)
+	case ".dword":
+		return a.data(args, 4, false)
+	case ".quad":
+		return a.data(args, 8, false)
+	case ".ascii":
+		var b []byte
+		for _, arg := range args {
+			s, n, err := unquoteOperand(arg)
+			if err != nil || n != len(arg) {
+				return nil, errors.New(".ascii needs quoted strings")
+			}
+			b = append(b, s...)
+		}
+		return b, nil
+	}
+	return nil, errors.New("Unknown directive " + name)
+}
+
+// data emits each value little-endian in size bytes. A value must fit either signed or
+// unsigned, so .byte takes -128 to 255.
+func (a *Assembler) data(args []string, size int, strs bool) ([]byte, error) {
+	if len(args) == 0 {
+		return nil, errors.New("Missing data")
+	}
+	var b []byte
+	for _, arg := range args {
+		if strs && strings.HasPrefix(arg, "\"") {
+			s, n, err := unquoteOperand(arg)
+			if err != nil || n != len(arg) {
+				return nil, errors.New("Bad string " + arg)
+			}
+			b = append(b, s...)
+			continue
+		}
+		v, err := a.evaluate(arg)
+		if err != nil {
+			return nil, err
+		}
+		if size < 8 && a.pass == 2 {
+			bits := uint(size * 8)
+			if int64(v) < -1<<(bits-1) || int64(v) >= 1<<bits {
+				return nil, errors.New("Value " + arg + " doesn't fit in " + fmt.Sprint(bits) + " bits")
+			}
+		}
+		for i := 0; i < size; i++ {
+			b = append(b, byte(v>>(8*i)))
+		}
+	}
+	return b, nil
+}
+
+// listLine adds a source line to the listing with its address and up to 12 of its bytes,
+// continuing on further lines for longer data
+func listLine(w *strings.Builder, sl sourceLine, addr uint64, b []byte) {
+	for i := 0; i == 0 || i < len(b); i += Onyx1ISA.ISA_MAX_LENGTH {
+		hex := ""
+		for j := i; j < len(b) && j < i+Onyx1ISA.ISA_MAX_LENGTH; j++ {
+			hex += fmt.Sprintf("%02X ", b[j])
+		}
+		text := ""
+		if i == 0 {
+			text = sl.text
+		}
+		if sl.code == "" {
+			fmt.Fprintf(w, "%5d %16s  %-36s %s\n", sl.line, "", hex, text)
+		} else {
+			fmt.Fprintf(w, "%5d %016X  %-36s %s\n", sl.line, addr+uint64(i), hex, text)
+		}
+	}
+}
+
+// listSymbols ends the listing with the symbol table in name order
+func listSymbols(w *strings.Builder, symbols map[string]uint64) {
+	names := make([]string, 0, len(symbols))
+	for name := range symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(w, "\nSymbols\n")
+	for _, name := range names {
+		fmt.Fprintf(w, "%-24s %016X\n", name, symbols[name])
+	}
+}
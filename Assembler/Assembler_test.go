@@ -0,0 +1,284 @@
+package Onyx1Assembler
+
+import (
+	Onyx1ALU "GolangCPUParts/ALU"
+	Onyx1CPU "GolangCPUParts/CPU"
+	"GolangCPUParts/Configuration"
+	Onyx1ISA "GolangCPUParts/ISA"
+	"GolangCPUParts/MemoryPackage/PhysicalMemory"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func assemble(t *testing.T, src string) *Program {
+	prog, err := Assembler_Initialize().Assemble("test.s", src)
+	if err != nil {
+		t.Fatalf("Assemble Expected no error, got %s", err)
+	}
+	return prog
+}
+
+func testMemory(t *testing.T, profile string) *PhysicalMemory.PhysicalMemoryManager {
+	s, _ := Configuration.MockConfig()
+	cfg, err := Configuration.LoadConfiguration(s)
+	if err != nil {
+		t.Fatalf("Assemble Expected the mock configuration to load, got %s", err)
+	}
+	pmc, err := PhysicalMemory.PhysicalMemoryInitialize(cfg, profile)
+	if err != nil {
+		t.Fatalf("Assemble Expected physical memory, got %s", err)
+	}
+	return pmc
+}
+
+func TestAssembleInstructions(t *testing.T) {
+	prog := assemble(t, `
+		nop
+start:	MOV R1, #0x10          ; immediate
+		add.d r2, r1, r3
+		NOT R4, R5
+		CMP R1, 7
+		LOAD.W R5, [R6]
+		STORE.B R7, [R8 + R9*8 - 4]
+		LEA SP, [R2 + 0x100]
+		LEA R3, [R2 + R4]
+		JNE start
+		JMP [R1 + R2*8]
+		CALL start + 4
+		PUSH R1
+		POP R1
+		IN.B R1, 0x20
+		RET
+		HALT
+	`)
+	ops := func(op int) Onyx1ISA.Operand {
+		return Onyx1ISA.Operand{Mode: Onyx1ISA.ISA_MODE_REGISTER, Reg: op}
+	}
+	imm := func(v uint64) Onyx1ISA.Operand {
+		return Onyx1ISA.Operand{Mode: Onyx1ISA.ISA_MODE_IMMEDIATE, Immediate: v}
+	}
+	want := []Onyx1ISA.Instruction{
+		{Opcode: Onyx1ISA.ISA_OP_NOP, Size: Onyx1ISA.ISA_SIZE_64},
+		{Opcode: Onyx1ISA.ISA_OP_MOV, Size: Onyx1ISA.ISA_SIZE_64, Rd: 1, Operand: imm(0x10)},
+		{Opcode: Onyx1ISA.ISA_OP_ALU, Size: Onyx1ISA.ISA_SIZE_32, Func: Onyx1ALU.ALU_OP_ADDINT64, Rd: 2, Ra: 1, Operand: ops(3)},
+		{Opcode: Onyx1ISA.ISA_OP_ALU, Size: Onyx1ISA.ISA_SIZE_64, Func: Onyx1ALU.ALU_OP_NOTINT64, Rd: 4, Ra: 5},
+		{Opcode: Onyx1ISA.ISA_OP_ALU, Size: Onyx1ISA.ISA_SIZE_64, Func: Onyx1ALU.ALU_OP_CMPINT64, Ra: 1, Operand: imm(7)},
+		{Opcode: Onyx1ISA.ISA_OP_LOAD, Size: Onyx1ISA.ISA_SIZE_16, Rd: 5,
+			Operand: Onyx1ISA.Operand{Mode: Onyx1ISA.ISA_MODE_INDIRECT, Reg: 6}},
+		{Opcode: Onyx1ISA.ISA_OP_STORE, Size: Onyx1ISA.ISA_SIZE_8, Rd: 7,
+			Operand: Onyx1ISA.Operand{Mode: Onyx1ISA.ISA_MODE_INDEXED, Reg: 8, Index: 9, Scale: 3, Disp: -4}},
+		{Opcode: Onyx1ISA.ISA_OP_LEA, Size: Onyx1ISA.ISA_SIZE_64, Rd: 15,
+			Operand: Onyx1ISA.Operand{Mode: Onyx1ISA.ISA_MODE_INDEXED, Reg: 2, Index: Onyx1ISA.ISA_NO_INDEX, Disp: 0x100}},
+		{Opcode: Onyx1ISA.ISA_OP_LEA, Size: Onyx1ISA.ISA_SIZE_64, Rd: 3,
+			Operand: Onyx1ISA.Operand{Mode: Onyx1ISA.ISA_MODE_INDEXED, Reg: 2, Index: 4}},
+		{Opcode: Onyx1ISA.ISA_OP_JMP, Size: Onyx1ISA.ISA_SIZE_64, Func: Onyx1ALU.ALU_COND_NE, Operand: imm(4)},
+		{Opcode: Onyx1ISA.ISA_OP_JMP, Size: Onyx1ISA.ISA_SIZE_64,
+			Operand: Onyx1ISA.Operand{Mode: Onyx1ISA.ISA_MODE_INDEXED, Reg: 1, Index: 2, Scale: 3}},
+		{Opcode: Onyx1ISA.ISA_OP_CALL, Size: Onyx1ISA.ISA_SIZE_64, Operand: imm(8)},
+		{Opcode: Onyx1ISA.ISA_OP_PUSH, Size: Onyx1ISA.ISA_SIZE_64, Rd: 1},
+		{Opcode: Onyx1ISA.ISA_OP_POP, Size: Onyx1ISA.ISA_SIZE_64, Rd: 1},
+		{Opcode: Onyx1ISA.ISA_OP_IN, Size: Onyx1ISA.ISA_SIZE_8, Rd: 1, Operand: imm(0x20)},
+		{Opcode: Onyx1ISA.ISA_OP_RET, Size: Onyx1ISA.ISA_SIZE_64},
+		{Opcode: Onyx1ISA.ISA_OP_HALT, Size: Onyx1ISA.ISA_SIZE_64},
+	}
+	var code []byte
+	for _, inst := range want {
+		b, err := Onyx1ISA.Encode(inst)
+		if err != nil {
+			t.Fatalf("Encode Expected %+v to encode, got %s", inst, err)
+		}
+		code = append(code, b...)
+	}
+	if len(prog.Segments) != 1 || prog.Segments[0].Address != 0 || !bytes.Equal(prog.Segments[0].Bytes, code) {
+		t.Errorf("Assemble Expected % x, got %+v", code, prog.Segments)
+	}
+	if prog.Symbols["start"] != 4 {
+		t.Errorf("Assemble Expected start at 4, got %d", prog.Symbols["start"])
+	}
+}
+
+func TestAssembleDirectives(t *testing.T) {
+	prog := assemble(t, `
+BASE = 0x100
+		.equ COUNT, (1 << 4) - 2*3   ; 10
+		.org BASE
+table:	.byte 1, -1, 'A', "hi", COUNT % 3
+		.word 0x1234, -2
+		.align 8
+		.quad end - table, ~0
+		.dword $ - table
+		.ascii "a;b\n"
+end:
+		.org 0x200
+		.byte end & 0xFF, 8 / HALF, 7 % HALF
+HALF = 2
+	`)
+	want := []Segment{
+		{0x100, []byte{1, 0xFF, 'A', 'h', 'i', 1, 0x34, 0x12, 0xFE, 0xFF, 0, 0, 0, 0, 0, 0,
+			0x28, 0, 0, 0, 0, 0, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+			0x20, 0, 0, 0, 'a', ';', 'b', '\n'}},
+		{0x200, []byte{0x28, 4, 1}},
+	}
+	if len(prog.Segments) != len(want) {
+		t.Fatalf("Assemble Expected %d segments, got %+v", len(want), prog.Segments)
+	}
+	for i, seg := range want {
+		if prog.Segments[i].Address != seg.Address || !bytes.Equal(prog.Segments[i].Bytes, seg.Bytes) {
+			t.Errorf("Assemble Expected segment %x % x, got %x % x", seg.Address, seg.Bytes,
+				prog.Segments[i].Address, prog.Segments[i].Bytes)
+		}
+	}
+	if prog.Symbols["COUNT"] != 10 || prog.Symbols["end"] != 0x128 {
+		t.Errorf("Assemble Expected COUNT 10 and end 0x128, got %+v", prog.Symbols)
+	}
+	if len(prog.Labels) != 2 || prog.Labels["table"] != 0x100 || prog.Labels["end"] != 0x128 {
+		t.Errorf("Assemble Expected the labels table and end only, got %+v", prog.Labels)
+	}
+	image, err := prog.Image(0x100, 0x100)
+	if err == nil {
+		t.Errorf("Image Expected an error for a program larger than the image")
+	}
+	image, err = prog.Image(0x100, 0x200)
+	if err != nil || len(image) != 0x200 || image[0] != 1 || image[0x100] != 0x28 || image[0x50] != 0 {
+		t.Errorf("Image Expected the program at 0x100, got %v", err)
+	}
+	if !strings.Contains(prog.Listing, "0000000000000100  01 FF 41 68 69 01") ||
+		!strings.Contains(prog.Listing, "0000000000000124  61 3B 62 0A") ||
+		!strings.Contains(prog.Listing, "table                    0000000000000100") {
+		t.Errorf("Assemble Expected addresses, bytes and symbols in the listing, got\n%s", prog.Listing)
+	}
+}
+
+func TestAssembleMacrosAndIncludes(t *testing.T) {
+	files := map[string]string{
+		"src/main.s": `
+			.include "lib/defs.s"
+	top:	INC R1
+			INC R2
+			LOOP R3, top
+			HALT
+		`,
+		"src/lib/defs.s": `
+			.macro INC r
+			ADD \r, \r, #STEP
+			.endm
+			.macro LOOP r, target
+			SUB \r, \r, 1
+			CMP \r, 0
+			JNE \target
+	skip\@:
+			.endm
+			.include "step.s"
+		`,
+		"src/lib/step.s": "STEP = 1\n",
+	}
+	a := Assembler_Initialize()
+	a.ReadFile = func(name string) ([]byte, error) {
+		s, ok := files[name]
+		if !ok {
+			return nil, errors.New("No file " + name)
+		}
+		return []byte(s), nil
+	}
+	prog, err := a.AssembleFile("src/main.s")
+	if err != nil {
+		t.Fatalf("AssembleFile Expected no error, got %s", err)
+	}
+	want := assemble(t, `
+	top:	ADD R1, R1, #1
+			ADD R2, R2, #1
+			SUB R3, R3, 1
+			CMP R3, 0
+			JNE top
+			HALT
+	`)
+	if !bytes.Equal(prog.Segments[0].Bytes, want.Segments[0].Bytes) {
+		t.Errorf("AssembleFile Expected % x, got % x", want.Segments[0].Bytes, prog.Segments[0].Bytes)
+	}
+	// INC is expanded twice before LOOP
+	if _, ok := prog.Symbols["skip3"]; !ok || prog.Symbols["top"] != 0 {
+		t.Errorf("AssembleFile Expected the macro's label and top, got %+v", prog.Symbols)
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"NOP\nFROB R1", "test.s:2: Unknown instruction FROB"},
+		{"JMP nowhere", "test.s:1: Undefined symbol nowhere"},
+		{"x: NOP\nx: NOP", "test.s:2: Symbol x already defined"},
+		{"ADD R1, R2", "test.s:1: ADD needs 3 operands"},
+		{"MOV R16, 1", "test.s:1: Expected a register, got R16"},
+		{"LOAD R1, [R2 + R3*3]", "test.s:1: Scale must be 1, 2, 4 or 8"},
+		{"MOV R1, [R2]", "test.s:1:"},
+		{".byte 256", "test.s:1: Value 256 doesn't fit in 8 bits"},
+		{".org later\nlater:", "test.s:1: Expression must only use symbols defined before it"},
+		{".byte 8 / (2 - 2)", "test.s:1: Division by zero in expression"},
+		{".byte 8 % zero\nzero = 0", "test.s:1: Division by zero in expression"},
+		{"FADDX R1, R2, R3", "test.s:1: FADDX can't run on registers"},
+		{".align 3", "test.s:1: .align must be a power of two"},
+		{"RET.B", "test.s:1: RET takes no size suffix"},
+		{".macro M a\nNOP", "test.s:1: .macro M has no .endm"},
+		{".macro M\nM\n.endm\nM", "test.s:4: Macros nested too deeply"},
+		{".include \"missing.s\"", "test.s:1: No file missing.s"},
+		{"ADD R1, R1, R2 + 1", "test.s:1: Register R2 used in an expression"},
+	}
+	for _, tt := range tests {
+		a := Assembler_Initialize()
+		a.ReadFile = func(name string) ([]byte, error) { return nil, errors.New("No file " + name) }
+		_, err := a.Assemble("test.s", tt.src)
+		if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("Assemble %q Expected %q, got %v", tt.src, tt.want, err)
+		}
+	}
+}
+
+func TestAssembleLoadAndRun(t *testing.T) {
+	prog := assemble(t, `
+		.org 0x100
+		MOV R1, #0
+		MOV R2, #COUNT
+loop:	ADD R1, R1, R2
+		SUB R2, R2, 1
+		JNE loop
+		STORE R1, [R0 + result]
+		HALT
+result:	.quad 0
+COUNT = 10
+	`)
+	pmc := testMemory(t, "Kaypro-CPM-64KB")
+	err := prog.LoadRegion(pmc, 0)
+	if err != nil {
+		t.Fatalf("LoadRegion Expected no error, got %s", err)
+	}
+	cpu, err := Onyx1CPU.CPU_Initialize(Configuration.CPUDescriptor{}, pmc)
+	if err != nil {
+		t.Fatalf("CPU Expected to initialize, got %s", err)
+	}
+	cpu.Reset(0x100)
+	err = cpu.Run(100)
+	if err != nil {
+		t.Fatalf("Run Expected no error, got %s", err)
+	}
+	v, _ := cpu.ReadMemory(prog.Symbols["result"], Onyx1ALU.ALU_WIDTH_64)
+	if v != 55 {
+		t.Errorf("Run Expected 55, got %d", v)
+	}
+
+	// The Old-IBM-Mainframe profile's block 1 is Kernel-RAM at 0x20000
+	kernel := assemble(t, ".org 0x20000\nHALT")
+	pmc = testMemory(t, "Old-IBM-Mainframe")
+	err = kernel.LoadRegion(pmc, 1)
+	block, _ := pmc.GetBlockByKey(1)
+	if err != nil || block.Buffer[0] != Onyx1ISA.ISA_OP_HALT<<2|Onyx1ISA.ISA_SIZE_64 {
+		t.Errorf("LoadRegion Expected HALT at the start of Kernel-RAM, got %v", err)
+	}
+	err = prog.LoadRegion(pmc, 1)
+	if err == nil {
+		t.Errorf("LoadRegion Expected an error for a program outside the block")
+	}
+}
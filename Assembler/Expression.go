@@ -0,0 +1,304 @@
+package Onyx1Assembler
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const (
+	tokenNumber = iota
+	tokenIdent
+	tokenString
+	tokenOp
+)
+
+type token struct {
+	kind  int
+	text  string
+	value uint64
+}
+
+// tokenize splits operand text into numbers, identifiers, strings and operators. Numbers
+// are decimal, 0x hex, 0b binary or a 'c' character constant.
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case isDigit(c):
+			j := i
+			for j < len(s) && (isIdentChar(s[j])) {
+				j++
+			}
+			v, err := strconv.ParseUint(strings.ReplaceAll(s[i:j], "_", ""), 0, 64)
+			if err != nil {
+				return nil, errors.New("Bad number " + s[i:j])
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: s[i:j], value: v})
+			i = j
+		case isIdentStart(c) || c == '.' && (i+1 == len(s) || !isIdentChar(s[i+1])):
+			j := i + 1
+			for j < len(s) && isIdentChar(s[j]) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: s[i:j]})
+			i = j
+		case c == '$':
+			tokens = append(tokens, token{kind: tokenIdent, text: "$"})
+			i++
+		case c == '\'' || c == '"':
+			str, n, err := unquote(s[i:])
+			if err != nil {
+				return nil, err
+			}
+			if c == '\'' {
+				if len(str) != 1 {
+					return nil, errors.New("Bad character constant " + s[i:i+n])
+				}
+				tokens = append(tokens, token{kind: tokenNumber, text: s[i : i+n], value: uint64(str[0])})
+			} else {
+				tokens = append(tokens, token{kind: tokenString, text: str})
+			}
+			i += n
+		case c == '<' || c == '>':
+			if i+1 == len(s) || s[i+1] != c {
+				return nil, errors.New("Unexpected " + string(c))
+			}
+			tokens = append(tokens, token{kind: tokenOp, text: s[i : i+2]})
+			i += 2
+		case strings.IndexByte("+-*/%&|^~()[]#,", c) >= 0:
+			tokens = append(tokens, token{kind: tokenOp, text: string(c)})
+			i++
+		default:
+			return nil, errors.New("Unexpected " + string(c))
+		}
+	}
+	return tokens, nil
+}
+
+// unquote reads a quoted string or character constant from the start of s, returning its
+// value and the number of bytes of s it used. \n, \t, \r, \0, \\ and the quotes are the
+// only escapes.
+func unquote(s string) (string, int, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if c == quote {
+			return b.String(), i + 1, nil
+		}
+		if c == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n':
+				c = '\n'
+			case 't':
+				c = '\t'
+			case 'r':
+				c = '\r'
+			case '0':
+				c = 0
+			case '\\', '\'', '"':
+				c = s[i]
+			default:
+				return "", 0, errors.New("Bad escape \\" + string(s[i]))
+			}
+		}
+		b.WriteByte(c)
+	}
+	return "", 0, errors.New("Unterminated string")
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || c == '.'
+}
+
+// registerNumber returns the register R0-R15 or SP names, ignoring case
+func registerNumber(name string) (int, bool) {
+	u := strings.ToUpper(name)
+	if u == "SP" {
+		return 15, true
+	}
+	if len(u) < 2 || u[0] != 'R' {
+		return 0, false
+	}
+	n, err := strconv.Atoi(u[1:])
+	if err != nil || n < 0 || n > 15 || strconv.Itoa(n) != u[1:] {
+		return 0, false
+	}
+	return n, true
+}
+
+// exprParser evaluates expressions by recursive descent with C precedence, from lowest:
+// | ^ & (<< >>) (+ -) (* / %) and the unary - ~ +. Arithmetic wraps at 64 bits and / and
+// % are signed. "." and "$" are the address of the current statement.
+type exprParser struct {
+	tokens []token
+	pos    int
+	asm    *Assembler
+}
+
+func (p *exprParser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+// accept consumes the next token if it is the operator op
+func (p *exprParser) accept(op string) bool {
+	t, ok := p.peek()
+	if ok && t.kind == tokenOp && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+var binaryLevels = [][]string{
+	{"|"},
+	{"^"},
+	{"&"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *exprParser) expr() (uint64, error) {
+	return p.binary(0)
+}
+
+func (p *exprParser) binary(level int) (uint64, error) {
+	if level == len(binaryLevels) {
+		return p.unary()
+	}
+	a, err := p.binary(level + 1)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := ""
+		for _, o := range binaryLevels[level] {
+			if p.accept(o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return a, nil
+		}
+		b, err := p.binary(level + 1)
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case "|":
+			a |= b
+		case "^":
+			a ^= b
+		case "&":
+			a &= b
+		case "<<":
+			a <<= b
+		case ">>":
+			a >>= b
+		case "+":
+			a += b
+		case "-":
+			a -= b
+		case "*":
+			a *= b
+		case "/", "%":
+			if b == 0 && p.asm.undefined {
+				// A forward reference in the first pass, which the second pass will check
+				a = 0
+				continue
+			}
+			if b == 0 {
+				return 0, errors.New("Division by zero in expression")
+			}
+			if op == "/" {
+				a = uint64(int64(a) / int64(b))
+			} else {
+				a = uint64(int64(a) % int64(b))
+			}
+		}
+	}
+}
+
+func (p *exprParser) unary() (uint64, error) {
+	switch {
+	case p.accept("-"):
+		v, err := p.unary()
+		return -v, err
+	case p.accept("~"):
+		v, err := p.unary()
+		return ^v, err
+	case p.accept("+"):
+		return p.unary()
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (uint64, error) {
+	t, ok := p.peek()
+	if !ok {
+		return 0, errors.New("Missing expression")
+	}
+	p.pos++
+	switch t.kind {
+	case tokenNumber:
+		return t.value, nil
+	case tokenIdent:
+		return p.asm.symbolValue(t.text)
+	case tokenOp:
+		if t.text == "(" {
+			v, err := p.expr()
+			if err != nil {
+				return 0, err
+			}
+			if !p.accept(")") {
+				return 0, errors.New("Missing )")
+			}
+			return v, nil
+		}
+	}
+	return 0, errors.New("Unexpected " + t.text + " in expression")
+}
+
+// evaluate evaluates the whole of s as one expression
+func (a *Assembler) evaluate(s string) (uint64, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return 0, err
+	}
+	return a.evaluateTokens(tokens)
+}
+
+func (a *Assembler) evaluateTokens(tokens []token) (uint64, error) {
+	a.undefined = false
+	p := exprParser{tokens: tokens, asm: a}
+	v, err := p.expr()
+	if err != nil {
+		return 0, err
+	}
+	if !p.done() {
+		return 0, errors.New("Unexpected " + p.tokens[p.pos].text + " in expression")
+	}
+	return v, nil
+}
@@ -0,0 +1,222 @@
+package Onyx1Assembler
+
+import (
+	Onyx1ALU "GolangCPUParts/ALU"
+	Onyx1ISA "GolangCPUParts/ISA"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// isaMnemonics maps the instruction mnemonics other than the ALU ops to their opcodes.
+// Conditional jumps are J followed by an ALU condition name, JEQ, JNE and so on, and JMP
+// is the unconditional jump.
+var isaMnemonics = map[string]int{}
+
+func init() {
+	for op, info := range Onyx1ISA.ISAOpcodes {
+		if op != Onyx1ISA.ISA_OP_ALU {
+			isaMnemonics[info.Mnemonic] = op
+		}
+	}
+}
+
+var scaleShifts = map[uint64]int{1: 0, 2: 1, 4: 2, 8: 3}
+
+// lookupMnemonic finds the opcode and function for a mnemonic without its size suffix
+func lookupMnemonic(name string) (opcode int, fn int, ok bool) {
+	u := strings.ToUpper(name)
+	if op, ok := isaMnemonics[u]; ok {
+		return op, 0, true
+	}
+	if info, ok := Onyx1ALU.ALULookupMnemonic(u); ok {
+		return Onyx1ISA.ISA_OP_ALU, info.Op, true
+	}
+	if strings.HasPrefix(u, "J") {
+		for cond, c := range Onyx1ALU.ALUConditionNames {
+			if u[1:] == c {
+				return Onyx1ISA.ISA_OP_JMP, cond, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// splitSize splits a .B, .W, .D or .Q size suffix off a mnemonic
+func splitSize(mnemonic string) (string, int, bool) {
+	i := strings.LastIndexByte(mnemonic, '.')
+	if i <= 0 {
+		return mnemonic, Onyx1ISA.ISA_SIZE_64, false
+	}
+	for size, suffix := range Onyx1ISA.ISASizeSuffixes {
+		if strings.EqualFold(mnemonic[i:], suffix) {
+			return mnemonic[:i], size, true
+		}
+	}
+	return mnemonic, Onyx1ISA.ISA_SIZE_64, false
+}
+
+// instruction parses one instruction. ok is false when mnemonic is not an instruction.
+// The operands are, by form:
+//
+//	NOP, HALT, RET, BRK, RTE
+//	PUSH Rd, POP Rd, GETSR Rd
+//	MOV, LOAD, STORE, LEA, IN, OUT   Rd, operand
+//	JMP, Jcc, CALL, SETSR, SYSCALL   operand
+//	ALU ops                          Rd, Ra, operand   or Rd, Ra for one source, Ra, operand
+//	                                 for the compares that only set flags
+func (a *Assembler) instruction(mnemonic string, operands string) (inst Onyx1ISA.Instruction, ok bool, err error) {
+	name, size, sized := splitSize(mnemonic)
+	opcode, fn, ok := lookupMnemonic(name)
+	if !ok {
+		return inst, false, nil
+	}
+	info := Onyx1ISA.ISAOpcodes[opcode]
+	if sized && !info.Sized {
+		return inst, true, errors.New(strings.ToUpper(name) + " takes no size suffix")
+	}
+	inst = Onyx1ISA.Instruction{Opcode: opcode, Size: size, Func: fn}
+	args := splitOperands(operands)
+	var regs []*int
+	hasOperand := false
+	switch info.Form {
+	case Onyx1ISA.ISA_FORM_RD:
+		regs = []*int{&inst.Rd}
+	case Onyx1ISA.ISA_FORM_RD_OPERAND:
+		regs, hasOperand = []*int{&inst.Rd}, true
+	case Onyx1ISA.ISA_FORM_OPERAND:
+		hasOperand = true
+	case Onyx1ISA.ISA_FORM_ALU:
+		alu, _ := Onyx1ALU.ALULookupOp(fn)
+		switch {
+		case alu.Results == 0:
+			regs, hasOperand = []*int{&inst.Ra}, true
+		case alu.Arity == 1:
+			regs = []*int{&inst.Rd, &inst.Ra}
+		default:
+			regs, hasOperand = []*int{&inst.Rd, &inst.Ra}, true
+		}
+	}
+	want := len(regs)
+	if hasOperand {
+		want++
+	}
+	if len(args) != want {
+		return inst, true, errors.New(strings.ToUpper(name) + " needs " + operandCount(want))
+	}
+	for i, r := range regs {
+		n, ok := registerNumber(args[i])
+		if !ok {
+			return inst, true, errors.New("Expected a register, got " + args[i])
+		}
+		*r = n
+	}
+	if hasOperand {
+		inst.Operand, err = a.operand(args[len(regs)])
+	}
+	return inst, true, err
+}
+
+func operandCount(n int) string {
+	switch n {
+	case 0:
+		return "no operands"
+	case 1:
+		return "1 operand"
+	}
+	return strconv.Itoa(n) + " operands"
+}
+
+// operand parses a source operand:
+//
+//	Rb                          register
+//	#expr or expr               immediate
+//	[Rb]                        indirect
+//	[Rb + Ri*scale + disp]      indexed, the scale 1, 2, 4 or 8 and each part but Rb optional
+func (a *Assembler) operand(s string) (Onyx1ISA.Operand, error) {
+	var op Onyx1ISA.Operand
+	if r, ok := registerNumber(s); ok {
+		op.Mode, op.Reg = Onyx1ISA.ISA_MODE_REGISTER, r
+		return op, nil
+	}
+	tokens, err := tokenize(s)
+	if err != nil {
+		return op, err
+	}
+	if len(tokens) == 0 {
+		return op, errors.New("Missing operand")
+	}
+	first := tokens[0]
+	if first.kind != tokenOp || first.text != "[" {
+		if first.kind == tokenOp && first.text == "#" {
+			tokens = tokens[1:]
+		}
+		op.Mode = Onyx1ISA.ISA_MODE_IMMEDIATE
+		op.Immediate, err = a.evaluateTokens(tokens)
+		return op, err
+	}
+	last := tokens[len(tokens)-1]
+	if last.kind != tokenOp || last.text != "]" {
+		return op, errors.New("Missing ]")
+	}
+	p := exprParser{tokens: tokens[1 : len(tokens)-1], asm: a}
+	base, ok := p.register()
+	if !ok {
+		return op, errors.New("Memory operand needs a base register")
+	}
+	op.Mode, op.Reg = Onyx1ISA.ISA_MODE_INDIRECT, base
+	if p.done() {
+		return op, nil
+	}
+	op.Mode, op.Index = Onyx1ISA.ISA_MODE_INDEXED, Onyx1ISA.ISA_NO_INDEX
+	save := p.pos
+	if p.accept("+") {
+		if index, ok := p.register(); ok {
+			op.Index = index
+			if p.accept("*") {
+				scale, err := p.unary()
+				if err != nil {
+					return op, err
+				}
+				shift, ok := scaleShifts[scale]
+				if !ok && a.pass == 2 {
+					return op, errors.New("Scale must be 1, 2, 4 or 8")
+				}
+				op.Scale = shift
+			}
+		} else {
+			p.pos = save
+		}
+	}
+	if p.done() {
+		return op, nil
+	}
+	disp, err := p.expr()
+	if err != nil {
+		return op, err
+	}
+	if !p.done() {
+		return op, errors.New("Unexpected " + p.tokens[p.pos].text + " in memory operand")
+	}
+	if int64(disp) < math.MinInt32 || int64(disp) > math.MaxInt32 {
+		if a.pass == 2 {
+			return op, errors.New("Displacement out of range")
+		}
+	}
+	op.Disp = int32(disp)
+	return op, nil
+}
+
+// register consumes the next token if it names a register
+func (p *exprParser) register() (int, bool) {
+	t, ok := p.peek()
+	if !ok || t.kind != tokenIdent {
+		return 0, false
+	}
+	r, ok := registerNumber(t.text)
+	if ok {
+		p.pos++
+	}
+	return r, ok
+}
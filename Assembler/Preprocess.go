@@ -0,0 +1,238 @@
+package Onyx1Assembler
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxIncludeDepth = 16
+	maxMacroDepth   = 16
+)
+
+// sourceLine is one line of source after comments are stripped, .include files are read
+// in and macros expanded. Text is the original line for the listing.
+type sourceLine struct {
+	file string
+	line int
+	text string
+	code string
+}
+
+type macro struct {
+	name   string
+	params []string
+	body   []sourceLine
+}
+
+// stripComment removes a ; comment, leaving any ; inside a string or character constant
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ';':
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// splitOperands splits operand text at the commas outside strings, brackets and parentheses
+func splitOperands(s string) []string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	var parts []string
+	var quote byte
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+// splitLabel splits "name: rest" into its label and the rest. A line without a label
+// returns an empty label.
+func splitLabel(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	i := 0
+	for i < len(s) && isIdentChar(s[i]) {
+		i++
+	}
+	if i > 0 && isIdentStart(s[0]) && i < len(s) && s[i] == ':' {
+		return s[:i], strings.TrimSpace(s[i+1:])
+	}
+	return "", s
+}
+
+// splitMnemonic splits "MNEMONIC operands" at the first space
+func splitMnemonic(s string) (string, string) {
+	i := strings.IndexAny(s, " \t")
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], strings.TrimSpace(s[i+1:])
+}
+
+// preprocess reads file and returns its lines with includes and macros expanded
+func (a *Assembler) preprocess(file string, src string) ([]sourceLine, error) {
+	lines, err := a.readLines(file, src, 0)
+	if err != nil {
+		return nil, err
+	}
+	return a.expandMacros(lines, 0)
+}
+
+// readLines splits src into lines, reading .include files in place. An included file is
+// found relative to the file that includes it.
+func (a *Assembler) readLines(file string, src string, depth int) ([]sourceLine, error) {
+	if depth > maxIncludeDepth {
+		return nil, errors.New(file + ": .include nested too deeply")
+	}
+	var out []sourceLine
+	for i, text := range strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n") {
+		sl := sourceLine{file: file, line: i + 1, text: text, code: strings.TrimSpace(stripComment(text))}
+		label, rest := splitLabel(sl.code)
+		mnemonic, operands := splitMnemonic(rest)
+		if !strings.EqualFold(mnemonic, ".include") {
+			out = append(out, sl)
+			continue
+		}
+		if label != "" {
+			out = append(out, sourceLine{file: file, line: sl.line, text: text, code: label + ":"})
+		}
+		name, n, err := unquoteOperand(operands)
+		if err != nil || n != len(operands) {
+			return nil, sl.errorf(".include needs a quoted file name")
+		}
+		path := name
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(file), name)
+		}
+		b, err := a.ReadFile(path)
+		if err != nil {
+			return nil, sl.errorf(err.Error())
+		}
+		included, err := a.readLines(path, string(b), depth+1)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, included...)
+	}
+	return out, nil
+}
+
+func unquoteOperand(s string) (string, int, error) {
+	if len(s) == 0 || s[0] != '"' {
+		return "", 0, errors.New("Expected a string")
+	}
+	return unquote(s)
+}
+
+// expandMacros collects .macro NAME p1, p2 ... .endm definitions and replaces every use of
+// a macro with its body, \p1 and \p2 replaced by the arguments and \@ by a number unique to
+// the expansion so the body can make its own labels. A label in front of the use is kept.
+func (a *Assembler) expandMacros(lines []sourceLine, depth int) ([]sourceLine, error) {
+	var out []sourceLine
+	for i := 0; i < len(lines); i++ {
+		sl := lines[i]
+		label, rest := splitLabel(sl.code)
+		mnemonic, operands := splitMnemonic(rest)
+		switch {
+		case strings.EqualFold(mnemonic, ".macro"):
+			name, params := splitMnemonic(operands)
+			if name == "" || label != "" {
+				return nil, sl.errorf("Bad .macro")
+			}
+			m := macro{name: strings.ToUpper(name), params: splitOperands(params)}
+			for i++; ; i++ {
+				if i == len(lines) {
+					return nil, sl.errorf(".macro " + name + " has no .endm")
+				}
+				_, r := splitLabel(lines[i].code)
+				inner, _ := splitMnemonic(r)
+				if strings.EqualFold(inner, ".endm") {
+					break
+				}
+				if strings.EqualFold(inner, ".macro") {
+					return nil, lines[i].errorf("Can't define a macro inside a macro")
+				}
+				m.body = append(m.body, lines[i])
+			}
+			a.macros[m.name] = &m
+		case strings.EqualFold(mnemonic, ".endm"):
+			return nil, sl.errorf(".endm without .macro")
+		case a.macros[strings.ToUpper(mnemonic)] != nil:
+			if depth >= maxMacroDepth {
+				return nil, sl.errorf("Macros nested too deeply")
+			}
+			m := a.macros[strings.ToUpper(mnemonic)]
+			args := splitOperands(operands)
+			if len(args) != len(m.params) {
+				return nil, sl.errorf("Macro " + m.name + " takes " + strconv.Itoa(len(m.params)) + " arguments")
+			}
+			if label != "" {
+				out = append(out, sourceLine{file: sl.file, line: sl.line, text: sl.text, code: label + ":"})
+			}
+			a.expansions++
+			body := make([]sourceLine, len(m.body))
+			for j, b := range m.body {
+				body[j] = sourceLine{file: sl.file, line: sl.line, text: "    " + strings.TrimSpace(b.text),
+					code: substitute(b.code, m.params, args, a.expansions)}
+			}
+			expanded, err := a.expandMacros(body, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, expanded...)
+		default:
+			out = append(out, sl)
+		}
+	}
+	return out, nil
+}
+
+// substitute replaces \param with its argument, longest names first so \ab is not taken
+// for \a followed by b, and \@ with the expansion number
+func substitute(s string, params, args []string, n int) string {
+	order := make([]int, len(params))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return len(params[order[i]]) > len(params[order[j]]) })
+	pairs := []string{"\\@", strconv.Itoa(n)}
+	for _, i := range order {
+		pairs = append(pairs, "\\"+params[i], args[i])
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
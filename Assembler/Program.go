@@ -0,0 +1,102 @@
+package Onyx1Assembler
+
+import (
+	"GolangCPUParts/MemoryPackage/PhysicalMemory"
+	"errors"
+)
+
+// Segment is a run of bytes to be loaded at Address
+type Segment struct {
+	Address uint64
+	Bytes   []byte
+}
+
+// Program is the output of the assembler: the code in address order of the source, the
+// symbol table and the listing. Labels holds just the symbols defined as labels, which
+// name addresses, leaving out .equ constants and predefined symbols.
+type Program struct {
+	Segments []Segment
+	Symbols  map[string]uint64
+	Labels   map[string]uint64
+	Listing  string
+}
+
+// MemoryWriter is anything a program can be written into a byte at a time, such as
+// PhysicalMemory.PhysicalMemoryManager or VirtualMemory.VMContainer
+type MemoryWriter interface {
+	WriteAddress(addr uint64, value byte) error
+}
+
+// emit appends b at addr, starting a new segment unless it follows on from the last one
+func (prog *Program) emit(addr uint64, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	n := len(prog.Segments)
+	if n > 0 {
+		last := &prog.Segments[n-1]
+		if last.Address+uint64(len(last.Bytes)) == addr {
+			last.Bytes = append(last.Bytes, b...)
+			return
+		}
+	}
+	prog.Segments = append(prog.Segments, Segment{Address: addr, Bytes: append([]byte{}, b...)})
+}
+
+// Size is the number of bytes of code and data in the program
+func (prog *Program) Size() int {
+	n := 0
+	for _, seg := range prog.Segments {
+		n += len(seg.Bytes)
+	}
+	return n
+}
+
+// LoadInto writes the program through mem
+func (prog *Program) LoadInto(mem MemoryWriter) error {
+	for _, seg := range prog.Segments {
+		for i, b := range seg.Bytes {
+			err := mem.WriteAddress(seg.Address+uint64(i), b)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// LoadRegion copies the program straight into the buffer of a physical memory block,
+// whatever its type and protection, so it can fill ROM and Kernel-RAM. The whole program
+// must be inside the block.
+func (prog *Program) LoadRegion(pmc *PhysicalMemory.PhysicalMemoryManager, key int) error {
+	block, err := pmc.GetBlockByKey(key)
+	if err != nil {
+		return err
+	}
+	for _, seg := range prog.Segments {
+		if !inside(seg, block.StartAddress, uint64(len(block.Buffer))) {
+			return errors.New("Program is outside the memory block")
+		}
+	}
+	for _, seg := range prog.Segments {
+		copy(block.Buffer[seg.Address-block.StartAddress:], seg.Bytes)
+	}
+	return nil
+}
+
+// Image returns the program as size bytes of memory starting at base, the gaps zero, for
+// a Kernel-RAM preload file
+func (prog *Program) Image(base uint64, size uint64) ([]byte, error) {
+	image := make([]byte, size)
+	for _, seg := range prog.Segments {
+		if !inside(seg, base, size) {
+			return nil, errors.New("Program is outside the image")
+		}
+		copy(image[seg.Address-base:], seg.Bytes)
+	}
+	return image, nil
+}
+
+func inside(seg Segment, base uint64, size uint64) bool {
+	return seg.Address >= base && seg.Address-base <= size && uint64(len(seg.Bytes)) <= size-(seg.Address-base)
+}
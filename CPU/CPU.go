@@ -0,0 +1,261 @@
+package Onyx1CPU
+
+import (
+	Onyx1ALU "GolangCPUParts/ALU"
+	"GolangCPUParts/Configuration"
+	"GolangCPUParts/IOSupport"
+	"GolangCPUParts/IOSupport/PortIO"
+	Onyx1ISA "GolangCPUParts/ISA"
+	"GolangCPUParts/MemoryPackage/PhysicalMemory"
+	"errors"
+	"strconv"
+)
+
+const (
+	CPU_NUM_REGISTERS = Onyx1ISA.ISA_NUM_REGISTERS
+	CPU_REG_SP        = Onyx1ISA.ISA_REG_SP
+
+	// The low bits of the status register hold the ALU flags from the last ALU instruction
+	CPU_STATUS_FLAGS = 0x0000_0000_0000_FFFF
+	// Set while the CPU runs in supervisor mode, clear in user mode
+	CPU_STATUS_SUPERVISOR = 0x0000_0000_0001_0000
+	// Set to fault multi-byte accesses that aren't aligned to their width
+	CPU_STATUS_ALIGN = 0x0000_0000_0002_0000
+	// Set to take interrupts between instructions
+	CPU_STATUS_INTERRUPTS = 0x0000_0000_0004_0000
+)
+
+// Memory is the CPU's view of the address space, one byte at a time. Every access passes
+// the mode the CPU is running in, PhysicalMemory.AccessMode_User or AccessMode_System.
+// VirtualMemory.VMContainer and PhysicalMemory.PhysicalMemoryManager both provide it.
+type Memory interface {
+	ReadAddressMode(addr uint64, mode int) (byte, error)
+	WriteAddressMode(addr uint64, value byte, mode int) error
+}
+
+// bus fetches instructions in the CPU's current mode
+type bus struct {
+	cpu *CPUContainer
+}
+
+func (b bus) ReadAddress(addr uint64) (byte, error) {
+	v, err := b.cpu.Memory.ReadAddressMode(addr, b.cpu.accessMode())
+	if err != nil {
+		return 0, memoryTrap(addr, err)
+	}
+	return v, nil
+}
+
+type CPUContainer struct {
+	Registers    [CPU_NUM_REGISTERS]uint64
+	PC           uint64
+	Status       uint64
+	FPControl    uint64
+	FixedControl uint64
+	Cycles       uint64
+	Halted       bool
+	Descriptor   Configuration.CPUDescriptor
+	Memory       Memory
+	Ports        map[uint64]PortIO.PortIOConfigObject
+	Cost         *Onyx1ALU.ALUCostModel
+	VectorBase   uint64
+	KernelStack  uint64
+	KernelSP     uint64
+	Interrupts   *IOSupport.InterruptController
+}
+
+// CPU_Initialize builds a CPU for the machine profile's CPUDescriptor, running out of mem
+// with the devices in PortIO.PortIOConfig on its ports
+func CPU_Initialize(desc Configuration.CPUDescriptor, mem Memory) (*CPUContainer, error) {
+	if mem == nil {
+		return nil, errors.New("CPU needs a memory")
+	}
+	cm, err := Onyx1ALU.ALUCostModel_Initialize(desc)
+	if err != nil {
+		return nil, err
+	}
+	cpu := CPUContainer{Descriptor: desc, Memory: mem, Cost: cm}
+	cpu.Ports = cpu.clockPorts(PortIO.PortIOConfig)
+	if v, ok := desc.Parameters[CPU_PARAM_VECTORS]; ok {
+		cpu.VectorBase, err = strconv.ParseUint(v, 0, 64)
+		if err != nil {
+			return nil, errors.New("Bad vector table address " + v)
+		}
+	}
+	if v, ok := desc.Parameters[CPU_PARAM_KERNEL_STACK]; ok {
+		cpu.KernelStack, err = strconv.ParseUint(v, 0, 64)
+		if err != nil {
+			return nil, errors.New("Bad kernel stack address " + v)
+		}
+	}
+	return &cpu, nil
+}
+
+// AttachInterrupts connects an interrupt controller, putting its registers on the CPU's
+// ports
+func (cpu *CPUContainer) AttachInterrupts(ic *IOSupport.InterruptController) {
+	cpu.Interrupts = ic
+	cpu.Ports = ic.Ports(cpu.Ports)
+}
+
+// Reset clears the processor state and starts it again at pc in supervisor mode, with the
+// kernel stack back at KernelStack
+func (cpu *CPUContainer) Reset(pc uint64) {
+	cpu.Registers = [CPU_NUM_REGISTERS]uint64{}
+	cpu.PC = pc
+	cpu.Status = CPU_STATUS_SUPERVISOR
+	cpu.FPControl = Onyx1ALU.ALU_FPCW_ROUND_NEAREST
+	cpu.FixedControl = 0
+	cpu.Cycles = 0
+	cpu.Cost.Reset()
+	cpu.Halted = false
+	cpu.KernelSP = cpu.KernelStack
+}
+
+// Step fetches, decodes and executes one instruction. An instruction that traps is
+// delivered to its handler through the vector table. When there is no handler the trap
+// is returned, and on any error the PC is left on the instruction that failed. A pending
+// interrupt is taken first, and the instruction run is then the first of its handler.
+// With an interrupt controller attached the timers advance by the cycles the step took.
+func (cpu *CPUContainer) Step() error {
+	if cpu.Halted {
+		return errors.New("CPU is halted")
+	}
+	start := cpu.Cost.Elapsed()
+	err := cpu.step()
+	ticks := cpu.Cost.Elapsed() - start
+	cpu.Cycles = cpu.Cost.Elapsed()
+	if cpu.Interrupts != nil && ticks > 0 {
+		terr := IOSupport.Timer_Tick(cpu.Interrupts, ticks)
+		if err == nil {
+			err = terr
+		}
+	}
+	return err
+}
+
+func (cpu *CPUContainer) step() error {
+	err := cpu.interrupt()
+	if err != nil {
+		return err
+	}
+	pc := cpu.PC
+	inst, err := Onyx1ISA.Decode(bus{cpu}, pc)
+	decoded := err == nil
+	if decoded {
+		cpu.PC += uint64(inst.Length)
+		err = cpu.execute(inst)
+	}
+	if err == nil {
+		return nil
+	}
+	resume := cpu.PC
+	cpu.PC = pc
+	var trap *CPUTrap
+	switch {
+	case errors.As(err, &trap):
+	case !decoded:
+		trap = &CPUTrap{Vector: CPU_VECTOR_ILLEGAL, Address: pc, Err: err}
+	default:
+		return err
+	}
+	if trap.Vector != CPU_VECTOR_BREAKPOINT && trap.Vector != CPU_VECTOR_SYSCALL {
+		resume = pc
+	}
+	return cpu.takeTrap(trap, resume)
+}
+
+// clockPorts returns a copy of ports whose LegacyClockDataPort reads the CPU's cycle
+// count, which the cost model advances by the cost of each instruction
+func (cpu *CPUContainer) clockPorts(ports map[uint64]PortIO.PortIOConfigObject) map[uint64]PortIO.PortIOConfigObject {
+	m := map[uint64]PortIO.PortIOConfigObject{}
+	for port, dev := range ports {
+		m[port] = dev
+	}
+	clock := m[PortIO.LegacyClockDataPort]
+	clock.Name = "LegacyClockData"
+	clock.HandleInQuad = func(port uint64) (uint64, error) { return cpu.Cycles, nil }
+	m[PortIO.LegacyClockDataPort] = clock
+	return m
+}
+
+// Run steps until the CPU halts, an instruction fails, or maxSteps instructions have run
+func (cpu *CPUContainer) Run(maxSteps int) error {
+	for i := 0; i < maxSteps; i++ {
+		if cpu.Halted {
+			return nil
+		}
+		err := cpu.Step()
+		if err != nil {
+			return err
+		}
+	}
+	if !cpu.Halted {
+		return errors.New("CPU did not halt")
+	}
+	return nil
+}
+
+// Supervisor reports whether the CPU is in supervisor mode
+func (cpu *CPUContainer) Supervisor() bool {
+	return cpu.Status&CPU_STATUS_SUPERVISOR != 0
+}
+
+func (cpu *CPUContainer) accessMode() int {
+	if cpu.Supervisor() {
+		return PhysicalMemory.AccessMode_System
+	}
+	return PhysicalMemory.AccessMode_User
+}
+
+// ReadMemory reads a little-endian value of width bits in the current mode. Errors are
+// returned as CPUTrap faults.
+func (cpu *CPUContainer) ReadMemory(addr uint64, width int) (uint64, error) {
+	err := cpu.checkAlignment(addr, width)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for i := 0; i < width/8; i++ {
+		b, err := cpu.Memory.ReadAddressMode(addr+uint64(i), cpu.accessMode())
+		if err != nil {
+			return 0, memoryTrap(addr+uint64(i), err)
+		}
+		v |= uint64(b) << (8 * i)
+	}
+	return v, nil
+}
+
+// WriteMemory writes the low width bits of v little-endian in the current mode
+func (cpu *CPUContainer) WriteMemory(addr uint64, width int, v uint64) error {
+	err := cpu.checkAlignment(addr, width)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < width/8; i++ {
+		err := cpu.Memory.WriteAddressMode(addr+uint64(i), byte(v>>(8*i)), cpu.accessMode())
+		if err != nil {
+			return memoryTrap(addr+uint64(i), err)
+		}
+	}
+	return nil
+}
+
+func (cpu *CPUContainer) push(v uint64) error {
+	sp := cpu.Registers[CPU_REG_SP] - 8
+	err := cpu.WriteMemory(sp, Onyx1ALU.ALU_WIDTH_64, v)
+	if err != nil {
+		return err
+	}
+	cpu.Registers[CPU_REG_SP] = sp
+	return nil
+}
+
+func (cpu *CPUContainer) pop() (uint64, error) {
+	v, err := cpu.ReadMemory(cpu.Registers[CPU_REG_SP], Onyx1ALU.ALU_WIDTH_64)
+	if err != nil {
+		return 0, err
+	}
+	cpu.Registers[CPU_REG_SP] += 8
+	return v, nil
+}
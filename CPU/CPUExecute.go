@@ -0,0 +1,223 @@
+package Onyx1CPU
+
+import (
+	Onyx1ALU "GolangCPUParts/ALU"
+	Onyx1ISA "GolangCPUParts/ISA"
+	"errors"
+	"math"
+)
+
+// execute runs a decoded instruction. The privileged ones fault in user mode.
+func (cpu *CPUContainer) execute(inst Onyx1ISA.Instruction) error {
+	if Onyx1ISA.ISAOpcodes[inst.Opcode].Privileged && !cpu.Supervisor() {
+		return newTrap(CPU_VECTOR_PROTECTION, inst.Address, "Privileged instruction")
+	}
+	if inst.Opcode == Onyx1ISA.ISA_OP_ALU {
+		return cpu.executeALU(inst)
+	}
+	cpu.Cost.Advance(1)
+	switch inst.Opcode {
+	case Onyx1ISA.ISA_OP_NOP:
+	case Onyx1ISA.ISA_OP_HALT:
+		cpu.Halted = true
+	case Onyx1ISA.ISA_OP_MOV:
+		cpu.Registers[inst.Rd] = cpu.operandValue(inst)
+	case Onyx1ISA.ISA_OP_LOAD:
+		v, err := cpu.ReadMemory(cpu.effectiveAddress(inst), inst.Width)
+		if err != nil {
+			return err
+		}
+		cpu.Registers[inst.Rd] = v
+	case Onyx1ISA.ISA_OP_STORE:
+		return cpu.WriteMemory(cpu.effectiveAddress(inst), inst.Width, cpu.Registers[inst.Rd])
+	case Onyx1ISA.ISA_OP_LEA:
+		cpu.Registers[inst.Rd] = cpu.effectiveAddress(inst)
+	case Onyx1ISA.ISA_OP_JMP:
+		if !Onyx1ALU.ALUCondition(cpu.Status, inst.Func) {
+			return nil
+		}
+		target, err := cpu.target(inst)
+		if err != nil {
+			return err
+		}
+		cpu.PC = target
+	case Onyx1ISA.ISA_OP_CALL:
+		target, err := cpu.target(inst)
+		if err != nil {
+			return err
+		}
+		err = cpu.push(cpu.PC)
+		if err != nil {
+			return err
+		}
+		cpu.PC = target
+	case Onyx1ISA.ISA_OP_RET:
+		target, err := cpu.pop()
+		if err != nil {
+			return err
+		}
+		cpu.PC = target
+	case Onyx1ISA.ISA_OP_PUSH:
+		return cpu.push(cpu.Registers[inst.Rd])
+	case Onyx1ISA.ISA_OP_POP:
+		v, err := cpu.pop()
+		if err != nil {
+			return err
+		}
+		cpu.Registers[inst.Rd] = v
+	case Onyx1ISA.ISA_OP_IN:
+		v, err := cpu.portIn(cpu.operandValue(inst), inst.Width)
+		if err != nil {
+			return err
+		}
+		cpu.Registers[inst.Rd] = v
+	case Onyx1ISA.ISA_OP_OUT:
+		return cpu.portOut(cpu.operandValue(inst), inst.Width, cpu.Registers[inst.Rd])
+	case Onyx1ISA.ISA_OP_GETSR:
+		cpu.Registers[inst.Rd] = cpu.Status
+	case Onyx1ISA.ISA_OP_SETSR:
+		// Clearing CPU_STATUS_SUPERVISOR drops to user mode on the current stack, and the
+		// next trap still goes to KernelSP
+		cpu.Status = cpu.operandValue(inst)
+	case Onyx1ISA.ISA_OP_BRK:
+		return newTrap(CPU_VECTOR_BREAKPOINT, inst.Address, "Breakpoint")
+	case Onyx1ISA.ISA_OP_SYSCALL:
+		// The handler finds the operand in the frame's ADDRESS slot
+		return newTrap(CPU_VECTOR_SYSCALL, cpu.operandValue(inst), "System call")
+	case Onyx1ISA.ISA_OP_RTE:
+		return cpu.returnFromTrap()
+	default:
+		return newTrap(CPU_VECTOR_ILLEGAL, inst.Address, "Illegal instruction")
+	}
+	return nil
+}
+
+// effectiveAddress is the address of an indirect or indexed operand
+func (cpu *CPUContainer) effectiveAddress(inst Onyx1ISA.Instruction) uint64 {
+	op := inst.Operand
+	addr := cpu.Registers[op.Reg]
+	if op.Mode == Onyx1ISA.ISA_MODE_INDEXED {
+		addr += uint64(int64(op.Disp))
+		if op.Index != Onyx1ISA.ISA_NO_INDEX {
+			addr += cpu.Registers[op.Index] << uint(op.Scale)
+		}
+	}
+	return addr
+}
+
+// operandValue is the value of a register or immediate operand
+func (cpu *CPUContainer) operandValue(inst Onyx1ISA.Instruction) uint64 {
+	if inst.Operand.Mode == Onyx1ISA.ISA_MODE_IMMEDIATE {
+		return inst.Operand.Immediate
+	}
+	return cpu.Registers[inst.Operand.Reg]
+}
+
+// operand is the value of any operand, memory operands being read at width
+func (cpu *CPUContainer) operand(inst Onyx1ISA.Instruction, width int) (uint64, error) {
+	if inst.Operand.IsMemory() {
+		return cpu.ReadMemory(cpu.effectiveAddress(inst), width)
+	}
+	return cpu.operandValue(inst), nil
+}
+
+// target is the destination of a jump or call. A memory operand holds the address, so
+// JMP [R1+R2*8] goes through a jump table.
+func (cpu *CPUContainer) target(inst Onyx1ISA.Instruction) (uint64, error) {
+	return cpu.operand(inst, Onyx1ALU.ALU_WIDTH_64)
+}
+
+// executeALU runs Rd = op(Ra, operand). Integer ops go through ALUIntWidthWithFlags at
+// the instruction's width and float64 ops through ALUFloat64WithControl, everything else,
+// the three operand ops included, through the registry's Execute. Narrow integer results
+// are sign extended. A second result goes to the register after Rd, and the three operand
+// ops take their third source from Rd.
+func (cpu *CPUContainer) executeALU(inst Onyx1ISA.Instruction) error {
+	info, ok := Onyx1ALU.ALULookupOp(inst.Func)
+	if !ok || !Onyx1ALU.ALUOpAvailable(inst.Func, cpu.Descriptor) {
+		return newTrap(CPU_VECTOR_ILLEGAL, inst.Address, "Illegal ALU op")
+	}
+	cpu.Cost.Charge(inst.Func)
+	parmA := cpu.Registers[inst.Ra]
+	var parmB uint64
+	if info.Arity > 1 {
+		v, err := cpu.operand(inst, inst.Width)
+		if err != nil {
+			return err
+		}
+		parmB = v
+	}
+	flagsIn := cpu.Status & CPU_STATUS_FLAGS
+	control := cpu.FPControl
+	if info.Kind == Onyx1ALU.ALU_KIND_FIXED {
+		control = cpu.FixedControl
+	}
+	var outA, outB, flags uint64
+	switch {
+	case info.Arity == 3:
+		outA, outB, flags = info.Execute(inst.Width, parmA, parmB, cpu.Registers[inst.Rd], flagsIn, control)
+	case info.Kind == Onyx1ALU.ALU_KIND_INT:
+		a, b, f := Onyx1ALU.ALUIntWidthWithFlags(inst.Func, inst.Width, int64(parmA), int64(parmB), flagsIn)
+		outA, outB, flags = uint64(a), uint64(b), f
+	case info.Kind == Onyx1ALU.ALU_KIND_FLOAT64:
+		a, b, f := Onyx1ALU.ALUFloat64WithControl(inst.Func, math.Float64frombits(parmA), math.Float64frombits(parmB), control)
+		outA, outB, flags = math.Float64bits(a), math.Float64bits(b), f
+	default:
+		outA, outB, flags = info.Execute(inst.Width, parmA, parmB, 0, flagsIn, control)
+	}
+	cpu.Status = cpu.Status&^CPU_STATUS_FLAGS | flags&CPU_STATUS_FLAGS
+	if flags&Onyx1ALU.ALU_FLAGS_ERROR != 0 {
+		if flags&Onyx1ALU.ALU_FLAGS_DIVIDEBYZERO != 0 {
+			return newTrap(CPU_VECTOR_DIVIDE, inst.Address, "Divide by zero")
+		}
+		return newTrap(CPU_VECTOR_ILLEGAL, inst.Address, "Illegal ALU op")
+	}
+	if info.Results > 0 {
+		cpu.Registers[inst.Rd] = outA
+	}
+	if info.Results > 1 {
+		cpu.Registers[(inst.Rd+1)%CPU_NUM_REGISTERS] = outB
+	}
+	return nil
+}
+
+// portIn reads a device port through the handler for the access width
+func (cpu *CPUContainer) portIn(port uint64, width int) (uint64, error) {
+	dev, ok := cpu.Ports[port]
+	if !ok {
+		return 0, errors.New("No device on port")
+	}
+	switch {
+	case width == Onyx1ALU.ALU_WIDTH_8 && dev.HandleInByte != nil:
+		v, err := dev.HandleInByte(port)
+		return uint64(v), err
+	case width == Onyx1ALU.ALU_WIDTH_16 && dev.HandleInWOrd != nil:
+		v, err := dev.HandleInWOrd(port)
+		return uint64(v), err
+	case width == Onyx1ALU.ALU_WIDTH_32 && dev.HandleInDouble != nil:
+		v, err := dev.HandleInDouble(port)
+		return uint64(v), err
+	case width == Onyx1ALU.ALU_WIDTH_64 && dev.HandleInQuad != nil:
+		return dev.HandleInQuad(port)
+	}
+	return 0, errors.New("Device " + dev.Name + " can't be read at this width")
+}
+
+// portOut writes the low width bits of v to a device port
+func (cpu *CPUContainer) portOut(port uint64, width int, v uint64) error {
+	dev, ok := cpu.Ports[port]
+	if !ok {
+		return errors.New("No device on port")
+	}
+	switch {
+	case width == Onyx1ALU.ALU_WIDTH_8 && dev.HandleOutByte != nil:
+		return dev.HandleOutByte(port, byte(v))
+	case width == Onyx1ALU.ALU_WIDTH_16 && dev.HandleOutWOrd != nil:
+		return dev.HandleOutWOrd(port, uint16(v))
+	case width == Onyx1ALU.ALU_WIDTH_32 && dev.HandleOutDouble != nil:
+		return dev.HandleOutDouble(port, uint32(v))
+	case width == Onyx1ALU.ALU_WIDTH_64 && dev.HandleOutQuad != nil:
+		return dev.HandleOutQuad(port, v)
+	}
+	return errors.New("Device " + dev.Name + " can't be written at this width")
+}
@@ -0,0 +1,174 @@
+package Onyx1CPU
+
+import (
+	Onyx1ALU "GolangCPUParts/ALU"
+	"GolangCPUParts/IOSupport"
+	"GolangCPUParts/MemoryPackage/PhysicalMemory"
+	"errors"
+)
+
+// CPUDescriptor parameter giving the address of the vector table, which belongs in
+// Kernel-RAM. Without it traps are returned from Step as errors.
+const CPU_PARAM_VECTORS = "cpu.vectors"
+
+// CPUDescriptor parameter giving the top of the kernel stack, which traps from user mode
+// switch to. RTE back to user mode keeps KernelSP where the frame was, and SETSR leaves it
+// alone, so the kernel stack is never the one user code runs on.
+const CPU_PARAM_KERNEL_STACK = "cpu.kernelstack"
+
+// Trap vectors. Each is an 8 byte handler address in the table at VectorBase, and a zero
+// entry means no handler. Vector 0 is unused so a zero cause never looks like a trap.
+const (
+	CPU_VECTOR_ILLEGAL    = 1
+	CPU_VECTOR_DIVIDE     = 2
+	CPU_VECTOR_PAGEFAULT  = 3
+	CPU_VECTOR_PROTECTION = 4
+	CPU_VECTOR_ALIGNMENT  = 5
+	CPU_VECTOR_BREAKPOINT = 6
+	CPU_VECTOR_SYSCALL    = 7
+	// Interrupt line n is vector CPU_VECTOR_IRQ + n
+	CPU_VECTOR_IRQ  = 16
+	CPU_NUM_VECTORS = CPU_VECTOR_IRQ + IOSupport.IRQ_NUM_LINES
+)
+
+var CPUVectorNames = []string{
+	"",
+	"ILLEGAL",
+	"DIVIDE",
+	"PAGEFAULT",
+	"PROTECTION",
+	"ALIGNMENT",
+	"BREAKPOINT",
+	"SYSCALL",
+}
+
+// A trap pushes this frame on the supervisor stack, the offsets being from SP in the
+// handler. ADDRESS is the faulting address for memory faults, the operand of SYSCALL and
+// the line number for interrupts.
+// PC is the instruction that faulted, or the one after BRK and SYSCALL. SP is the stack
+// pointer before the trap, the user stack when the trap came from user mode.
+const (
+	CPU_FRAME_CAUSE   = 0
+	CPU_FRAME_ADDRESS = 8
+	CPU_FRAME_PC      = 16
+	CPU_FRAME_STATUS  = 24
+	CPU_FRAME_SP      = 32
+	CPU_FRAME_LENGTH  = 40
+)
+
+// CPUTrap is an exception raised by an instruction. Step delivers it through the vector
+// table, or returns it when there is no handler.
+type CPUTrap struct {
+	Vector  int
+	Address uint64
+	Err     error
+}
+
+func (t *CPUTrap) Error() string {
+	return t.Err.Error()
+}
+
+func (t *CPUTrap) Unwrap() error {
+	return t.Err
+}
+
+func newTrap(vector int, addr uint64, msg string) error {
+	return &CPUTrap{Vector: vector, Address: addr, Err: errors.New(msg)}
+}
+
+// memoryTrap classifies an error from the memory system at addr. Protection faults are
+// reported as such, and anything else, an unmapped address or a page that can't be
+// brought in, is a page fault.
+func memoryTrap(addr uint64, err error) error {
+	if errors.Is(err, PhysicalMemory.ErrProtectionFault) {
+		return &CPUTrap{Vector: CPU_VECTOR_PROTECTION, Address: addr, Err: err}
+	}
+	return &CPUTrap{Vector: CPU_VECTOR_PAGEFAULT, Address: addr, Err: err}
+}
+
+// checkAlignment faults an access that isn't aligned to its width while
+// CPU_STATUS_ALIGN is set
+func (cpu *CPUContainer) checkAlignment(addr uint64, width int) error {
+	if cpu.Status&CPU_STATUS_ALIGN != 0 && width > Onyx1ALU.ALU_WIDTH_8 && addr%uint64(width/8) != 0 {
+		return newTrap(CPU_VECTOR_ALIGNMENT, addr, "Misaligned access")
+	}
+	return nil
+}
+
+// takeTrap enters the handler for trap in supervisor mode, switching to the kernel stack
+// when the trap came from user mode and pushing the frame there. pc is the PC to save. A
+// trap with no handler is returned as is, and one that can't be delivered because the
+// vector or the stack can't be reached is a double fault.
+func (cpu *CPUContainer) takeTrap(trap *CPUTrap, pc uint64) error {
+	if cpu.VectorBase == 0 {
+		return trap
+	}
+	status, sp := cpu.Status, cpu.Registers[CPU_REG_SP]
+	cpu.Status = CPU_STATUS_SUPERVISOR
+	handler, err := cpu.ReadMemory(cpu.VectorBase+uint64(trap.Vector)*8, Onyx1ALU.ALU_WIDTH_64)
+	if err == nil && handler == 0 {
+		cpu.Status = status
+		return trap
+	}
+	if status&CPU_STATUS_SUPERVISOR == 0 {
+		cpu.Registers[CPU_REG_SP] = cpu.KernelSP
+	}
+	for _, v := range []uint64{sp, status, pc, trap.Address, uint64(trap.Vector)} {
+		if err != nil {
+			break
+		}
+		err = cpu.push(v)
+	}
+	if err != nil {
+		cpu.Status, cpu.Registers[CPU_REG_SP] = status, sp
+		return errors.New("Double fault: " + err.Error() + " taking " + trap.Error())
+	}
+	cpu.PC = handler
+	return nil
+}
+
+// interrupt takes the controller's next interrupt when CPU_STATUS_INTERRUPTS is set and
+// there is a vector table. Entering the handler clears CPU_STATUS_INTERRUPTS until RTE, and
+// the line stays pending if the handler can't be entered. A line with no handler is masked
+// and its trap returned, so it is reported once rather than by every Step.
+func (cpu *CPUContainer) interrupt() error {
+	if cpu.Interrupts == nil || cpu.VectorBase == 0 || cpu.Status&CPU_STATUS_INTERRUPTS == 0 {
+		return nil
+	}
+	line, ok := cpu.Interrupts.Next()
+	if !ok {
+		return nil
+	}
+	trap := CPUTrap{Vector: CPU_VECTOR_IRQ + line, Address: uint64(line), Err: errors.New("Interrupt")}
+	err := cpu.takeTrap(&trap, cpu.PC)
+	if err == &trap {
+		cpu.Interrupts.MaskLine(line)
+	}
+	if err != nil {
+		return err
+	}
+	cpu.Interrupts.Take(line)
+	return nil
+}
+
+// returnFromTrap pops a trap frame, restoring the PC, status and stack pointer. Returning
+// to user mode saves the kernel stack pointer for the next trap.
+func (cpu *CPUContainer) returnFromTrap() error {
+	sp := cpu.Registers[CPU_REG_SP]
+	var frame [CPU_FRAME_LENGTH / 8]uint64
+	for i := range frame {
+		v, err := cpu.ReadMemory(sp+uint64(i)*8, Onyx1ALU.ALU_WIDTH_64)
+		if err != nil {
+			return err
+		}
+		frame[i] = v
+	}
+	status := frame[CPU_FRAME_STATUS/8]
+	if status&CPU_STATUS_SUPERVISOR == 0 {
+		cpu.KernelSP = sp + CPU_FRAME_LENGTH
+	}
+	cpu.PC = frame[CPU_FRAME_PC/8]
+	cpu.Status = status
+	cpu.Registers[CPU_REG_SP] = frame[CPU_FRAME_SP/8]
+	return nil
+}
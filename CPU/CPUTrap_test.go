@@ -0,0 +1,324 @@
+package Onyx1CPU
+
+import (
+	Onyx1Assembler "GolangCPUParts/Assembler"
+	"GolangCPUParts/Configuration"
+	"GolangCPUParts/IOSupport"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// The kernel keeps its vector table and handlers in Kernel-RAM, drops to user mode
+// through RTE and counts what the user program does. Each fault handler steps the saved
+// PC over the 4 byte instruction that faulted.
+const trapProgram = `
+		.org 0x10000
+vectors: .quad 0, illegal, divide, pagefault, protection, alignment, brk, syscall
+
+		.org 0x10100
+reset:	MOV SP, #0x11000
+		MOV R1, #0x8000
+		PUSH R1				; SP
+		MOV R1, #0x20000
+		PUSH R1				; Status, user mode with alignment checks
+		MOV R1, #user
+		PUSH R1				; PC
+		MOV R1, #0
+		PUSH R1				; address
+		PUSH R1				; cause
+		RTE
+
+syscall: LOAD R1, [SP + 8]
+		ADD R10, R10, R1
+rte:	RTE
+brk:	ADD R11, R11, #1
+		RTE
+divide:	MOV R12, #1
+		CALL skip
+		RTE
+protection: LOAD R1, [SP + 8]
+		ADD R13, R13, R1
+		CALL skip
+		RTE
+alignment: LOAD R8, [SP + 8]
+		CALL skip
+		RTE
+pagefault: LOAD R6, [SP + 8]
+		CALL skip
+		RTE
+illegal: LOAD R14, [SP]
+		HALT
+; the frame is 8 bytes further up for the return address
+skip:	LOAD R1, [SP + 24]
+		ADD R1, R1, #4
+		STORE R1, [SP + 24]
+		RET
+
+		.org 0x1000
+user:	SYSCALL #5
+		SYSCALL #7
+brkpt:	BRK
+		MOV R2, #0
+		DIV R3, R3, R2
+		MOV R4, #0x10008
+		LOAD R5, [R4]
+priv:	HALT
+		MOV R4, #0x2001
+		LOAD.W R5, [R4]
+		MOV R4, #0x100000
+		LOAD R5, [R4]
+		PUSH R4
+bad:	.byte 0xFC, 0, 0, 0
+`
+
+func newTrapCPU(t *testing.T, src string) (*CPUContainer, *Onyx1Assembler.Program) {
+	pmc := protectedMemory(t)
+	prog, err := Onyx1Assembler.Assembler_Initialize().Assemble("trap.s", src)
+	if err != nil {
+		t.Fatalf("CPU Expected the program to assemble, got %s", err)
+	}
+	err = prog.LoadInto(pmc)
+	if err != nil {
+		t.Fatalf("CPU Expected the program to load, got %s", err)
+	}
+	desc := Configuration.CPUDescriptor{Parameters: map[string]string{
+		CPU_PARAM_VECTORS:      "0x10000",
+		CPU_PARAM_KERNEL_STACK: "0x11000",
+	}}
+	cpu, err := CPU_Initialize(desc, pmc)
+	if err != nil {
+		t.Fatalf("CPU Expected to initialize, got %s", err)
+	}
+	cpu.Reset(prog.Symbols["reset"])
+	return cpu, prog
+}
+
+func TestCPUTraps(t *testing.T) {
+	cpu, prog := newTrapCPU(t, trapProgram)
+	err := cpu.Run(200)
+	if err != nil {
+		t.Fatalf("CPU Expected the traps to be handled, got %s", err)
+	}
+	tests := []struct {
+		name string
+		reg  int
+		want uint64
+	}{
+		{"SYSCALL", 10, 12},
+		{"BRK", 11, 1},
+		{"DIV", 12, 1},
+		{"Protection", 13, 0x10008 + prog.Symbols["priv"]},
+		{"Alignment", 8, 0x2001},
+		{"Page fault", 6, 0x100000},
+		{"Illegal", 14, CPU_VECTOR_ILLEGAL},
+	}
+	for _, tt := range tests {
+		if cpu.Registers[tt.reg] != tt.want {
+			t.Errorf("%s Expected R%d = %X, got %X", tt.name, tt.reg, tt.want, cpu.Registers[tt.reg])
+		}
+	}
+	// The illegal instruction trapped from user mode onto the kernel stack
+	sp := uint64(0x11000 - CPU_FRAME_LENGTH)
+	if !cpu.Supervisor() || cpu.Registers[CPU_REG_SP] != sp || cpu.KernelSP != 0x11000 {
+		t.Errorf("CPU Expected to be on the kernel stack in supervisor mode, got SP %X", cpu.Registers[CPU_REG_SP])
+	}
+	frame := map[int]uint64{
+		CPU_FRAME_PC:     prog.Symbols["bad"],
+		CPU_FRAME_STATUS: CPU_STATUS_ALIGN,
+		CPU_FRAME_SP:     0x8000 - 8,
+	}
+	for off, want := range frame {
+		v, _ := cpu.ReadMemory(sp+uint64(off), 64)
+		if off == CPU_FRAME_STATUS {
+			v &^= CPU_STATUS_FLAGS
+		}
+		if v != want {
+			t.Errorf("CPU Expected %X at frame offset %d, got %X", want, off, v)
+		}
+	}
+}
+
+func TestCPUTrapErrors(t *testing.T) {
+	// With no handler for the vector, the trap comes back from Step
+	cpu, _ := newTrapCPU(t, ".org 0x10000\n.quad 0, 0, 0\n.org 0x10100\nreset: BRK\nMOV R2, #0\nDIV R3, R3, R2")
+	err := cpu.Step()
+	var trap *CPUTrap
+	if !errors.As(err, &trap) || trap.Vector != CPU_VECTOR_BREAKPOINT || cpu.PC != 0x10100 {
+		t.Errorf("CPU Expected a breakpoint with no handler, got %v at %X", err, cpu.PC)
+	}
+	cpu.PC = 0x10104
+	cpu.Step()
+	err = cpu.Step()
+	if !errors.As(err, &trap) || trap.Vector != CPU_VECTOR_DIVIDE || err.Error() != "Divide by zero" {
+		t.Errorf("CPU Expected a divide trap with no handler, got %v", err)
+	}
+	// A trap from user mode with no kernel stack can't be delivered
+	cpu, prog := newTrapCPU(t, trapProgram)
+	cpu.Status = 0
+	cpu.PC = prog.Symbols["brkpt"]
+	cpu.KernelSP = 0x100000
+	err = cpu.Step()
+	if err == nil || !strings.HasPrefix(err.Error(), "Double fault") || cpu.Supervisor() {
+		t.Errorf("CPU Expected a double fault, got %v", err)
+	}
+	// RTE is privileged, and user code can't even fetch it from Kernel-RAM
+	cpu.KernelSP = 0x11000
+	cpu.Status = 0
+	cpu.PC = prog.Symbols["rte"]
+	err = cpu.Step()
+	if err != nil || !cpu.Supervisor() || cpu.PC != prog.Symbols["protection"] {
+		t.Errorf("CPU Expected RTE to fault in user mode, got %v", err)
+	}
+	cpu.Registers[CPU_REG_SP] = 0x11000
+	cpu.Status = CPU_STATUS_SUPERVISOR
+	cpu.VectorBase = 0
+	rte, _ := cpu.ReadMemory(prog.Symbols["rte"], 32)
+	cpu.WriteMemory(0x100, 32, rte)
+	cpu.Status = 0
+	cpu.PC = 0x100
+	err = cpu.Step()
+	if !errors.As(err, &trap) || trap.Vector != CPU_VECTOR_PROTECTION || err.Error() != "Privileged instruction" {
+		t.Errorf("CPU Expected RTE to fault in user mode, got %v", err)
+	}
+	_, err = CPU_Initialize(Configuration.CPUDescriptor{Parameters: map[string]string{CPU_PARAM_VECTORS: "kernel"}}, cpu.Memory)
+	if err == nil {
+		t.Errorf("CPU Expected a bad vector table address to fail")
+	}
+}
+
+func TestCPUSetSRToUser(t *testing.T) {
+	// SETSR drops to user mode on the user's stack, and the SYSCALL still traps onto the
+	// kernel stack without touching what the user pushed
+	cpu, prog := newTrapCPU(t, trapProgram+`
+		.org 0x2000
+direct:	MOV SP, #0x8000
+		SETSR #0
+		MOV R1, #0x1234
+		PUSH R1
+		SYSCALL #9
+		POP R2
+		HALT
+`)
+	cpu.Reset(prog.Symbols["direct"])
+	if cpu.KernelSP != 0x11000 {
+		t.Fatalf("CPU Expected Reset to put KernelSP at the kernel stack, got %X", cpu.KernelSP)
+	}
+	for i := 0; i < 5; i++ {
+		err := cpu.Step()
+		if err != nil {
+			t.Fatalf("CPU Expected step %d to run, got %s", i, err)
+		}
+	}
+	if !cpu.Supervisor() || cpu.PC != prog.Symbols["syscall"] || cpu.Registers[CPU_REG_SP] != 0x11000-CPU_FRAME_LENGTH {
+		t.Fatalf("CPU Expected the SYSCALL on the kernel stack, got SP %X PC %X", cpu.Registers[CPU_REG_SP], cpu.PC)
+	}
+	for i := 0; i < 4; i++ {
+		cpu.Step()
+	}
+	if cpu.Registers[10] != 9 || cpu.Registers[2] != 0x1234 || cpu.Supervisor() {
+		t.Errorf("CPU Expected the user's data to survive the SYSCALL, got R10 %d R2 %X", cpu.Registers[10], cpu.Registers[2])
+	}
+	if cpu.Registers[CPU_REG_SP] != 0x8000 || cpu.KernelSP != 0x11000 {
+		t.Errorf("CPU Expected both stacks back at their tops, got SP %X KernelSP %X", cpu.Registers[CPU_REG_SP], cpu.KernelSP)
+	}
+	_, err := CPU_Initialize(Configuration.CPUDescriptor{Parameters: map[string]string{CPU_PARAM_KERNEL_STACK: "top"}}, cpu.Memory)
+	if err == nil {
+		t.Errorf("CPU Expected a bad kernel stack address to fail")
+	}
+}
+
+// The kernel starts the legacy clock, puts the console ahead of the timer and drops to
+// user mode with interrupts on. Each handler notes the order it ran in.
+const interruptProgram = `
+		.org 0x10000 + 16*8
+		.quad timer, console
+
+		.org 0x10100
+reset:	MOV SP, #0x11000
+		MOV R1, #3
+		OUT R1, #0x1000000000010002		; LegacyClockInterruptPort
+		MOV R1, #5
+		OUT.B R1, #0x1000000000020101	; InterruptPriorityPort + IRQ_CONSOLE
+		MOV R1, #0x8000
+		PUSH R1
+		MOV R1, #0x40000
+		PUSH R1
+		MOV R1, #user
+		PUSH R1
+		MOV R1, #0
+		PUSH R1
+		PUSH R1
+		RTE
+
+timer:	MOV R14, R10
+		MOV R1, #0
+		JMP done
+console: MOV R13, R10
+		MOV R1, #1
+done:	ADD R10, R10, #1
+		OUT R1, #0x1000000000020002		; InterruptAckPort
+		RTE
+
+		.org 0x1000
+user:	ADD R2, R2, #1
+		JMP user
+`
+
+func TestCPUInterrupts(t *testing.T) {
+	IOSupport.Timer_Initialize()
+	defer IOSupport.Timer_Terminate()
+	cpu, prog := newTrapCPU(t, interruptProgram)
+	ic := IOSupport.InterruptController_Initialize()
+	cpu.AttachInterrupts(ic)
+	ic.Raise(IOSupport.IRQ_CONSOLE)
+	for i := 0; i < 40; i++ {
+		err := cpu.Step()
+		if err != nil {
+			t.Fatalf("CPU Expected step %d to run, got %s", i, err)
+		}
+		// The console is taken as soon as the kernel drops to user mode with interrupts on
+		if cpu.PC == prog.Symbols["console"] {
+			pc, _ := cpu.ReadMemory(cpu.Registers[CPU_REG_SP]+CPU_FRAME_PC, 64)
+			if pc != prog.Symbols["user"] || cpu.Registers[2] != 0 {
+				t.Errorf("CPU Expected the console interrupt before the first user instruction, got %X", pc)
+			}
+		}
+	}
+	if cpu.Registers[10] != 2 || cpu.Registers[13] != 0 || cpu.Registers[14] != 1 {
+		t.Errorf("CPU Expected the console then the timer interrupt, got %d %d %d", cpu.Registers[10], cpu.Registers[13], cpu.Registers[14])
+	}
+	if ic.Pending != 0 || ic.InService != 0 || cpu.Supervisor() || cpu.Registers[2] == 0 {
+		t.Errorf("CPU Expected both interrupts handled and back in user code, got %X %X", ic.Pending, ic.InService)
+	}
+	if cpu.Status&CPU_STATUS_INTERRUPTS == 0 || cpu.Registers[CPU_REG_SP] != 0x8000 {
+		t.Errorf("CPU Expected RTE to restore interrupts and the user stack, got %X", cpu.Status)
+	}
+}
+
+func TestCPUInterruptNoHandler(t *testing.T) {
+	// Line 3 has no vector, so it is reported once and masked, and the CPU carries on
+	cpu, prog := newTrapCPU(t, interruptProgram)
+	ic := IOSupport.InterruptController_Initialize()
+	cpu.AttachInterrupts(ic)
+	cpu.Status = CPU_STATUS_SUPERVISOR | CPU_STATUS_INTERRUPTS
+	cpu.PC = prog.Symbols["user"]
+	ic.Raise(3)
+	err := cpu.Step()
+	var trap *CPUTrap
+	if !errors.As(err, &trap) || trap.Vector != CPU_VECTOR_IRQ+3 || cpu.PC != prog.Symbols["user"] {
+		t.Fatalf("CPU Expected the interrupt with no handler to be returned, got %v", err)
+	}
+	if ic.Mask != 1<<3 || ic.Pending != 1<<3 || ic.InService != 0 {
+		t.Errorf("CPU Expected the line masked and still pending, got mask %X pending %X", ic.Mask, ic.Pending)
+	}
+	for i := 0; i < 4; i++ {
+		err = cpu.Step()
+		if err != nil {
+			t.Fatalf("CPU Expected step %d to run, got %s", i, err)
+		}
+	}
+	if cpu.Registers[2] != 2 {
+		t.Errorf("CPU Expected the program to run on, got R2 %d", cpu.Registers[2])
+	}
+}
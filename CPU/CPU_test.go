@@ -0,0 +1,435 @@
+package Onyx1CPU
+
+import (
+	Onyx1ALU "GolangCPUParts/ALU"
+	"GolangCPUParts/Configuration"
+	"GolangCPUParts/IOSupport/PortIO"
+	Onyx1ISA "GolangCPUParts/ISA"
+	"GolangCPUParts/MemoryPackage/PhysicalMemory"
+	"GolangCPUParts/MemoryPackage/VirtualMemory"
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func reg(r int) Onyx1ISA.Operand {
+	return Onyx1ISA.Operand{Mode: Onyx1ISA.ISA_MODE_REGISTER, Reg: r}
+}
+
+func imm(v uint64) Onyx1ISA.Operand {
+	return Onyx1ISA.Operand{Mode: Onyx1ISA.ISA_MODE_IMMEDIATE, Immediate: v}
+}
+
+func ind(r int) Onyx1ISA.Operand {
+	return Onyx1ISA.Operand{Mode: Onyx1ISA.ISA_MODE_INDIRECT, Reg: r}
+}
+
+func idx(r int, i int, scale int, disp int32) Onyx1ISA.Operand {
+	return Onyx1ISA.Operand{Mode: Onyx1ISA.ISA_MODE_INDEXED, Reg: r, Index: i, Scale: scale, Disp: disp}
+}
+
+func inst(opcode int, size int, fn int, rd int, ra int, op Onyx1ISA.Operand) Onyx1ISA.Instruction {
+	return Onyx1ISA.Instruction{Opcode: opcode, Size: size, Func: fn, Rd: rd, Ra: ra, Operand: op}
+}
+
+func movi(rd int, v uint64) Onyx1ISA.Instruction {
+	return inst(Onyx1ISA.ISA_OP_MOV, Onyx1ISA.ISA_SIZE_64, 0, rd, 0, imm(v))
+}
+
+func alu(size int, fn int, rd int, ra int, op Onyx1ISA.Operand) Onyx1ISA.Instruction {
+	return inst(Onyx1ISA.ISA_OP_ALU, size, fn, rd, ra, op)
+}
+
+var halt = inst(Onyx1ISA.ISA_OP_HALT, Onyx1ISA.ISA_SIZE_64, 0, 0, 0, reg(0))
+
+// newTestCPU assembles the program at address 0 of the Kaypro profile's RAM
+func newTestCPU(t *testing.T, desc Configuration.CPUDescriptor, program ...Onyx1ISA.Instruction) *CPUContainer {
+	s, _ := Configuration.MockConfig()
+	cfg, err := Configuration.LoadConfiguration(s)
+	if err != nil {
+		t.Fatalf("CPU Expected the mock configuration to load, got %s", err)
+	}
+	pmc, err := PhysicalMemory.PhysicalMemoryInitialize(cfg, "Kaypro-CPM-64KB")
+	if err != nil {
+		t.Fatalf("CPU Expected physical memory, got %s", err)
+	}
+	addr := uint64(0)
+	for _, in := range program {
+		b, err := Onyx1ISA.Encode(in)
+		if err != nil {
+			t.Fatalf("CPU Expected %+v to encode, got %s", in, err)
+		}
+		for _, v := range b {
+			pmc.WriteAddress(addr, v)
+			addr++
+		}
+	}
+	cpu, err := CPU_Initialize(desc, pmc)
+	if err != nil {
+		t.Fatalf("CPU Expected to initialize, got %s", err)
+	}
+	cpu.Reset(0)
+	cpu.Registers[CPU_REG_SP] = 0x8000
+	return cpu
+}
+
+func TestCPUIntProgram(t *testing.T) {
+	cpu := newTestCPU(t, Configuration.CPUDescriptor{},
+		movi(1, 10),
+		movi(2, 32),
+		alu(Onyx1ISA.ISA_SIZE_64, Onyx1ALU.ALU_OP_ADDINT64, 3, 1, reg(2)),
+		alu(Onyx1ISA.ISA_SIZE_64, Onyx1ALU.ALU_OP_DIVINT64, 4, 3, imm(5)),
+		halt,
+	)
+	err := cpu.Run(100)
+	if err != nil {
+		t.Fatalf("CPU Expected the program to halt, got %s", err)
+	}
+	if cpu.Registers[3] != 42 || cpu.Registers[4] != 8 || cpu.Registers[5] != 2 {
+		t.Errorf("CPU Expected 42 8 2, got %d %d %d", cpu.Registers[3], cpu.Registers[4], cpu.Registers[5])
+	}
+	// Three one cycle instructions, ADD and DIV
+	if cpu.Cycles != 3+1+40 {
+		t.Errorf("CPU Expected 44 cycles, got %d", cpu.Cycles)
+	}
+	if cpu.PC != 12+12+4+12+4 {
+		t.Errorf("CPU Expected to stop after HALT, got PC %d", cpu.PC)
+	}
+}
+
+func TestCPUClock(t *testing.T) {
+	cpu := newTestCPU(t, Configuration.CPUDescriptor{Parameters: map[string]string{"alu.cycles.DIV": "100"}},
+		alu(Onyx1ISA.ISA_SIZE_64, Onyx1ALU.ALU_OP_DIVINT64, 4, 3, imm(5)),
+		inst(Onyx1ISA.ISA_OP_IN, Onyx1ISA.ISA_SIZE_64, 0, 1, 0, imm(PortIO.LegacyClockDataPort)),
+		inst(Onyx1ISA.ISA_OP_IN, Onyx1ISA.ISA_SIZE_64, 0, 2, 0, imm(PortIO.LegacyClockDataPort)),
+		halt,
+	)
+	err := cpu.Run(10)
+	if err != nil {
+		t.Fatalf("CPU Expected the program to halt, got %s", err)
+	}
+	if cpu.Registers[1] != 100 || cpu.Registers[2] != 101 || cpu.Cycles != cpu.Cost.Elapsed() {
+		t.Errorf("CPU Expected the clock to read 100 then 101, got %d %d", cpu.Registers[1], cpu.Registers[2])
+	}
+	cpu.Reset(0)
+	if cpu.Cycles != 0 || cpu.Cost.Elapsed() != 0 {
+		t.Errorf("CPU Expected Reset to stop the clock at 0, got %d", cpu.Cost.Elapsed())
+	}
+}
+
+func TestCPUWidthAndFlags(t *testing.T) {
+	cpu := newTestCPU(t, Configuration.CPUDescriptor{},
+		movi(1, 0x7F),
+		alu(Onyx1ISA.ISA_SIZE_8, Onyx1ALU.ALU_OP_ADDINT64, 2, 1, imm(1)),
+		alu(Onyx1ISA.ISA_SIZE_64, Onyx1ALU.ALU_OP_CMPINT64, 0, 2, imm(0)),
+		halt,
+	)
+	cpu.Step()
+	cpu.Step()
+	if int64(cpu.Registers[2]) != -128 || cpu.Status&Onyx1ALU.ALU_FLAGS_OVERFLOW == 0 {
+		t.Errorf("CPU Expected -128 with OVERFLOW, got %d %b", int64(cpu.Registers[2]), cpu.Status)
+	}
+	cpu.Step()
+	if !Onyx1ALU.ALUCondition(cpu.Status, Onyx1ALU.ALU_COND_LT) || cpu.Registers[0] != 0 {
+		t.Errorf("CPU Expected CMP to only set LT, got %b R0=%d", cpu.Status, cpu.Registers[0])
+	}
+}
+
+func TestCPUFloat(t *testing.T) {
+	cpu := newTestCPU(t, Configuration.CPUDescriptor{},
+		movi(1, math.Float64bits(1.5)),
+		alu(Onyx1ISA.ISA_SIZE_64, Onyx1ALU.ALU_OP_FMULT64, 2, 1, imm(math.Float64bits(2))),
+		alu(Onyx1ISA.ISA_SIZE_64, Onyx1ALU.ALU_OP_FSQRT64, 3, 2, reg(0)),
+		halt,
+	)
+	err := cpu.Run(100)
+	if err != nil {
+		t.Fatalf("CPU Expected the program to halt, got %s", err)
+	}
+	if math.Float64frombits(cpu.Registers[2]) != 3 || math.Float64frombits(cpu.Registers[3]) != math.Sqrt(3) {
+		t.Errorf("CPU Expected 3 and sqrt(3), got %g %g",
+			math.Float64frombits(cpu.Registers[2]), math.Float64frombits(cpu.Registers[3]))
+	}
+
+	noFPU := Configuration.CPUDescriptor{FeatureA: Configuration.FeatureA_NoFPU}
+	cpu = newTestCPU(t, noFPU, alu(Onyx1ISA.ISA_SIZE_64, Onyx1ALU.ALU_OP_FADD64, 1, 1, reg(1)))
+	err = cpu.Step()
+	if err == nil || cpu.PC != 0 {
+		t.Errorf("CPU Expected FADD to fail without an FPU, got PC %d", cpu.PC)
+	}
+}
+
+func TestCPUMemory(t *testing.T) {
+	cpu := newTestCPU(t, Configuration.CPUDescriptor{},
+		movi(1, 0x1000),
+		movi(2, 3),
+		movi(3, 0x1122334455667788),
+		inst(Onyx1ISA.ISA_OP_STORE, Onyx1ISA.ISA_SIZE_64, 0, 3, 0, idx(1, 2, 3, 8)),
+		inst(Onyx1ISA.ISA_OP_LEA, Onyx1ISA.ISA_SIZE_64, 0, 4, 0, idx(1, 2, 3, 8)),
+		inst(Onyx1ISA.ISA_OP_LOAD, Onyx1ISA.ISA_SIZE_16, 0, 5, 0, ind(4)),
+		alu(Onyx1ISA.ISA_SIZE_32, Onyx1ALU.ALU_OP_ADDINT64, 6, 2, ind(4)),
+		halt,
+	)
+	err := cpu.Run(100)
+	if err != nil {
+		t.Fatalf("CPU Expected the program to halt, got %s", err)
+	}
+	if cpu.Registers[4] != 0x1020 || cpu.Registers[5] != 0x7788 || cpu.Registers[6] != 0x5566778B {
+		t.Errorf("CPU Expected 1020 7788 5566778b, got %x %x %x", cpu.Registers[4], cpu.Registers[5], cpu.Registers[6])
+	}
+	v, _ := cpu.ReadMemory(0x1020, Onyx1ALU.ALU_WIDTH_64)
+	if v != 0x1122334455667788 {
+		t.Errorf("CPU Expected the quad stored at 1020, got %x", v)
+	}
+}
+
+func TestCPUBranches(t *testing.T) {
+	// Sum 1..10 in a loop through a subroutine
+	cpu := newTestCPU(t, Configuration.CPUDescriptor{},
+		movi(1, 10), // 0
+		movi(2, 0),  // 12
+		inst(Onyx1ISA.ISA_OP_CALL, Onyx1ISA.ISA_SIZE_64, 0, 0, 0, imm(64)),                   // 24 loop
+		alu(Onyx1ISA.ISA_SIZE_64, Onyx1ALU.ALU_OP_SUBINT64, 1, 1, imm(1)),                    // 36
+		inst(Onyx1ISA.ISA_OP_JMP, Onyx1ISA.ISA_SIZE_64, Onyx1ALU.ALU_COND_NE, 0, 0, imm(24)), // 48
+		halt, // 60
+	)
+	// The subroutine at 64 is PUSH R1, ADD R2 = R2 + R1, POP R3, RET
+	sub := []Onyx1ISA.Instruction{
+		inst(Onyx1ISA.ISA_OP_PUSH, Onyx1ISA.ISA_SIZE_64, 0, 1, 0, reg(0)),
+		alu(Onyx1ISA.ISA_SIZE_64, Onyx1ALU.ALU_OP_ADDINT64, 2, 2, reg(1)),
+		inst(Onyx1ISA.ISA_OP_POP, Onyx1ISA.ISA_SIZE_64, 0, 3, 0, reg(0)),
+		inst(Onyx1ISA.ISA_OP_RET, Onyx1ISA.ISA_SIZE_64, 0, 0, 0, reg(0)),
+	}
+	addr := uint64(64)
+	for _, in := range sub {
+		b, _ := Onyx1ISA.Encode(in)
+		for _, v := range b {
+			cpu.WriteMemory(addr, Onyx1ALU.ALU_WIDTH_8, uint64(v))
+			addr++
+		}
+	}
+	err := cpu.Run(1000)
+	if err != nil {
+		t.Fatalf("CPU Expected the program to halt, got %s", err)
+	}
+	if cpu.Registers[2] != 55 || cpu.Registers[3] != 1 || cpu.Registers[CPU_REG_SP] != 0x8000 {
+		t.Errorf("CPU Expected 55 1 and the stack back at 8000, got %d %d %x",
+			cpu.Registers[2], cpu.Registers[3], cpu.Registers[CPU_REG_SP])
+	}
+}
+
+func TestCPUPorts(t *testing.T) {
+	var written uint16
+	cpu := newTestCPU(t, Configuration.CPUDescriptor{},
+		inst(Onyx1ISA.ISA_OP_IN, Onyx1ISA.ISA_SIZE_64, 0, 1, 0, imm(PortIO.LegacyClockDataPort)),
+		movi(2, 0x10),
+		inst(Onyx1ISA.ISA_OP_OUT, Onyx1ISA.ISA_SIZE_16, 0, 1, 0, reg(2)),
+		inst(Onyx1ISA.ISA_OP_OUT, Onyx1ISA.ISA_SIZE_8, 0, 1, 0, reg(2)),
+	)
+	cpu.Ports = map[uint64]PortIO.PortIOConfigObject{
+		PortIO.LegacyClockDataPort: PortIO.PortIOConfig[PortIO.LegacyClockDataPort],
+		0x10: {
+			Name:          "Test",
+			HandleOutWOrd: func(port uint64, value uint16) error { written = value; return nil },
+		},
+	}
+	cpu.Registers[1] = 0xFFFF
+	cpu.Step()
+	if cpu.Registers[1] != 0 {
+		t.Errorf("CPU Expected IN from the clock data port, got %x", cpu.Registers[1])
+	}
+	cpu.Registers[1] = 0x12345
+	cpu.Step()
+	cpu.Step()
+	if written != 0x2345 {
+		t.Errorf("CPU Expected OUT.W to write 2345, got %x", written)
+	}
+	if cpu.Step() == nil {
+		t.Errorf("CPU Expected OUT.B to fail on a port without a byte handler")
+	}
+}
+
+func TestCPUErrors(t *testing.T) {
+	cpu := newTestCPU(t, Configuration.CPUDescriptor{},
+		movi(1, 1),
+		alu(Onyx1ISA.ISA_SIZE_64, Onyx1ALU.ALU_OP_DIVINT64, 2, 1, reg(0)),
+	)
+	cpu.Step()
+	err := cpu.Step()
+	if err == nil || cpu.PC != 12 || cpu.Status&Onyx1ALU.ALU_FLAGS_DIVIDEBYZERO == 0 {
+		t.Errorf("CPU Expected divide by zero at 12, got %v at %d", err, cpu.PC)
+	}
+
+	cpu = newTestCPU(t, Configuration.CPUDescriptor{})
+	cpu.WriteMemory(0, Onyx1ALU.ALU_WIDTH_32, 0xFC)
+	if cpu.Step() == nil {
+		t.Errorf("CPU Expected an illegal instruction")
+	}
+	// Memory is zero, which is NOP
+	cpu = newTestCPU(t, Configuration.CPUDescriptor{})
+	if cpu.Run(10) == nil || cpu.PC != 40 {
+		t.Errorf("CPU Expected Run to stop after 10 NOPs, got PC %d", cpu.PC)
+	}
+}
+
+// protectedMemory is 64KB of RAM with 64KB of Kernel-RAM after it
+func protectedMemory(t *testing.T) *PhysicalMemory.PhysicalMemoryManager {
+	cfg := &Configuration.ConfigObject{Configuration: []Configuration.SystemConfigs{{
+		Name: "Protected",
+		Description: Configuration.ConfigurationDescriptor{Memory: []Configuration.MemoryDescriptor{
+			{Key: 0, StartAddress: 0x0000, EndAddress: 0xFFFF, MemoryType: "Physical-RAM"},
+			{Key: 1, StartAddress: 0x10000, EndAddress: 0x1FFFF, MemoryType: "Kernel-RAM"},
+		}},
+	}}}
+	pmc, err := PhysicalMemory.PhysicalMemoryInitialize(cfg, "Protected")
+	if err != nil {
+		t.Fatalf("CPU Expected physical memory, got %s", err)
+	}
+	return pmc
+}
+
+func TestCPUPrivilege(t *testing.T) {
+	pmc := protectedMemory(t)
+	program := []Onyx1ISA.Instruction{
+		movi(1, 0x10000),
+		movi(2, 42),
+		inst(Onyx1ISA.ISA_OP_STORE, Onyx1ISA.ISA_SIZE_64, 0, 2, 0, ind(1)),               // 24
+		inst(Onyx1ISA.ISA_OP_SETSR, Onyx1ISA.ISA_SIZE_64, 0, 0, 0, imm(0)),               // 28
+		inst(Onyx1ISA.ISA_OP_GETSR, Onyx1ISA.ISA_SIZE_64, 0, 3, 0, reg(0)),               // 40
+		inst(Onyx1ISA.ISA_OP_LOAD, Onyx1ISA.ISA_SIZE_64, 0, 4, 0, ind(1)),                // 44
+		inst(Onyx1ISA.ISA_OP_STORE, Onyx1ISA.ISA_SIZE_64, 0, 2, 0, idx(1, 0, 0, -0x100)), // 48
+		halt, // 57
+	}
+	addr := uint64(0)
+	for _, in := range program {
+		b, _ := Onyx1ISA.Encode(in)
+		for _, v := range b {
+			pmc.WriteAddress(addr, v)
+			addr++
+		}
+	}
+	cpu, err := CPU_Initialize(Configuration.CPUDescriptor{}, pmc)
+	if err != nil {
+		t.Fatalf("CPU Expected to initialize, got %s", err)
+	}
+	cpu.Reset(0)
+	if !cpu.Supervisor() {
+		t.Errorf("CPU Expected to reset into supervisor mode")
+	}
+	for i := 0; i < 5; i++ {
+		err = cpu.Step()
+		if err != nil {
+			t.Fatalf("CPU Expected step %d to run, got %s", i, err)
+		}
+	}
+	v, _ := pmc.ReadAddress(0x10000)
+	if v != 42 || cpu.Supervisor() || cpu.Registers[3] != 0 {
+		t.Errorf("CPU Expected the supervisor store and a drop to user mode, got %d %x", v, cpu.Registers[3])
+	}
+	err = cpu.Step()
+	if !errors.Is(err, PhysicalMemory.ErrProtectionFault) || cpu.PC != 44 {
+		t.Errorf("CPU Expected a protection fault reading Kernel-RAM at 44, got %v at %d", err, cpu.PC)
+	}
+	// User RAM is still usable
+	cpu.PC = 48
+	err = cpu.Step()
+	if err != nil {
+		t.Errorf("CPU Expected a user store to RAM, got %s", err)
+	}
+	err = cpu.Step()
+	if err == nil || err.Error() != "Privileged instruction" || cpu.PC != 57 {
+		t.Errorf("CPU Expected HALT to be privileged in user mode, got %v at %d", err, cpu.PC)
+	}
+	for _, op := range []int{Onyx1ISA.ISA_OP_SETSR, Onyx1ISA.ISA_OP_IN, Onyx1ISA.ISA_OP_OUT} {
+		if !Onyx1ISA.ISAOpcodes[op].Privileged {
+			t.Errorf("CPU Expected %s to be privileged", Onyx1ISA.ISAOpcodes[op].Mnemonic)
+		}
+	}
+	// Instructions can't be fetched from Kernel-RAM in user mode
+	cpu.PC = 0x10000
+	err = cpu.Step()
+	if !errors.Is(err, PhysicalMemory.ErrProtectionFault) {
+		t.Errorf("CPU Expected a protection fault fetching from Kernel-RAM, got %v", err)
+	}
+}
+
+func TestCPUVirtualMemory(t *testing.T) {
+	// Virtual page n is backed by block n, so page 1 is Kernel-RAM and page 2 has no memory
+	cfg := Configuration.ConfigObject{
+		Settings: Configuration.ConfigSettings{SwapFileName: filepath.Join(t.TempDir(), "swap.swp")},
+		Configuration: []Configuration.SystemConfigs{{
+			Name: "Paged",
+			Description: Configuration.ConfigurationDescriptor{Memory: []Configuration.MemoryDescriptor{
+				{Key: 0, StartAddress: 0x0000, EndAddress: 0xFFFF, MemoryType: "Virtual-RAM"},
+				{Key: 1, StartAddress: 0x10000, EndAddress: 0x1FFFF, MemoryType: "Kernel-RAM"},
+			}},
+		}},
+	}
+	vmc, err := VirtualMemory.VirtualMemoryInitialize(cfg, "Paged")
+	if err != nil {
+		t.Fatalf("CPU Expected virtual memory, got %s", err)
+	}
+	defer vmc.Terminate()
+	for page := uint32(0); page < 4; page++ {
+		vmc.SetPageActive(page)
+	}
+	// Page 3 shares block 0 but is marked for the system only
+	p3 := vmc.MemoryPages[3]
+	p3.PhysicalPage = 0
+	vmc.MemoryPages[3] = p3
+	vmc.SetPageNeedsSystem(3)
+	program := []Onyx1ISA.Instruction{
+		movi(1, 0x1000),
+		movi(2, 42),
+		inst(Onyx1ISA.ISA_OP_STORE, Onyx1ISA.ISA_SIZE_64, 0, 2, 0, ind(1)),                                  // 24
+		inst(Onyx1ISA.ISA_OP_SETSR, Onyx1ISA.ISA_SIZE_64, 0, 0, 0, imm(0)),                                  // 28
+		inst(Onyx1ISA.ISA_OP_LOAD, Onyx1ISA.ISA_SIZE_64, 0, 3, 0, ind(0)),                                   // 40
+		inst(Onyx1ISA.ISA_OP_LOAD, Onyx1ISA.ISA_SIZE_64, 0, 4, 0, ind(1)),                                   // 44
+		inst(Onyx1ISA.ISA_OP_LOAD, Onyx1ISA.ISA_SIZE_64, 0, 5, 0, idx(1, Onyx1ISA.ISA_NO_INDEX, 0, 0x2000)), // 48
+		inst(Onyx1ISA.ISA_OP_LOAD, Onyx1ISA.ISA_SIZE_64, 0, 6, 0, idx(1, Onyx1ISA.ISA_NO_INDEX, 0, 0x1000)), // 57
+	}
+	addr := uint64(0)
+	for _, in := range program {
+		b, _ := Onyx1ISA.Encode(in)
+		for _, v := range b {
+			vmc.WriteAddress(addr, v)
+			addr++
+		}
+	}
+	cpu, err := CPU_Initialize(Configuration.CPUDescriptor{}, vmc)
+	if err != nil {
+		t.Fatalf("CPU Expected to initialize, got %s", err)
+	}
+	cpu.Reset(0)
+	for i := 0; i < 5; i++ {
+		err = cpu.Step()
+		if err != nil {
+			t.Fatalf("CPU Expected step %d to run, got %s", i, err)
+		}
+	}
+	v, _ := vmc.ReadAddress(0x1000)
+	if v != 42 || cpu.Supervisor() || cpu.Registers[3] == 0 {
+		t.Errorf("CPU Expected the supervisor store to Kernel-RAM and a user load from RAM, got %d %X", v, cpu.Registers[3])
+	}
+	tests := []struct {
+		pc     uint64
+		vector int
+	}{
+		{44, CPU_VECTOR_PROTECTION},
+		{48, CPU_VECTOR_PROTECTION},
+		{57, CPU_VECTOR_PAGEFAULT},
+	}
+	for _, tt := range tests {
+		cpu.PC = tt.pc
+		err = cpu.Step()
+		var trap *CPUTrap
+		if !errors.As(err, &trap) || trap.Vector != tt.vector {
+			t.Errorf("CPU Expected vector %d at %d, got %v", tt.vector, tt.pc, err)
+		}
+	}
+	if !errors.Is(vmc.CheckPageAccess(3, PhysicalMemory.AccessMode_User), PhysicalMemory.ErrProtectionFault) ||
+		vmc.CheckPageAccess(3, PhysicalMemory.AccessMode_System) != nil {
+		t.Errorf("CheckPageAccess Expected page 3 to need system access")
+	}
+}
@@ -52,6 +52,11 @@ type ConfigObject struct {
 	Configuration []SystemConfigs `json:"configuration"`
 }
 
+// CPUDescriptor.FeatureA bits
+const (
+	FeatureA_NoFPU = 0x0000_0000_0000_0001
+)
+
 var MemoryTypeNames = []string{
 	"Empty",
 	"Virtual-RAM",
@@ -76,10 +81,12 @@ func MockConfig() ([]byte, error) {
 				Name: "Old-IBM-Mainframe",
 				Description: ConfigurationDescriptor{
 					CPU: CPUDescriptor{
-						CPUType:    1000_0000_0000_0000,
-						FeatureA:   0000_0000_0000_0000,
-						FeatureB:   0000_0000_0000_0000,
-						Parameters: map[string]string{},
+						CPUType:  1000_0000_0000_0000,
+						FeatureA: 0000_0000_0000_0000,
+						FeatureB: 0000_0000_0000_0000,
+						Parameters: map[string]string{
+							"alu.cycles.scale": "2",
+						},
 					},
 					Memory: []MemoryDescriptor{
 						MemoryDescriptor{
@@ -232,10 +239,13 @@ func MockConfig() ([]byte, error) {
 				Name: "Kaypro-CPM-64KB",
 				Description: ConfigurationDescriptor{
 					CPU: CPUDescriptor{
-						CPUType:    1000_0000_0000_0000,
-						FeatureA:   0000_0000_0000_0000,
-						FeatureB:   0000_0000_0000_0000,
-						Parameters: map[string]string{},
+						CPUType:  1000_0000_0000_0000,
+						FeatureA: FeatureA_NoFPU,
+						FeatureB: 0000_0000_0000_0000,
+						Parameters: map[string]string{
+							"alu.cycles.scale":      "10",
+							"alu.cycles.kind.FIXED": "8",
+						},
 					},
 					Memory: []MemoryDescriptor{
 						MemoryDescriptor{
@@ -317,10 +327,13 @@ func MockConfig() ([]byte, error) {
 				Name: "Vax-11/780-64MB",
 				Description: ConfigurationDescriptor{
 					CPU: CPUDescriptor{
-						CPUType:    1000_0000_0000_0000,
-						FeatureA:   0000_0000_0000_0000,
-						FeatureB:   0000_0000_0000_0000,
-						Parameters: map[string]string{},
+						CPUType:  1000_0000_0000_0000,
+						FeatureA: 0000_0000_0000_0000,
+						FeatureB: 0000_0000_0000_0000,
+						Parameters: map[string]string{
+							"alu.cycles.DIV":  "60",
+							"alu.cycles.FSIN": "400",
+						},
 					},
 					Memory: []MemoryDescriptor{
 						MemoryDescriptor{
@@ -0,0 +1,176 @@
+package Onyx1Disassembler
+
+import (
+	Onyx1ALU "GolangCPUParts/ALU"
+	Onyx1ISA "GolangCPUParts/ISA"
+	"GolangCPUParts/MemoryPackage/PhysicalMemory"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Line is one disassembled instruction, or a .byte for a byte that doesn't start a valid
+// instruction
+type Line struct {
+	Address uint64
+	Bytes   []byte
+	Text    string
+	Valid   bool
+	Inst    Onyx1ISA.Instruction
+}
+
+// bufferReader reads a physical memory block's buffer directly, so blocks that overlap
+// another block or need system access can be disassembled too
+type bufferReader struct {
+	buffer []byte
+	start  uint64
+}
+
+func (br bufferReader) ReadAddress(addr uint64) (byte, error) {
+	if addr < br.start || addr-br.start >= uint64(len(br.buffer)) {
+		return 0, errors.New("Address outside the memory block")
+	}
+	return br.buffer[addr-br.start], nil
+}
+
+// DisassembleBlock disassembles the whole of a physical memory block
+func DisassembleBlock(pmc *PhysicalMemory.PhysicalMemoryManager, key int, symbols *SymbolTable) ([]Line, error) {
+	block, err := pmc.GetBlockByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	br := bufferReader{buffer: block.Buffer, start: block.StartAddress}
+	return Disassemble(br, block.StartAddress, block.StartAddress+uint64(len(block.Buffer)), symbols)
+}
+
+// Disassemble disassembles from start up to end through mem, which can be a
+// VirtualMemory.VMContainer or PhysicalMemory.PhysicalMemoryManager. Bytes that don't
+// decode become .byte lines and disassembly carries on after them, so a damaged image
+// still lines up again. A read error stops it, returning the lines so far.
+func Disassemble(mem Onyx1ISA.ByteReader, start uint64, end uint64, symbols *SymbolTable) ([]Line, error) {
+	var lines []Line
+	for addr := start; addr < end; {
+		b, err := fetch(mem, addr, end)
+		if err != nil {
+			return lines, err
+		}
+		inst, err := Onyx1ISA.DecodeBytes(b, addr)
+		if err != nil {
+			lines = append(lines, Line{Address: addr, Bytes: b[:1], Text: fmt.Sprintf(".byte 0x%02X", b[0])})
+			addr++
+			continue
+		}
+		lines = append(lines, Line{Address: addr, Bytes: b[:inst.Length], Text: Format(inst, symbols), Valid: true, Inst: inst})
+		addr += uint64(inst.Length)
+	}
+	return lines, nil
+}
+
+// fetch reads the bytes of the instruction at addr, or as many as there are before end
+func fetch(mem Onyx1ISA.ByteReader, addr uint64, end uint64) ([]byte, error) {
+	var b []byte
+	n := Onyx1ISA.ISA_HEADER_LENGTH
+	for i := 0; i < n && addr+uint64(i) < end; i++ {
+		v, err := mem.ReadAddress(addr + uint64(i))
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, v)
+		if i == 3 {
+			n = Onyx1ISA.InstructionLength(v)
+		}
+	}
+	return b, nil
+}
+
+// Format renders an instruction in the assembler's syntax. Jump and call targets are
+// shown as symbols when the table has one at or below them, and the sizes are only given
+// when they aren't the default .Q.
+func Format(inst Onyx1ISA.Instruction, symbols *SymbolTable) string {
+	info := Onyx1ISA.ISAOpcodes[inst.Opcode]
+	mnemonic := info.Mnemonic
+	var args []string
+	switch info.Form {
+	case Onyx1ISA.ISA_FORM_RD:
+		args = []string{register(inst.Rd)}
+	case Onyx1ISA.ISA_FORM_RD_OPERAND:
+		args = []string{register(inst.Rd), operand(inst.Operand)}
+	case Onyx1ISA.ISA_FORM_OPERAND:
+		if inst.Opcode == Onyx1ISA.ISA_OP_JMP && inst.Func != Onyx1ALU.ALU_COND_AL {
+			mnemonic = "J" + Onyx1ALU.ALUConditionNames[inst.Func]
+		}
+		branch := inst.Opcode == Onyx1ISA.ISA_OP_JMP || inst.Opcode == Onyx1ISA.ISA_OP_CALL
+		if inst.Operand.Mode == Onyx1ISA.ISA_MODE_IMMEDIATE && branch {
+			args = []string{symbols.Format(inst.Operand.Immediate)}
+		} else {
+			args = []string{operand(inst.Operand)}
+		}
+	case Onyx1ISA.ISA_FORM_ALU:
+		alu, _ := Onyx1ALU.ALULookupOp(inst.Func)
+		mnemonic = alu.Mnemonic
+		switch {
+		case alu.Results == 0:
+			args = []string{register(inst.Ra), operand(inst.Operand)}
+		case alu.Arity == 1:
+			args = []string{register(inst.Rd), register(inst.Ra)}
+		default:
+			args = []string{register(inst.Rd), register(inst.Ra), operand(inst.Operand)}
+		}
+	}
+	if info.Sized && inst.Size != Onyx1ISA.ISA_SIZE_64 {
+		mnemonic += Onyx1ISA.ISASizeSuffixes[inst.Size]
+	}
+	if len(args) == 0 {
+		return mnemonic
+	}
+	return mnemonic + " " + strings.Join(args, ", ")
+}
+
+func register(r int) string {
+	if r == Onyx1ISA.ISA_REG_SP {
+		return "SP"
+	}
+	return fmt.Sprintf("R%d", r)
+}
+
+func operand(op Onyx1ISA.Operand) string {
+	switch op.Mode {
+	case Onyx1ISA.ISA_MODE_REGISTER:
+		return register(op.Reg)
+	case Onyx1ISA.ISA_MODE_IMMEDIATE:
+		return fmt.Sprintf("#0x%X", op.Immediate)
+	case Onyx1ISA.ISA_MODE_INDIRECT:
+		return "[" + register(op.Reg) + "]"
+	}
+	s := "[" + register(op.Reg)
+	if op.Index != Onyx1ISA.ISA_NO_INDEX {
+		s += " + " + register(op.Index)
+		if op.Scale != 0 {
+			s += fmt.Sprintf("*%d", 1<<op.Scale)
+		}
+	}
+	switch {
+	case op.Disp < 0:
+		s += fmt.Sprintf(" - 0x%X", -int64(op.Disp))
+	case op.Disp > 0 || op.Index == Onyx1ISA.ISA_NO_INDEX:
+		s += fmt.Sprintf(" + 0x%X", op.Disp)
+	}
+	return s + "]"
+}
+
+// Listing renders lines like the assembler's listing, each symbol on a line of its own
+// before the instruction at its address
+func Listing(lines []Line, symbols *SymbolTable) string {
+	var w strings.Builder
+	for _, l := range lines {
+		for _, name := range symbols.Names(l.Address) {
+			fmt.Fprintf(&w, "%16s  %-36s %s:\n", "", "", name)
+		}
+		hex := ""
+		for _, b := range l.Bytes {
+			hex += fmt.Sprintf("%02X ", b)
+		}
+		fmt.Fprintf(&w, "%016X  %-36s     %s\n", l.Address, hex, l.Text)
+	}
+	return w.String()
+}
@@ -0,0 +1,185 @@
+package Onyx1Disassembler
+
+import (
+	Onyx1Assembler "GolangCPUParts/Assembler"
+	"GolangCPUParts/Configuration"
+	Onyx1ISA "GolangCPUParts/ISA"
+	"GolangCPUParts/MemoryPackage/PhysicalMemory"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// sliceMemory is a ByteReader over a slice starting at address 0
+type sliceMemory []byte
+
+func (m sliceMemory) ReadAddress(addr uint64) (byte, error) {
+	if addr >= uint64(len(m)) {
+		return 0, errors.New("Address out of range")
+	}
+	return m[addr], nil
+}
+
+func assemble(t *testing.T, src string) *Onyx1Assembler.Program {
+	prog, err := Onyx1Assembler.Assembler_Initialize().Assemble("test.s", src)
+	if err != nil {
+		t.Fatalf("Assemble Expected no error, got %s", err)
+	}
+	return prog
+}
+
+const program = `
+		.org 0x100
+start:	MOV R1, #0x10
+		ADD.D R2, R1, R3
+		NOT R4, R5
+		CMP.B R1, #7
+		FMA R6, R7, [R8 + R9*4 - 0x20]
+		LOAD.W R5, [R6]
+		STORE R7, [SP + 0x8]
+		LEA R3, [R2 + R4]
+loop:	JNE loop
+		JMP [R1 + R2*8 + 0x0]
+		CALL start + 4
+		PUSH R1
+		POP SP
+		OUT.B R1, #0x20
+		GETSR R2
+		SETSR #0x10000
+		SYSCALL #0x5
+		BRK
+		RTE
+		RET
+		HALT
+`
+
+func TestDisassembleRoundTrip(t *testing.T) {
+	prog := assemble(t, program)
+	seg := prog.Segments[0]
+	mem := make(sliceMemory, seg.Address+uint64(len(seg.Bytes)))
+	copy(mem[seg.Address:], seg.Bytes)
+	symbols := SymbolTable_Initialize(prog.Labels)
+	lines, err := Disassemble(mem, seg.Address, uint64(len(mem)), symbols)
+	if err != nil {
+		t.Fatalf("Disassemble Expected no error, got %s", err)
+	}
+	src := []string{".org 0x100"}
+	for _, l := range lines {
+		if !l.Valid {
+			t.Errorf("Disassemble Expected valid instructions, got %s at %X", l.Text, l.Address)
+		}
+		for _, name := range symbols.Names(l.Address) {
+			src = append(src, name+":")
+		}
+		src = append(src, l.Text)
+	}
+	again := assemble(t, strings.Join(src, "\n"))
+	if !bytes.Equal(again.Segments[0].Bytes, seg.Bytes) {
+		t.Errorf("Disassemble Expected to reassemble the same code, got\n%s", strings.Join(src, "\n"))
+	}
+	texts := map[string]bool{}
+	for _, l := range lines {
+		texts[l.Text] = true
+	}
+	for _, want := range []string{"ADD.D R2, R1, R3", "CMP.B R1, #0x7", "FMA R6, R7, [R8 + R9*4 - 0x20]",
+		"STORE R7, [SP + 0x8]", "JNE loop", "CALL start+0x4", "POP SP", "SETSR #0x10000", "SYSCALL #0x5", "RTE"} {
+		if !texts[want] {
+			t.Errorf("Disassemble Expected %q in the output", want)
+		}
+	}
+	listing := Listing(lines, symbols)
+	if !strings.Contains(listing, "loop:\n") || !strings.Contains(listing, "0000000000000100  0F 00 10 10 10 00") {
+		t.Errorf("Listing Expected labels and bytes, got\n%s", listing)
+	}
+}
+
+func TestDisassembleBadCode(t *testing.T) {
+	halt, _ := Onyx1ISA.Encode(Onyx1ISA.Instruction{Opcode: Onyx1ISA.ISA_OP_HALT, Size: Onyx1ISA.ISA_SIZE_64})
+	mem := append(sliceMemory{0xFC, 0xFC}, halt...)
+	mem = append(mem, 0x0C, 0x00, 0x00, 0x10) // a MOV missing its immediate
+	lines, err := Disassemble(mem, 0, uint64(len(mem)), nil)
+	if err != nil {
+		t.Fatalf("Disassemble Expected no error, got %s", err)
+	}
+	want := []string{".byte 0xFC", ".byte 0xFC", "HALT", ".byte 0x0C", ".byte 0x00", ".byte 0x00", ".byte 0x10"}
+	if len(lines) != len(want) {
+		t.Fatalf("Disassemble Expected %d lines, got %+v", len(want), lines)
+	}
+	for i, l := range lines {
+		if l.Text != want[i] {
+			t.Errorf("Disassemble Expected %q, got %q", want[i], l.Text)
+		}
+	}
+	// Past the end of mem the MOV's immediate can't be read
+	lines, err = Disassemble(mem, 2, uint64(len(mem))+8, nil)
+	if err == nil || len(lines) != 1 {
+		t.Errorf("Disassemble Expected the lines before a read error and the error, got %d lines, %v", len(lines), err)
+	}
+}
+
+func TestDisassembleBlock(t *testing.T) {
+	s, _ := Configuration.MockConfig()
+	cfg, err := Configuration.LoadConfiguration(s)
+	if err != nil {
+		t.Fatalf("Disassemble Expected the mock configuration to load, got %s", err)
+	}
+	pmc, err := PhysicalMemory.PhysicalMemoryInitialize(cfg, "Old-IBM-Mainframe")
+	if err != nil {
+		t.Fatalf("Disassemble Expected physical memory, got %s", err)
+	}
+	// Kernel-RAM is block 1, 0x20000 to 0x2FFFF
+	prog := assemble(t, ".org 0x20000\nentry: CALL handler\nHALT\nhandler: RET")
+	err = prog.LoadRegion(pmc, 1)
+	if err != nil {
+		t.Fatalf("LoadRegion Expected no error, got %s", err)
+	}
+	lines, err := DisassembleBlock(pmc, 1, SymbolTable_Initialize(prog.Labels))
+	if err != nil || len(lines) < 3 {
+		t.Fatalf("DisassembleBlock Expected the block, got %d lines, %v", len(lines), err)
+	}
+	if lines[0].Address != 0x20000 || lines[0].Text != "CALL handler" || lines[2].Text != "RET" {
+		t.Errorf("DisassembleBlock Expected CALL handler, HALT, RET, got %+v", lines[:3])
+	}
+	last := lines[len(lines)-1]
+	if last.Address+uint64(len(last.Bytes)) != 0x20000+0xFFFF {
+		t.Errorf("DisassembleBlock Expected to stop at the end of the block, got %X", last.Address)
+	}
+}
+
+func TestDisassembleLabelsOnly(t *testing.T) {
+	// LIMIT is nearer the jump target than start, but it isn't an address
+	prog := assemble(t, ".org 0x100\nLIMIT = 0x180\nstart: JMP 0x190")
+	seg := prog.Segments[0]
+	mem := make(sliceMemory, seg.Address+uint64(len(seg.Bytes)))
+	copy(mem[seg.Address:], seg.Bytes)
+	lines, err := Disassemble(mem, seg.Address, uint64(len(mem)), SymbolTable_Initialize(prog.Labels))
+	if err != nil || len(lines) != 1 || lines[0].Text != "JMP start+0x90" {
+		t.Errorf("Disassemble Expected JMP start+0x90, got %+v %v", lines, err)
+	}
+}
+
+func TestSymbolTable(t *testing.T) {
+	st := SymbolTable_Initialize(map[string]uint64{"b": 0x100, "a": 0x100, "c": 0x200})
+	tests := []struct {
+		addr uint64
+		want string
+	}{
+		{0x10, "0x10"},
+		{0x100, "a"},
+		{0x1FF, "a+0xFF"},
+		{0x280, "c+0x80"},
+	}
+	for _, tt := range tests {
+		if got := st.Format(tt.addr); got != tt.want {
+			t.Errorf("Format %X Expected %s, got %s", tt.addr, tt.want, got)
+		}
+	}
+	if names := st.Names(0x100); len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Errorf("Names Expected [a b], got %v", names)
+	}
+	var none *SymbolTable
+	if none.Format(0x100) != "0x100" || none.Names(0x100) != nil {
+		t.Errorf("Format Expected a nil table to have no symbols")
+	}
+}
@@ -0,0 +1,61 @@
+package Onyx1Disassembler
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SymbolTable looks symbols up by address. Build it from an assembler Program's Labels
+// rather than its Symbols, or constants will turn up as the names of nearby addresses.
+type SymbolTable struct {
+	names map[uint64][]string
+	addrs []uint64
+}
+
+func SymbolTable_Initialize(symbols map[string]uint64) *SymbolTable {
+	st := SymbolTable{names: map[uint64][]string{}}
+	for name, addr := range symbols {
+		if _, ok := st.names[addr]; !ok {
+			st.addrs = append(st.addrs, addr)
+		}
+		st.names[addr] = append(st.names[addr], name)
+	}
+	for _, names := range st.names {
+		sort.Strings(names)
+	}
+	sort.Slice(st.addrs, func(i, j int) bool { return st.addrs[i] < st.addrs[j] })
+	return &st
+}
+
+// Names returns the symbols at exactly addr in name order. A nil table has none.
+func (st *SymbolTable) Names(addr uint64) []string {
+	if st == nil {
+		return nil
+	}
+	return st.names[addr]
+}
+
+// Lookup finds the nearest symbol at or below addr and addr's offset from it
+func (st *SymbolTable) Lookup(addr uint64) (name string, offset uint64, ok bool) {
+	if st == nil {
+		return "", 0, false
+	}
+	i := sort.Search(len(st.addrs), func(i int) bool { return st.addrs[i] > addr })
+	if i == 0 {
+		return "", 0, false
+	}
+	base := st.addrs[i-1]
+	return st.names[base][0], addr - base, true
+}
+
+// Format renders addr as symbol or symbol+offset, or in hex when no symbol is below it
+func (st *SymbolTable) Format(addr uint64) string {
+	name, offset, ok := st.Lookup(addr)
+	switch {
+	case !ok:
+		return fmt.Sprintf("0x%X", addr)
+	case offset == 0:
+		return name
+	}
+	return fmt.Sprintf("%s+0x%X", name, offset)
+}
@@ -0,0 +1,179 @@
+package IOSupport
+
+import (
+	"GolangCPUParts/IOSupport/PortIO"
+	"errors"
+	"sync"
+)
+
+const (
+	IRQ_NUM_LINES = 16
+
+	// Lines for the standard devices. The rest are free for other devices.
+	IRQ_TIMER   = 0
+	IRQ_CONSOLE = 1
+	IRQ_DISK    = 2
+
+	// The timer behind LegacyClockInterruptPort
+	LegacyClockTimer = 0
+)
+
+// InterruptController collects interrupt requests from devices for the CPU. A device
+// raises its line, which stays pending until the CPU takes it, and the line is then in
+// service until the handler acknowledges it. Only a line of higher priority than every
+// line in service is delivered, ties going to the lower line number, and masked lines
+// stay pending. Devices can raise lines from their own goroutines.
+type InterruptController struct {
+	Pending   uint64
+	Mask      uint64
+	InService uint64
+	Priority  [IRQ_NUM_LINES]byte
+	lock      sync.Mutex
+}
+
+func InterruptController_Initialize() *InterruptController {
+	return &InterruptController{}
+}
+
+func checkLine(line int) error {
+	if line < 0 || line >= IRQ_NUM_LINES {
+		return errors.New("No such interrupt line")
+	}
+	return nil
+}
+
+// Raise requests an interrupt on line
+func (ic *InterruptController) Raise(line int) error {
+	err := checkLine(line)
+	if err != nil {
+		return err
+	}
+	ic.lock.Lock()
+	defer ic.lock.Unlock()
+	ic.Pending |= 1 << line
+	return nil
+}
+
+// MaskLine masks line, which then stays pending until the mask bit is cleared
+func (ic *InterruptController) MaskLine(line int) error {
+	err := checkLine(line)
+	if err != nil {
+		return err
+	}
+	ic.lock.Lock()
+	defer ic.lock.Unlock()
+	ic.Mask |= 1 << line
+	return nil
+}
+
+// Next finds the line the CPU should take, if any
+func (ic *InterruptController) Next() (int, bool) {
+	ic.lock.Lock()
+	defer ic.lock.Unlock()
+	level := -1
+	for line := 0; line < IRQ_NUM_LINES; line++ {
+		if ic.InService&(1<<line) != 0 && int(ic.Priority[line]) > level {
+			level = int(ic.Priority[line])
+		}
+	}
+	best := -1
+	for line := 0; line < IRQ_NUM_LINES; line++ {
+		if (ic.Pending&^ic.Mask)&(1<<line) == 0 || int(ic.Priority[line]) <= level {
+			continue
+		}
+		if best < 0 || ic.Priority[line] > ic.Priority[best] {
+			best = line
+		}
+	}
+	return best, best >= 0
+}
+
+// Take moves line from pending to in service as the CPU enters its handler
+func (ic *InterruptController) Take(line int) {
+	ic.lock.Lock()
+	defer ic.lock.Unlock()
+	ic.Pending &^= 1 << line
+	ic.InService |= 1 << line
+}
+
+// Acknowledge ends the service of line, letting lines of the same or lower priority in
+func (ic *InterruptController) Acknowledge(line int) error {
+	err := checkLine(line)
+	if err != nil {
+		return err
+	}
+	ic.lock.Lock()
+	defer ic.lock.Unlock()
+	ic.InService &^= 1 << line
+	return nil
+}
+
+// Ports returns a copy of ports with the controller's registers added:
+//
+//	InterruptPendingPort       read the pending lines
+//	InterruptMaskPort          read or write the mask, a set bit masking its line
+//	InterruptAckPort           write a line number to acknowledge it, read the lines in service
+//	InterruptRaisePort         write a line number to raise it from software
+//	InterruptPriorityPort + n  read or write line n's priority byte, higher going first
+//
+// It also connects LegacyClockInterruptPort, where writing a count of ticks starts the
+// legacy clock's timer to raise IRQ_TIMER.
+func (ic *InterruptController) Ports(ports map[uint64]PortIO.PortIOConfigObject) map[uint64]PortIO.PortIOConfigObject {
+	m := map[uint64]PortIO.PortIOConfigObject{}
+	for port, dev := range ports {
+		m[port] = dev
+	}
+	m[PortIO.InterruptPendingPort] = PortIO.PortIOConfigObject{
+		Name:         "InterruptPending",
+		HandleInQuad: func(port uint64) (uint64, error) { return ic.read(&ic.Pending), nil },
+	}
+	m[PortIO.InterruptMaskPort] = PortIO.PortIOConfigObject{
+		Name:         "InterruptMask",
+		HandleInQuad: func(port uint64) (uint64, error) { return ic.read(&ic.Mask), nil },
+		HandleOutQuad: func(port uint64, value uint64) error {
+			ic.lock.Lock()
+			defer ic.lock.Unlock()
+			ic.Mask = value
+			return nil
+		},
+	}
+	m[PortIO.InterruptAckPort] = PortIO.PortIOConfigObject{
+		Name:          "InterruptAck",
+		HandleInQuad:  func(port uint64) (uint64, error) { return ic.read(&ic.InService), nil },
+		HandleOutQuad: func(port uint64, value uint64) error { return ic.Acknowledge(int(value)) },
+	}
+	m[PortIO.InterruptRaisePort] = PortIO.PortIOConfigObject{
+		Name:          "InterruptRaise",
+		HandleOutQuad: func(port uint64, value uint64) error { return ic.Raise(int(value)) },
+	}
+	for line := 0; line < IRQ_NUM_LINES; line++ {
+		m[PortIO.InterruptPriorityPort+uint64(line)] = PortIO.PortIOConfigObject{
+			Name: "InterruptPriority",
+			HandleInByte: func(port uint64) (byte, error) {
+				ic.lock.Lock()
+				defer ic.lock.Unlock()
+				return ic.Priority[line], nil
+			},
+			HandleOutByte: func(port uint64, value byte) error {
+				ic.lock.Lock()
+				defer ic.lock.Unlock()
+				ic.Priority[line] = value
+				return nil
+			},
+		}
+	}
+	clock := m[PortIO.LegacyClockInterruptPort]
+	clock.Name = "LegacyClockInterrupt"
+	clock.HandleOutQuad = func(port uint64, value uint64) error {
+		Timer_Add(LegacyClockTimer, value, IRQ_TIMER)
+		return nil
+	}
+	m[PortIO.LegacyClockInterruptPort] = clock
+	return m
+}
+
+func (ic *InterruptController) read(reg *uint64) uint64 {
+	ic.lock.Lock()
+	defer ic.lock.Unlock()
+	return *reg
+}
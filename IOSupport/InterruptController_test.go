@@ -0,0 +1,138 @@
+package IOSupport
+
+import (
+	"GolangCPUParts/IOSupport/PortIO"
+	"sync"
+	"testing"
+)
+
+func TestInterruptPriority(t *testing.T) {
+	ic := InterruptController_Initialize()
+	if _, ok := ic.Next(); ok {
+		t.Errorf("Next Expected nothing pending")
+	}
+	ic.Raise(IRQ_DISK)
+	ic.Raise(IRQ_CONSOLE)
+	if line, _ := ic.Next(); line != IRQ_CONSOLE {
+		t.Errorf("Next Expected the lower line on a tie, got %d", line)
+	}
+	ic.Priority[IRQ_DISK] = 2
+	if line, _ := ic.Next(); line != IRQ_DISK {
+		t.Errorf("Next Expected the higher priority line, got %d", line)
+	}
+	ic.Take(IRQ_DISK)
+	if _, ok := ic.Next(); ok {
+		t.Errorf("Next Expected a lower priority line to wait while the disk is in service")
+	}
+	ic.Raise(IRQ_TIMER)
+	ic.Priority[IRQ_TIMER] = 3
+	if line, _ := ic.Next(); line != IRQ_TIMER {
+		t.Errorf("Next Expected a higher priority line to nest, got %d", line)
+	}
+	ic.MaskLine(IRQ_TIMER)
+	ic.Acknowledge(IRQ_DISK)
+	if line, _ := ic.Next(); line != IRQ_CONSOLE || ic.Pending != 1<<IRQ_TIMER|1<<IRQ_CONSOLE {
+		t.Errorf("Next Expected the masked timer to stay pending, got %d %X", line, ic.Pending)
+	}
+	if ic.Raise(IRQ_NUM_LINES) == nil || ic.Acknowledge(-1) == nil || ic.MaskLine(IRQ_NUM_LINES) == nil {
+		t.Errorf("Raise Expected an error for a line that doesn't exist")
+	}
+}
+
+func TestInterruptPorts(t *testing.T) {
+	ic := InterruptController_Initialize()
+	ports := ic.Ports(PortIO.PortIOConfig)
+	if _, ok := PortIO.PortIOConfig[PortIO.InterruptPendingPort]; ok {
+		t.Errorf("Ports Expected to leave the map it was given alone")
+	}
+	if ports[PortIO.LegacyBeeperPort].Name != "LegacyBeeper" {
+		t.Errorf("Ports Expected the existing devices")
+	}
+	ports[PortIO.InterruptRaisePort].HandleOutQuad(PortIO.InterruptRaisePort, IRQ_DISK)
+	ports[PortIO.InterruptMaskPort].HandleOutQuad(PortIO.InterruptMaskPort, 0xFF00)
+	ports[PortIO.InterruptPriorityPort+IRQ_DISK].HandleOutByte(PortIO.InterruptPriorityPort+IRQ_DISK, 7)
+	pending, _ := ports[PortIO.InterruptPendingPort].HandleInQuad(PortIO.InterruptPendingPort)
+	mask, _ := ports[PortIO.InterruptMaskPort].HandleInQuad(PortIO.InterruptMaskPort)
+	prio, _ := ports[PortIO.InterruptPriorityPort+IRQ_DISK].HandleInByte(PortIO.InterruptPriorityPort + IRQ_DISK)
+	if pending != 1<<IRQ_DISK || mask != 0xFF00 || prio != 7 {
+		t.Errorf("Ports Expected pending 4, mask FF00 and priority 7, got %X %X %d", pending, mask, prio)
+	}
+	ic.Take(IRQ_DISK)
+	ports[PortIO.InterruptAckPort].HandleOutQuad(PortIO.InterruptAckPort, IRQ_DISK)
+	service, _ := ports[PortIO.InterruptAckPort].HandleInQuad(PortIO.InterruptAckPort)
+	if service != 0 || ic.Pending != 0 {
+		t.Errorf("Ports Expected the acknowledge to end service, got %X", service)
+	}
+	if ports[PortIO.InterruptAckPort].HandleOutQuad(PortIO.InterruptAckPort, 99) == nil {
+		t.Errorf("Ports Expected an error acknowledging a line that doesn't exist")
+	}
+}
+
+func TestTimerInterrupt(t *testing.T) {
+	Timer_Initialize()
+	defer Timer_Terminate()
+	ic := InterruptController_Initialize()
+	ports := ic.Ports(PortIO.PortIOConfig)
+	ports[PortIO.LegacyClockInterruptPort].HandleOutQuad(PortIO.LegacyClockInterruptPort, 2)
+	Timer_Add(5, 3, IRQ_DISK)
+	Timer_Tick(ic, 1)
+	if ic.Pending != 0 {
+		t.Errorf("Timer_Tick Expected no interrupt yet, got %X", ic.Pending)
+	}
+	Timer_Tick(ic, 1)
+	if ic.Pending != 1<<IRQ_TIMER || len(TimerService) != 1 {
+		t.Errorf("Timer_Tick Expected the clock's interrupt, got %X", ic.Pending)
+	}
+	if Timer_Remove(5) != nil || Timer_Remove(5) == nil {
+		t.Errorf("Timer_Remove Expected to remove the timer once")
+	}
+	Timer_Tick(ic, 1)
+	if ic.Pending != 1<<IRQ_TIMER {
+		t.Errorf("Timer_Tick Expected the removed timer not to fire, got %X", ic.Pending)
+	}
+	Timer_Add(6, 40, IRQ_CONSOLE)
+	Timer_Tick(ic, 39)
+	Timer_Tick(ic, 100)
+	if ic.Pending != 1<<IRQ_TIMER|1<<IRQ_CONSOLE || len(TimerService) != 0 {
+		t.Errorf("Timer_Tick Expected a timer to run out across several ticks, got %X", ic.Pending)
+	}
+	// A bad line doesn't stop the other timers, whichever order the map gives them
+	for id := uint(1); id <= 3; id++ {
+		Timer_Add(id, 1, IRQ_NUM_LINES)
+	}
+	Timer_Add(4, 1, IRQ_DISK)
+	Timer_Add(7, 5, IRQ_CONSOLE)
+	ic.Pending = 0
+	if Timer_Tick(ic, 1) == nil {
+		t.Errorf("Timer_Tick Expected an error for a timer on a line that doesn't exist")
+	}
+	if ic.Pending != 1<<IRQ_DISK || len(TimerService) != 1 || TimerService[7].CountdownTimer != 4 {
+		t.Errorf("Timer_Tick Expected every timer advanced and the bad ones removed, got %X %+v", ic.Pending, TimerService)
+	}
+}
+
+func TestTimerConcurrent(t *testing.T) {
+	Timer_Initialize()
+	defer Timer_Terminate()
+	ic := InterruptController_Initialize()
+	var wg sync.WaitGroup
+	for i := uint(0); i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				Timer_Add(i, 2, IRQ_DISK)
+				Timer_Remove(i)
+			}
+			Timer_Add(i, 2, IRQ_DISK)
+		}()
+	}
+	for j := 0; j < 100; j++ {
+		Timer_Tick(ic, 1)
+	}
+	wg.Wait()
+	Timer_Tick(ic, 2)
+	if ic.Pending&(1<<IRQ_DISK) == 0 || len(TimerService) != 0 {
+		t.Errorf("Timer_Tick Expected every timer to run out, got %d left", len(TimerService))
+	}
+}
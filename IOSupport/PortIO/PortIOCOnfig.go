@@ -18,6 +18,14 @@ const (
 	LegacyClockControlPort   = 0x1000_0000_0001_0000
 	LegacyClockDataPort      = 0x1000_0000_0001_0001
 	LegacyClockInterruptPort = 0x1000_0000_0001_0002
+
+	// The interrupt controller's registers. Each line's priority is a byte port at
+	// InterruptPriorityPort plus the line number.
+	InterruptPendingPort  = 0x1000_0000_0002_0000
+	InterruptMaskPort     = 0x1000_0000_0002_0001
+	InterruptAckPort      = 0x1000_0000_0002_0002
+	InterruptRaisePort    = 0x1000_0000_0002_0003
+	InterruptPriorityPort = 0x1000_0000_0002_0100
 )
 
 var PortIOConfig = map[uint64]PortIOConfigObject{
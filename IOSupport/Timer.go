@@ -1,23 +1,74 @@
 package IOSupport
 
+import (
+	"errors"
+	"sync"
+)
+
+// TimerQueue is a countdown in clock ticks that raises the interrupt line Interrupt when
+// it runs out
 type TimerQueue struct {
 	CountdownTimer uint64
 	Interrupt      uint64
 }
 
+// TimerService is shared by the CPU and the devices, which may run on other goroutines,
+// so it is only touched under timerLock
 var TimerService map[uint]TimerQueue
+var timerLock sync.Mutex
 
 func Timer_Initialize() {
+	timerLock.Lock()
+	defer timerLock.Unlock()
 	TimerService = make(map[uint]TimerQueue)
 }
 
 func Timer_Terminate() {
-
+	timerLock.Lock()
+	defer timerLock.Unlock()
+	TimerService = nil
 }
 
-func Timer_Add(timer uint, countdown uint64) {
+// Timer_Add starts timer counting down, replacing it if it's already running
+func Timer_Add(timer uint, countdown uint64, interrupt uint64) {
+	timerLock.Lock()
+	defer timerLock.Unlock()
+	if TimerService == nil {
+		TimerService = make(map[uint]TimerQueue)
+	}
+	TimerService[timer] = TimerQueue{CountdownTimer: countdown, Interrupt: interrupt}
 }
 
 func Timer_Remove(id uint) error {
+	timerLock.Lock()
+	defer timerLock.Unlock()
+	_, ok := TimerService[id]
+	if !ok {
+		return errors.New("No such timer")
+	}
+	delete(TimerService, id)
 	return nil
 }
+
+// Timer_Tick advances every timer by ticks clock ticks, the CPU's cycles. Timers that run
+// out raise their line on ic and are removed. Raise only fails for a line that doesn't
+// exist, which would fail again on every tick, so that timer is removed too and the first
+// such error is returned once every timer has been advanced.
+func Timer_Tick(ic *InterruptController, ticks uint64) error {
+	timerLock.Lock()
+	defer timerLock.Unlock()
+	var first error
+	for id, tq := range TimerService {
+		if tq.CountdownTimer > ticks {
+			tq.CountdownTimer -= ticks
+			TimerService[id] = tq
+			continue
+		}
+		delete(TimerService, id)
+		err := ic.Raise(int(tq.Interrupt))
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
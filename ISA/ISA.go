@@ -0,0 +1,295 @@
+package Onyx1ISA
+
+import (
+	Onyx1ALU "GolangCPUParts/ALU"
+	"encoding/binary"
+	"errors"
+)
+
+// Onyx1 instructions are a four byte header followed by an operand extension:
+//
+//	byte 0  opcode<<2 | size, the size selecting an 8, 16, 32 or 64-bit operand width
+//	byte 1  function: the ALU_OP_* code for ALU, which must be Executable, the ALU_COND_*
+//	        condition for JMP
+//	byte 2  Rd<<4 | Ra
+//	byte 3  mode<<4 | Rb
+//
+// The mode says what the source operand is and what follows the header:
+//
+//	REGISTER   Rb                                 nothing
+//	IMMEDIATE  an immediate value                 8 byte little-endian immediate
+//	INDIRECT   memory at Rb                       nothing
+//	INDEXED    memory at Rb + Ri<<scale + disp    Ri<<4 | scale, 4 byte little-endian disp
+//
+// An indexed operand without an index register, [Rb + disp], sets ISA_NO_INDEX_BIT in
+// place of Ri and the scale. All values are little-endian in memory.
+const (
+	ISA_OP_NOP     = 0x00
+	ISA_OP_HALT    = 0x01
+	ISA_OP_ALU     = 0x02
+	ISA_OP_MOV     = 0x03
+	ISA_OP_LOAD    = 0x04
+	ISA_OP_STORE   = 0x05
+	ISA_OP_LEA     = 0x06
+	ISA_OP_JMP     = 0x07
+	ISA_OP_CALL    = 0x08
+	ISA_OP_RET     = 0x09
+	ISA_OP_PUSH    = 0x0A
+	ISA_OP_POP     = 0x0B
+	ISA_OP_IN      = 0x0C
+	ISA_OP_OUT     = 0x0D
+	ISA_OP_GETSR   = 0x0E
+	ISA_OP_SETSR   = 0x0F
+	ISA_OP_BRK     = 0x10
+	ISA_OP_SYSCALL = 0x11
+	ISA_OP_RTE     = 0x12
+
+	ISA_MODE_REGISTER  = 0x0
+	ISA_MODE_IMMEDIATE = 0x1
+	ISA_MODE_INDIRECT  = 0x2
+	ISA_MODE_INDEXED   = 0x3
+
+	ISA_SIZE_8  = 0
+	ISA_SIZE_16 = 1
+	ISA_SIZE_32 = 2
+	ISA_SIZE_64 = 3
+
+	ISA_NUM_REGISTERS = 16
+	ISA_REG_SP        = 15
+	ISA_NO_INDEX      = -1
+	ISA_NO_INDEX_BIT  = 0x08
+
+	ISA_HEADER_LENGTH    = 4
+	ISA_IMMEDIATE_LENGTH = 8
+	ISA_INDEXED_LENGTH   = 5
+	ISA_MAX_LENGTH       = ISA_HEADER_LENGTH + ISA_IMMEDIATE_LENGTH
+)
+
+var ISAModeNames = []string{"REGISTER", "IMMEDIATE", "INDIRECT", "INDEXED"}
+var ISASizeSuffixes = []string{".B", ".W", ".D", ".Q"}
+
+// Operand shapes, telling the assembler and disassembler which fields an opcode uses
+const (
+	ISA_FORM_NONE       = 0 // NOP, HALT, RET, BRK, RTE
+	ISA_FORM_ALU        = 1 // Rd, Ra, operand
+	ISA_FORM_RD_OPERAND = 2 // Rd, operand
+	ISA_FORM_OPERAND    = 3 // operand
+	ISA_FORM_RD         = 4 // Rd
+)
+
+// Mode masks for ISAOpcodeInfo.Modes
+const (
+	ISA_MODES_VALUE  = 1<<ISA_MODE_REGISTER | 1<<ISA_MODE_IMMEDIATE
+	ISA_MODES_MEMORY = 1<<ISA_MODE_INDIRECT | 1<<ISA_MODE_INDEXED
+	ISA_MODES_ALL    = ISA_MODES_VALUE | ISA_MODES_MEMORY
+)
+
+// ISAOpcodeInfo describes one opcode. Modes is the mask of the addressing modes its
+// operand may use, Sized is set when the size bits matter, and Privileged when only
+// supervisor mode may run it.
+type ISAOpcodeInfo struct {
+	Opcode     int
+	Mnemonic   string
+	Form       int
+	Modes      int
+	Sized      bool
+	Privileged bool
+}
+
+var ISAOpcodes = map[int]ISAOpcodeInfo{
+	ISA_OP_NOP:     {ISA_OP_NOP, "NOP", ISA_FORM_NONE, 0, false, false},
+	ISA_OP_HALT:    {ISA_OP_HALT, "HALT", ISA_FORM_NONE, 0, false, true},
+	ISA_OP_ALU:     {ISA_OP_ALU, "ALU", ISA_FORM_ALU, ISA_MODES_ALL, true, false},
+	ISA_OP_MOV:     {ISA_OP_MOV, "MOV", ISA_FORM_RD_OPERAND, ISA_MODES_VALUE, false, false},
+	ISA_OP_LOAD:    {ISA_OP_LOAD, "LOAD", ISA_FORM_RD_OPERAND, ISA_MODES_MEMORY, true, false},
+	ISA_OP_STORE:   {ISA_OP_STORE, "STORE", ISA_FORM_RD_OPERAND, ISA_MODES_MEMORY, true, false},
+	ISA_OP_LEA:     {ISA_OP_LEA, "LEA", ISA_FORM_RD_OPERAND, ISA_MODES_MEMORY, false, false},
+	ISA_OP_JMP:     {ISA_OP_JMP, "JMP", ISA_FORM_OPERAND, ISA_MODES_ALL, false, false},
+	ISA_OP_CALL:    {ISA_OP_CALL, "CALL", ISA_FORM_OPERAND, ISA_MODES_ALL, false, false},
+	ISA_OP_RET:     {ISA_OP_RET, "RET", ISA_FORM_NONE, 0, false, false},
+	ISA_OP_PUSH:    {ISA_OP_PUSH, "PUSH", ISA_FORM_RD, 0, false, false},
+	ISA_OP_POP:     {ISA_OP_POP, "POP", ISA_FORM_RD, 0, false, false},
+	ISA_OP_IN:      {ISA_OP_IN, "IN", ISA_FORM_RD_OPERAND, ISA_MODES_VALUE, true, true},
+	ISA_OP_OUT:     {ISA_OP_OUT, "OUT", ISA_FORM_RD_OPERAND, ISA_MODES_VALUE, true, true},
+	ISA_OP_GETSR:   {ISA_OP_GETSR, "GETSR", ISA_FORM_RD, 0, false, false},
+	ISA_OP_SETSR:   {ISA_OP_SETSR, "SETSR", ISA_FORM_OPERAND, ISA_MODES_VALUE, false, true},
+	ISA_OP_BRK:     {ISA_OP_BRK, "BRK", ISA_FORM_NONE, 0, false, false},
+	ISA_OP_SYSCALL: {ISA_OP_SYSCALL, "SYSCALL", ISA_FORM_OPERAND, ISA_MODES_VALUE, false, false},
+	ISA_OP_RTE:     {ISA_OP_RTE, "RTE", ISA_FORM_NONE, 0, false, true},
+}
+
+// Operand is the source operand. Reg is Rb, and Index, Scale and Disp are only used in
+// indexed mode, where the address is Reg + Index<<Scale + Disp, or Reg + Disp when Index
+// is ISA_NO_INDEX.
+type Operand struct {
+	Mode      int
+	Reg       int
+	Index     int
+	Scale     int
+	Disp      int32
+	Immediate uint64
+}
+
+// Instruction is one decoded instruction. Func is the ALU op for ALU and the condition
+// for JMP. Width is the operand width in bits, from Size.
+type Instruction struct {
+	Address uint64
+	Length  int
+	Opcode  int
+	Size    int
+	Width   int
+	Func    int
+	Rd      int
+	Ra      int
+	Operand Operand
+}
+
+// ByteReader is anything instructions can be fetched from a byte at a time, such as
+// VirtualMemory.VMContainer or PhysicalMemory.PhysicalMemoryManager
+type ByteReader interface {
+	ReadAddress(addr uint64) (byte, error)
+}
+
+func (op Operand) IsMemory() bool {
+	return op.Mode == ISA_MODE_INDIRECT || op.Mode == ISA_MODE_INDEXED
+}
+
+// extensionLength is the number of bytes after the header in the given mode
+func extensionLength(mode int) int {
+	switch mode {
+	case ISA_MODE_IMMEDIATE:
+		return ISA_IMMEDIATE_LENGTH
+	case ISA_MODE_INDEXED:
+		return ISA_INDEXED_LENGTH
+	}
+	return 0
+}
+
+// InstructionLength is the length of an instruction from its header byte 3
+func InstructionLength(modeByte byte) int {
+	return ISA_HEADER_LENGTH + extensionLength(int(modeByte>>4))
+}
+
+// check verifies the instruction is one this ISA can encode
+func (inst Instruction) check() error {
+	info, ok := ISAOpcodes[inst.Opcode]
+	if !ok {
+		return errors.New("Illegal instruction")
+	}
+	op := inst.Operand
+	if inst.Size < ISA_SIZE_8 || inst.Size > ISA_SIZE_64 || inst.Func < 0 || inst.Func > 0xFF {
+		return errors.New("Illegal instruction")
+	}
+	if inst.Rd < 0 || inst.Rd >= ISA_NUM_REGISTERS || inst.Ra < 0 || inst.Ra >= ISA_NUM_REGISTERS ||
+		op.Reg < 0 || op.Reg >= ISA_NUM_REGISTERS || op.Index < ISA_NO_INDEX || op.Index >= ISA_NUM_REGISTERS {
+		return errors.New("Illegal register")
+	}
+	if op.Index == ISA_NO_INDEX && (op.Mode != ISA_MODE_INDEXED || op.Scale != 0) {
+		return errors.New("Illegal addressing mode")
+	}
+	if op.Mode < ISA_MODE_REGISTER || op.Mode > ISA_MODE_INDEXED || op.Scale < 0 || op.Scale > 3 {
+		return errors.New("Illegal addressing mode")
+	}
+	// Opcodes without an operand leave it as register 0
+	if info.Modes&(1<<op.Mode) == 0 && (info.Modes != 0 || op.Mode != ISA_MODE_REGISTER) {
+		return errors.New("Illegal addressing mode for " + info.Mnemonic)
+	}
+	switch inst.Opcode {
+	case ISA_OP_ALU:
+		alu, ok := Onyx1ALU.ALULookupOp(inst.Func)
+		if !ok {
+			return errors.New("Illegal ALU op")
+		}
+		if !alu.Executable() {
+			return errors.New(alu.Mnemonic + " can't run on registers")
+		}
+	case ISA_OP_JMP:
+		if !Onyx1ALU.ALUConditionValid(inst.Func) {
+			return errors.New("Illegal condition")
+		}
+	}
+	return nil
+}
+
+// Encode returns the bytes of an instruction
+func Encode(inst Instruction) ([]byte, error) {
+	err := inst.check()
+	if err != nil {
+		return nil, err
+	}
+	op := inst.Operand
+	b := []byte{
+		byte(inst.Opcode<<2 | inst.Size),
+		byte(inst.Func),
+		byte(inst.Rd<<4 | inst.Ra),
+		byte(op.Mode<<4 | op.Reg),
+	}
+	switch op.Mode {
+	case ISA_MODE_IMMEDIATE:
+		b = binary.LittleEndian.AppendUint64(b, op.Immediate)
+	case ISA_MODE_INDEXED:
+		if op.Index == ISA_NO_INDEX {
+			b = append(b, ISA_NO_INDEX_BIT)
+		} else {
+			b = append(b, byte(op.Index<<4|op.Scale))
+		}
+		b = binary.LittleEndian.AppendUint32(b, uint32(op.Disp))
+	}
+	return b, nil
+}
+
+// DecodeBytes decodes the instruction at the start of b, which was read from addr
+func DecodeBytes(b []byte, addr uint64) (Instruction, error) {
+	if len(b) < ISA_HEADER_LENGTH || len(b) < InstructionLength(b[3]) {
+		return Instruction{}, errors.New("Truncated instruction")
+	}
+	inst := Instruction{
+		Address: addr,
+		Length:  InstructionLength(b[3]),
+		Opcode:  int(b[0] >> 2),
+		Size:    int(b[0] & 3),
+		Width:   Onyx1ALU.ALU_WIDTH_8 << (b[0] & 3),
+		Func:    int(b[1]),
+		Rd:      int(b[2] >> 4),
+		Ra:      int(b[2] & 0xF),
+		Operand: Operand{Mode: int(b[3] >> 4), Reg: int(b[3] & 0xF)},
+	}
+	switch inst.Operand.Mode {
+	case ISA_MODE_IMMEDIATE:
+		inst.Operand.Immediate = binary.LittleEndian.Uint64(b[ISA_HEADER_LENGTH:])
+	case ISA_MODE_INDEXED:
+		if b[ISA_HEADER_LENGTH] == ISA_NO_INDEX_BIT {
+			inst.Operand.Index = ISA_NO_INDEX
+		} else {
+			inst.Operand.Index = int(b[ISA_HEADER_LENGTH] >> 4)
+			inst.Operand.Scale = int(b[ISA_HEADER_LENGTH] & 0xF)
+		}
+		inst.Operand.Disp = int32(binary.LittleEndian.Uint32(b[ISA_HEADER_LENGTH+1:]))
+	}
+	err := inst.check()
+	if err != nil {
+		return Instruction{}, err
+	}
+	return inst, nil
+}
+
+// Decode fetches and decodes the instruction at addr
+func Decode(mem ByteReader, addr uint64) (Instruction, error) {
+	b := make([]byte, ISA_HEADER_LENGTH, ISA_MAX_LENGTH)
+	for i := range b {
+		v, err := mem.ReadAddress(addr + uint64(i))
+		if err != nil {
+			return Instruction{}, err
+		}
+		b[i] = v
+	}
+	n := InstructionLength(b[3])
+	for i := ISA_HEADER_LENGTH; i < n; i++ {
+		v, err := mem.ReadAddress(addr + uint64(i))
+		if err != nil {
+			return Instruction{}, err
+		}
+		b = append(b, v)
+	}
+	return DecodeBytes(b, addr)
+}
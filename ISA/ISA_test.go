@@ -0,0 +1,102 @@
+package Onyx1ISA
+
+import (
+	Onyx1ALU "GolangCPUParts/ALU"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// byteMemory is a ByteReader over a slice
+type byteMemory []byte
+
+func (m byteMemory) ReadAddress(addr uint64) (byte, error) {
+	if addr >= uint64(len(m)) {
+		return 0, errors.New("Address out of range")
+	}
+	return m[addr], nil
+}
+
+func TestEncodeDecode(t *testing.T) {
+	tests := []struct {
+		inst Instruction
+		want []byte
+	}{
+		{Instruction{Opcode: ISA_OP_ALU, Size: ISA_SIZE_32, Func: Onyx1ALU.ALU_OP_ADDINT64, Rd: 1, Ra: 2,
+			Operand: Operand{Mode: ISA_MODE_REGISTER, Reg: 3}},
+			[]byte{0x0A, 0x01, 0x12, 0x03}},
+		{Instruction{Opcode: ISA_OP_MOV, Size: ISA_SIZE_64, Rd: 4,
+			Operand: Operand{Mode: ISA_MODE_IMMEDIATE, Immediate: 0x1122334455667788}},
+			[]byte{0x0F, 0x00, 0x40, 0x10, 0x88, 0x77, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11}},
+		{Instruction{Opcode: ISA_OP_LOAD, Size: ISA_SIZE_16, Rd: 5,
+			Operand: Operand{Mode: ISA_MODE_INDIRECT, Reg: 6}},
+			[]byte{0x11, 0x00, 0x50, 0x26}},
+		{Instruction{Opcode: ISA_OP_STORE, Size: ISA_SIZE_8, Rd: 7,
+			Operand: Operand{Mode: ISA_MODE_INDEXED, Reg: 8, Index: 9, Scale: 3, Disp: -4}},
+			[]byte{0x14, 0x00, 0x70, 0x38, 0x93, 0xFC, 0xFF, 0xFF, 0xFF}},
+		{Instruction{Opcode: ISA_OP_LEA, Size: ISA_SIZE_64, Rd: 1,
+			Operand: Operand{Mode: ISA_MODE_INDEXED, Reg: 2, Index: ISA_NO_INDEX, Disp: 0x100}},
+			[]byte{0x1B, 0x00, 0x10, 0x32, 0x08, 0x00, 0x01, 0x00, 0x00}},
+		{Instruction{Opcode: ISA_OP_JMP, Size: ISA_SIZE_64, Func: Onyx1ALU.ALU_COND_NE,
+			Operand: Operand{Mode: ISA_MODE_IMMEDIATE, Immediate: 0x100}},
+			[]byte{0x1F, 0x02, 0x00, 0x10, 0x00, 0x01, 0, 0, 0, 0, 0, 0}},
+		{Instruction{Opcode: ISA_OP_SETSR, Size: ISA_SIZE_64,
+			Operand: Operand{Mode: ISA_MODE_IMMEDIATE, Immediate: 0x10000}},
+			[]byte{0x3F, 0x00, 0x00, 0x10, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0}},
+		{Instruction{Opcode: ISA_OP_RET, Size: ISA_SIZE_64},
+			[]byte{0x27, 0x00, 0x00, 0x00}},
+	}
+	for _, tt := range tests {
+		b, err := Encode(tt.inst)
+		if err != nil || !bytes.Equal(b, tt.want) {
+			t.Errorf("Encode Expected % x, got % x %v", tt.want, b, err)
+			continue
+		}
+		// Decode from a nonzero address with trailing bytes after the instruction
+		mem := append(append([]byte{0xFF, 0xFF}, b...), 0xEE, 0xEE)
+		inst, err := Decode(byteMemory(mem), 2)
+		want := tt.inst
+		want.Address = 2
+		want.Length = len(b)
+		want.Width = Onyx1ALU.ALU_WIDTH_8 << want.Size
+		if err != nil || inst != want {
+			t.Errorf("Decode Expected %+v, got %+v %v", want, inst, err)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	bad := [][]byte{
+		{0xFC, 0x00, 0x00, 0x00},                         // opcode 0x3F
+		{0x08, 0x00, 0x00, 0x00},                         // ALU op 0
+		{0x08, 0x01, 0x00, 0x40},                         // mode 4
+		{0x0C, 0x00, 0x00, 0x20},                         // MOV from memory
+		{0x10, 0x00, 0x00, 0x00},                         // LOAD from a register
+		{0x1C, 0x40, 0x00, 0x00},                         // condition 0x40
+		{0x24, 0x00, 0x00, 0x10, 0, 0, 0, 0, 0, 0, 0, 0}, // RET with an immediate
+		{0x14, 0x00, 0x00, 0x30, 0x04, 0, 0, 0, 0},       // scale 4
+		{0x0C, 0x00, 0x00, 0x10, 0, 0},                   // truncated immediate
+	}
+	for _, b := range bad {
+		_, err := DecodeBytes(b, 0)
+		if err == nil {
+			t.Errorf("DecodeBytes Expected an error for % x", b)
+		}
+	}
+	_, err := Decode(byteMemory{0x0C, 0x00, 0x00, 0x10}, 0)
+	if err == nil {
+		t.Errorf("Decode Expected an error reading past the end of memory")
+	}
+	_, err = Encode(Instruction{Opcode: ISA_OP_ALU, Func: Onyx1ALU.ALU_OP_ADDINT64, Rd: 16})
+	if err == nil {
+		t.Errorf("Encode Expected an error for register 16")
+	}
+	_, err = Encode(Instruction{Opcode: ISA_OP_ALU, Func: Onyx1ALU.ALU_OP_BIGADD})
+	if err == nil || err.Error() != "BIGADD can't run on registers" {
+		t.Errorf("Encode Expected an error for an op Execute can't run, got %v", err)
+	}
+	_, err = DecodeBytes([]byte{0x08, byte(Onyx1ALU.ALU_OP_DADD), 0x00, 0x00}, 0)
+	if err == nil {
+		t.Errorf("DecodeBytes Expected an error for DADD")
+	}
+}
@@ -20,8 +20,15 @@ const (
 	Protection_CanWrite   = 0x2
 	Protection_CanExecute = 0x4
 	Protection_NeedSystem = 0x8
+
+	// The privilege of a memory access. Kernel-RAM and Protection_NeedSystem blocks can only
+	// be reached from AccessMode_System.
+	AccessMode_User   = 0
+	AccessMode_System = 1
 )
 
+var ErrProtectionFault = errors.New("Protection fault")
+
 type PhysicalMemoryBlock struct {
 	Buffer       []byte
 	StartAddress uint64
@@ -216,7 +223,27 @@ func (pmc *PhysicalMemoryManager) WritePage(p uint32, data []byte) error {
 	return nil
 }
 
+// ReadAddress reads a byte with system access, for loaders and devices
 func (pmc *PhysicalMemoryManager) ReadAddress(addr uint64) (uint8, error) {
+	return pmc.ReadAddressMode(addr, AccessMode_System)
+}
+
+// WriteAddress writes a byte with system access, for loaders and devices
+func (pmc *PhysicalMemoryManager) WriteAddress(addr uint64, data uint8) error {
+	return pmc.WriteAddressMode(addr, data, AccessMode_System)
+}
+
+// CheckAccess faults an access in mode to a block that needs system access
+func (block *PhysicalMemoryBlock) CheckAccess(mode int) error {
+	if mode != AccessMode_System &&
+		(block.MemoryType == MemoryType_KernelRAM || block.Protection&Protection_NeedSystem != 0) {
+		return ErrProtectionFault
+	}
+	return nil
+}
+
+// ReadAddressMode reads a byte as a CPU running in mode does
+func (pmc *PhysicalMemoryManager) ReadAddressMode(addr uint64, mode int) (uint8, error) {
 	block, err := pmc.GetBlockByAddress(addr)
 	if err != nil {
 		return 0, err
@@ -225,10 +252,15 @@ func (pmc *PhysicalMemoryManager) ReadAddress(addr uint64) (uint8, error) {
 	if err != nil {
 		return 0, err
 	}
+	err = block.CheckAccess(mode)
+	if err != nil {
+		return 0, err
+	}
 	return block.Buffer[addr-block.StartAddress], nil
 }
 
-func (pmc *PhysicalMemoryManager) WriteAddress(addr uint64, data uint8) error {
+// WriteAddressMode writes a byte as a CPU running in mode does
+func (pmc *PhysicalMemoryManager) WriteAddressMode(addr uint64, data uint8, mode int) error {
 	block, err := pmc.GetBlockByAddress(addr)
 	if err != nil {
 		return err
@@ -237,6 +269,10 @@ func (pmc *PhysicalMemoryManager) WriteAddress(addr uint64, data uint8) error {
 	if err != nil {
 		return err
 	}
+	err = block.CheckAccess(mode)
+	if err != nil {
+		return err
+	}
 	block.Buffer[addr-block.StartAddress] = data
 	return nil
 }
@@ -2,7 +2,9 @@ package ProcessMemory
 
 import (
 	"GolangCPUParts/IOSupport/Pipes"
+	"GolangCPUParts/MemoryPackage/PhysicalMemory"
 	"GolangCPUParts/MemoryPackage/VirtualMemory"
+	"errors"
 	"math/rand"
 )
 
@@ -68,6 +70,45 @@ func ProcessTable_Initialize(vmp *VirtualMemory.VMContainer) *ProcessTable {
 	return &pt
 }
 
+// AllocateSegment gives segment size pages of virtual memory with protection. The pages of
+// a Protection_NeedSystem segment are marked PageStatus_NeedSystem, so the VMContainer
+// faults any user mode access to them.
+func (pt *ProcessTable) AllocateSegment(segment int, size int, protection uint64) ([]uint32, error) {
+	_, ok := pt.Segments[segment]
+	if ok {
+		return nil, errors.New("Segment already allocated")
+	}
+	pages, err := pt.VMC.AllocateVirtualPages(size)
+	if err != nil {
+		return nil, err
+	}
+	if protection&Protection_NeedSystem != 0 {
+		for _, pg := range pages {
+			pt.VMC.SetPageNeedsSystem(pg)
+		}
+	}
+	pt.Segments[segment] = SegmentObject{BasePage: int(pages[0]), Size: size, Protection: protection}
+	return pages, nil
+}
+
+// CheckAccess faults an access to a Protection_NeedSystem segment made from user mode.
+// mode is PhysicalMemory.AccessMode_User or AccessMode_System.
+func (so SegmentObject) CheckAccess(mode int) error {
+	if so.Protection&Protection_NeedSystem != 0 && mode != PhysicalMemory.AccessMode_System {
+		return PhysicalMemory.ErrProtectionFault
+	}
+	return nil
+}
+
+// CheckSegmentAccess checks an access in mode to a segment of the table
+func (pt *ProcessTable) CheckSegmentAccess(segment int, mode int) error {
+	so, ok := pt.Segments[segment]
+	if !ok {
+		return errors.New("Segment not found")
+	}
+	return so.CheckAccess(mode)
+}
+
 type PipeTable struct {
 	Pipes 	[]Pipes.PipePair
 }
@@ -0,0 +1,69 @@
+package ProcessMemory
+
+import (
+	"GolangCPUParts/Configuration"
+	"GolangCPUParts/MemoryPackage/PhysicalMemory"
+	"GolangCPUParts/MemoryPackage/VirtualMemory"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSegmentNeedSystem(t *testing.T) {
+	cfg := Configuration.ConfigObject{
+		Settings: Configuration.ConfigSettings{SwapFileName: filepath.Join(t.TempDir(), "swap.swp")},
+		Configuration: []Configuration.SystemConfigs{{
+			Name: "Paged",
+			Description: Configuration.ConfigurationDescriptor{Memory: []Configuration.MemoryDescriptor{
+				{Key: 0, StartAddress: 0x0000, EndAddress: 0xFFFF, MemoryType: "Virtual-RAM"},
+			}},
+		}},
+	}
+	vmc, err := VirtualMemory.VirtualMemoryInitialize(cfg, "Paged")
+	if err != nil {
+		t.Fatalf("Segment Expected virtual memory, got %s", err)
+	}
+	defer vmc.Terminate()
+	for page := uint32(0); page < 4; page++ {
+		vmc.FreeVirtualPages.PushBack(page)
+	}
+	pt := ProcessTable_Initialize(vmc)
+	kernel, err := pt.AllocateSegment(1, 1, Protection_Kernel)
+	if err != nil {
+		t.Fatalf("Segment Expected a kernel segment, got %s", err)
+	}
+	data, err := pt.AllocateSegment(2, 1, Protection_Data)
+	if err != nil {
+		t.Fatalf("Segment Expected a data segment, got %s", err)
+	}
+	_, err = pt.AllocateSegment(2, 1, Protection_Data)
+	if err == nil {
+		t.Errorf("Segment Expected a second segment 2 to fail")
+	}
+	// Keep both pages in memory, backed by block 0
+	for _, pg := range []uint32{kernel[0], data[0]} {
+		vmc.SetPageIsNotOnDisk(pg)
+	}
+	kaddr := uint64(kernel[0]) * PhysicalMemory.PhysicalPageSize
+	daddr := uint64(data[0]) * PhysicalMemory.PhysicalPageSize
+	_, err = vmc.ReadAddressMode(kaddr, PhysicalMemory.AccessMode_User)
+	if !errors.Is(err, PhysicalMemory.ErrProtectionFault) {
+		t.Errorf("Segment Expected a user read of the kernel segment to fault, got %v", err)
+	}
+	err = vmc.WriteAddressMode(kaddr, 1, PhysicalMemory.AccessMode_User)
+	if !errors.Is(err, PhysicalMemory.ErrProtectionFault) {
+		t.Errorf("Segment Expected a user write to the kernel segment to fault, got %v", err)
+	}
+	_, err = vmc.ReadAddressMode(kaddr, PhysicalMemory.AccessMode_System)
+	if err != nil {
+		t.Errorf("Segment Expected a system read of the kernel segment, got %s", err)
+	}
+	_, err = vmc.ReadAddressMode(daddr, PhysicalMemory.AccessMode_User)
+	if err != nil {
+		t.Errorf("Segment Expected a user read of the data segment, got %s", err)
+	}
+	if !errors.Is(pt.CheckSegmentAccess(1, PhysicalMemory.AccessMode_User), PhysicalMemory.ErrProtectionFault) ||
+		pt.CheckSegmentAccess(2, PhysicalMemory.AccessMode_User) != nil {
+		t.Errorf("CheckSegmentAccess Expected only segment 1 to need system access")
+	}
+}
@@ -15,6 +15,8 @@ const (
 	PageStatus_Active = uint64(0x0000_0000_0000_0001)
 	PageStatus_OnDisk = uint64(0x0000_0000_0000_0002)
 	PageStatus_Locked = uint64(0x0000_0000_0000_0004)
+	// Only AccessMode_System may reach the page, whatever block backs it
+	PageStatus_NeedSystem = uint64(0x0000_0000_0000_0008)
 
 	MinFreePages    = 8
 	MaxVirtualPages = 1024 * 1024
@@ -47,10 +49,23 @@ func (vmc *VMContainer) IsPageOnDisk(page uint32) bool {
 func (vmc *VMContainer) IsPageLocked(page uint32) bool {
 	return vmc.MemoryPages[page].Status&PageStatus_Locked != 0
 }
+func (vmc *VMContainer) IsPageSystem(page uint32) bool {
+	return vmc.MemoryPages[page].Status&PageStatus_NeedSystem != 0
+}
 func (vmc *VMContainer) GetBuffer(page uint32) []byte {
 	ppage := vmc.MemoryPages[page].PhysicalPage
 	return vmc.PhysicalPMemory.Blocks[ppage].Buffer
 }
+
+// pageBlock is the physical block behind a page, which GetBuffer reads
+func (vmc *VMContainer) pageBlock(page uint32) (*PhysicalMemory.PhysicalMemoryBlock, error) {
+	ppage := vmc.MemoryPages[page].PhysicalPage
+	if int(ppage) >= len(vmc.PhysicalPMemory.Blocks) {
+		return nil, errors.New("Page has no physical memory")
+	}
+	return &vmc.PhysicalPMemory.Blocks[ppage], nil
+}
+
 func (vmc *VMContainer) SetPageActive(page uint32) {
 	s := vmc.MemoryPages[page]
 	s.Status |= PageStatus_Active
@@ -66,6 +81,11 @@ func (vmc *VMContainer) SetPageIsLocked(page uint32) {
 	s.Status |= PageStatus_Locked
 	vmc.MemoryPages[page] = s
 }
+func (vmc *VMContainer) SetPageNeedsSystem(page uint32) {
+	s := vmc.MemoryPages[page]
+	s.Status |= PageStatus_NeedSystem
+	vmc.MemoryPages[page] = s
+}
 func (vmc *VMContainer) SetPageIsNotOnDisk(page uint32) {
 	s := vmc.MemoryPages[page]
 	s.Status &= ^PageStatus_OnDisk
@@ -97,24 +117,24 @@ func DebugList(name string, l *list.List) {
 	}
 }
 
-func MoveFreeToUsed(freelst *list.List, usedlst *list.List, pg uint32) {
+func MoveFreeToUsed(freelst *list.List, usedlst *list.List, pg uint32) error {
 	elm := ListFindUint32(freelst, pg)
 	if elm == nil {
-		panic("Can't find page in free list")
-		return
+		return errors.New("Can't find page in free list")
 	}
 	freelst.Remove(elm)
 	usedlst.PushBack(pg)
+	return nil
 }
 
-func MoveUsedToFree(usedlist *list.List, freelst *list.List, pg uint32) {
+func MoveUsedToFree(usedlist *list.List, freelst *list.List, pg uint32) error {
 	elm := ListFindUint32(usedlist, pg)
 	if elm == nil {
-		panic("Can't find page in used list")
-		return
+		return errors.New("Can't find page in used list")
 	}
 	usedlist.Remove(elm)
 	freelst.PushBack(pg)
+	return nil
 }
 
 func VirtualMemoryInitialize(
@@ -225,7 +245,7 @@ func (vmc *VMContainer) AllocateVirtualPages(numPagse int) ([]uint32, error) {
 	}
 	lst := make([]uint32, numPagse)
 	pageIdx := 0
-	for vmc.FreeVirtualPages.Len() > 0 {
+	for pageIdx < numPagse {
 		elm := vmc.FreeVirtualPages.Front()
 		vmc.FreeVirtualPages.Remove(elm)
 		pgValue := elm.Value.(uint32)
@@ -251,8 +271,16 @@ func (vmc *VMContainer) ReturnVirtualPages(pages []uint32) error {
 		return errors.New("Invalid number of pages")
 	}
 	for _, pg := range pages {
-		MoveUsedToFree(vmc.UsedVirtualPages, vmc.FreeVirtualPages, pg)
-		MoveUsedToFree(vmc.UsedPhysicalMemory, vmc.FreePhysicalMemory, vmc.MemoryPages[pg].PhysicalPage)
+		err := MoveUsedToFree(vmc.UsedVirtualPages, vmc.FreeVirtualPages, pg)
+		if err != nil {
+			RemoteLogging.LogEvent("ERROR", "ReturnVirtualPages", err.Error())
+			return err
+		}
+		err = MoveUsedToFree(vmc.UsedPhysicalMemory, vmc.FreePhysicalMemory, vmc.MemoryPages[pg].PhysicalPage)
+		if err != nil {
+			RemoteLogging.LogEvent("ERROR", "ReturnVirtualPages", err.Error())
+			return err
+		}
 		delete(vmc.MemoryPages, pg)
 	}
 	return nil
@@ -287,7 +315,11 @@ func (vmc *VMContainer) SwapOutPage(page uint32) error {
 		return err
 	}
 	vmc.SetPageIsOnDisk(page)
-	MoveUsedToFree(vmc.UsedPhysicalMemory, vmc.FreePhysicalMemory, vmc.MemoryPages[page].PhysicalPage)
+	err = MoveUsedToFree(vmc.UsedPhysicalMemory, vmc.FreePhysicalMemory, vmc.MemoryPages[page].PhysicalPage)
+	if err != nil {
+		RemoteLogging.LogEvent("ERROR", "SwapOutPage", err.Error())
+		return err
+	}
 	return nil
 }
 
@@ -322,8 +354,16 @@ func (vmc *VMContainer) SwapInPage(page uint32) error {
 			return err
 		}
 		// We've got a page, so point the virtual page to it
+		if vmc.FreePhysicalMemory.Len() == 0 {
+			RemoteLogging.LogEvent("ERROR", "SwapInPage", "No free physical pages")
+			return errors.New("No free physical pages")
+		}
 		newPage := vmc.FreePhysicalMemory.Front().Value.(uint32)
-		MoveFreeToUsed(vmc.FreePhysicalMemory, vmc.UsedPhysicalMemory, newPage)
+		err = MoveFreeToUsed(vmc.FreePhysicalMemory, vmc.UsedPhysicalMemory, newPage)
+		if err != nil {
+			RemoteLogging.LogEvent("ERROR", "SwapInPage", err.Error())
+			return err
+		}
 		s := vmc.MemoryPages[page]
 		s.PhysicalPage = newPage
 		vmc.MemoryPages[page] = s
@@ -399,6 +439,10 @@ func (vmc *VMContainer) ReadPage(page uint32) ([]byte, error) {
 			return nil, err
 		}
 	}
+	_, err := vmc.pageBlock(page)
+	if err != nil {
+		return nil, err
+	}
 	vmc.LRUCache.PushFront(page)
 	return vmc.GetBuffer(page), nil
 }
@@ -420,29 +464,67 @@ func (vmc *VMContainer) WritePage(page uint32, buf []byte) error {
 			return err
 		}
 	}
+	_, err := vmc.pageBlock(page)
+	if err != nil {
+		return err
+	}
 	blk := vmc.GetBuffer(page)
 	copy(blk, buf)
 	vmc.LRUCache.PushFront(page)
 	return nil
 }
 
+// ReadAddress reads a byte with system access, for loaders and devices
 func (vmc *VMContainer) ReadAddress(addr uint64) (byte, error) {
-	page := addr / PhysicalMemory.PhysicalPageSize
+	return vmc.ReadAddressMode(addr, PhysicalMemory.AccessMode_System)
+}
+
+// WriteAddress writes a byte with system access, for loaders and devices
+func (vmc *VMContainer) WriteAddress(addr uint64, value byte) error {
+	return vmc.WriteAddressMode(addr, value, PhysicalMemory.AccessMode_System)
+}
+
+// CheckPageAccess faults an access in mode to a page marked PageStatus_NeedSystem or
+// backed by a block that needs system access, Kernel-RAM or Protection_NeedSystem. The
+// page must be in memory.
+func (vmc *VMContainer) CheckPageAccess(page uint32, mode int) error {
+	if vmc.IsPageSystem(page) && mode != PhysicalMemory.AccessMode_System {
+		return PhysicalMemory.ErrProtectionFault
+	}
+	block, err := vmc.pageBlock(page)
+	if err != nil {
+		return err
+	}
+	return block.CheckAccess(mode)
+}
+
+// ReadAddressMode reads a byte for a CPU running in mode
+func (vmc *VMContainer) ReadAddressMode(addr uint64, mode int) (byte, error) {
+	page := uint32(addr / PhysicalMemory.PhysicalPageSize)
 	offset := addr % PhysicalMemory.PhysicalPageSize
-	buf, err := vmc.ReadPage(uint32(page))
+	buf, err := vmc.ReadPage(page)
+	if err != nil {
+		return 0, err
+	}
+	err = vmc.CheckPageAccess(page, mode)
 	if err != nil {
 		return 0, err
 	}
 	return buf[offset], nil
 }
 
-func (vmc *VMContainer) WriteAddress(addr uint64, value byte) error {
-	page := addr / PhysicalMemory.PhysicalPageSize
+// WriteAddressMode writes a byte for a CPU running in mode
+func (vmc *VMContainer) WriteAddressMode(addr uint64, value byte, mode int) error {
+	page := uint32(addr / PhysicalMemory.PhysicalPageSize)
 	offset := addr % PhysicalMemory.PhysicalPageSize
-	buf, err := vmc.ReadPage(uint32(page))
+	buf, err := vmc.ReadPage(page)
+	if err != nil {
+		return err
+	}
+	err = vmc.CheckPageAccess(page, mode)
 	if err != nil {
 		return err
 	}
 	buf[offset] = value
-	return vmc.WritePage(uint32(page), buf)
+	return vmc.WritePage(page, buf)
 }